@@ -0,0 +1,212 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAdaptiveInterval      = 5000
+	defaultAdaptiveTargetLatency = 500
+	defaultAdaptiveMaxErrorRate  = 0.1
+)
+
+// AdaptiveWorkers configures the resizing of the worker pool during the
+// crawl. Every interval the crawler looks at the requests sent to the
+// allowed domains since the last check, as the ones sent to other hosts do
+// not load the crawled site: if any of them was answered with 429 Too Many
+// Requests, or the ratio of errors is above MaxErrorRate, the number of
+// workers is halved. Otherwise a worker is added if the average latency is
+// below TargetLatency, and one is removed if it is above twice the
+// TargetLatency.
+type AdaptiveWorkers struct {
+	// Enabled turns on the resizing of the worker pool.
+	Enabled bool `toml:"enabled" yaml:"enabled" json:"enabled"`
+
+	// MinWorkers is the least number of workers. Setting it to 0 will use 1.
+	MinWorkers int `toml:"min-workers" yaml:"min-workers" json:"min-workers"`
+
+	// MaxWorkers is the most number of workers. Setting it to 0 will use four
+	// times the worker count.
+	MaxWorkers int `toml:"max-workers" yaml:"max-workers" json:"max-workers"`
+
+	// Interval is the time in milliseconds between two checks. Setting it to 0
+	// will use the default value of 5000 milliseconds.
+	Interval int `toml:"interval" yaml:"interval" json:"interval"`
+
+	// TargetLatency is the average time in milliseconds fetching a page is
+	// expected to take. Setting it to 0 will use the default value of 500
+	// milliseconds.
+	TargetLatency int `toml:"target-latency" yaml:"target-latency" json:"target-latency"`
+
+	// MaxErrorRate is the ratio of failed requests and 5xx responses above
+	// which the number of workers is halved. Setting it to 0 will use the
+	// default value of 0.1.
+	MaxErrorRate float64 `toml:"max-error-rate" yaml:"max-error-rate" json:"max-error-rate"`
+}
+
+// withDefaults returns the options with the unset values replaced by the
+// defaults.
+func (a AdaptiveWorkers) withDefaults(workerCount int) AdaptiveWorkers {
+	if a.MinWorkers == 0 {
+		a.MinWorkers = 1
+	}
+
+	if a.MaxWorkers == 0 {
+		a.MaxWorkers = 4 * workerCount
+	}
+
+	if a.Interval == 0 {
+		a.Interval = defaultAdaptiveInterval
+	}
+
+	if a.TargetLatency == 0 {
+		a.TargetLatency = defaultAdaptiveTargetLatency
+	}
+
+	if a.MaxErrorRate == 0 {
+		a.MaxErrorRate = defaultAdaptiveMaxErrorRate
+	}
+
+	return a
+}
+
+// validate checks the options with the defaults applied, using workerCount
+// as the worker count of the crawler.
+func (a AdaptiveWorkers) validate(workerCount int) error {
+	if a.MinWorkers < 0 || a.MaxWorkers < 0 || a.Interval < 0 || a.TargetLatency < 0 {
+		return fmt.Errorf("worker counts, interval and target latency must not be negative")
+	}
+
+	if d := a.withDefaults(workerCount); d.MinWorkers > d.MaxWorkers {
+		return fmt.Errorf("min workers (%d) is greater than max workers (%d)", d.MinWorkers, d.MaxWorkers)
+	}
+
+	if a.MaxErrorRate < 0 || a.MaxErrorRate > 1 {
+		return fmt.Errorf("max error rate must be between 0 and 1, got %v", a.MaxErrorRate)
+	}
+
+	return nil
+}
+
+// window summarizes the requests sent between two checks.
+type window struct {
+	requests  int64
+	errors    int64
+	throttled int64
+	latency   time.Duration
+}
+
+// signals collects the results the worker pool is resized by.
+type signals struct {
+	mu      sync.Mutex
+	w       window
+	fetched int64
+	total   time.Duration
+}
+
+// add records the result of a request.
+func (s *signals) add(r Result) {
+	if r.Cached {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.w.requests++
+
+	if r.Err != nil {
+		s.w.errors++
+		return
+	}
+
+	switch {
+	case r.Status == http.StatusTooManyRequests:
+		s.w.throttled++
+	case r.Status >= 500:
+		s.w.errors++
+	}
+
+	s.fetched++
+	s.total += r.Duration
+}
+
+// take returns the summary of the results added since the last call.
+func (s *signals) take() window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.w
+	if s.fetched > 0 {
+		w.latency = s.total / time.Duration(s.fetched)
+	}
+
+	s.w, s.fetched, s.total = window{}, 0, 0
+
+	return w
+}
+
+// next returns the number of workers to use instead of n, based on the
+// requests sent in the window.
+func (a AdaptiveWorkers) next(n int, w window) int {
+	target := time.Duration(a.TargetLatency) * time.Millisecond
+
+	switch {
+	case w.requests == 0:
+	case w.throttled > 0 || float64(w.errors)/float64(w.requests) > a.MaxErrorRate:
+		n /= 2
+	case w.latency > 2*target:
+		n--
+	case w.latency < target:
+		n++
+	}
+
+	if n < a.MinWorkers {
+		n = a.MinWorkers
+	}
+
+	if n > a.MaxWorkers {
+		n = a.MaxWorkers
+	}
+
+	return n
+}
+
+// adaptWorkers resizes the worker pool periodically until the crawler is
+// stopped. Nothing is changed while the crawl is paused.
+func (c *Crawler) adaptWorkers() {
+	opts := c.opts.AdaptiveWorkers
+
+	ticker := time.NewTicker(time.Duration(opts.Interval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		w := c.adaptive.take()
+
+		if c.Paused() {
+			continue
+		}
+
+		n := c.WorkerCount()
+		next := opts.next(n, w)
+		if next == n {
+			continue
+		}
+
+		c.logger.Info("resizing worker pool", "from", n, "to", next, "requests", w.requests,
+			"errors", w.errors, "throttled", w.throttled, "latency", w.latency)
+
+		if err := c.SetWorkerCount(next); err != nil {
+			c.logger.Debug("failed resizing worker pool", "error", err)
+		}
+	}
+}
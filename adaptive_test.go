@@ -0,0 +1,94 @@
+package brink
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveWorkers_next(t *testing.T) {
+	opts := AdaptiveWorkers{MinWorkers: 2, MaxWorkers: 10, TargetLatency: 100, MaxErrorRate: 0.1}
+
+	tests := []struct {
+		name string
+		n    int
+		w    window
+		want int
+	}{
+		{"no requests", 5, window{}, 5},
+		{"fast", 5, window{requests: 10, latency: 50 * time.Millisecond}, 6},
+		{"fast at max", 10, window{requests: 10, latency: 50 * time.Millisecond}, 10},
+		{"on target", 5, window{requests: 10, latency: 150 * time.Millisecond}, 5},
+		{"slow", 5, window{requests: 10, latency: 300 * time.Millisecond}, 4},
+		{"throttled", 8, window{requests: 10, throttled: 1, latency: 50 * time.Millisecond}, 4},
+		{"errors", 8, window{requests: 10, errors: 2, latency: 50 * time.Millisecond}, 4},
+		{"few errors", 8, window{requests: 10, errors: 1, latency: 50 * time.Millisecond}, 9},
+		{"throttled at min", 3, window{requests: 10, throttled: 5}, 2},
+		{"below min", 1, window{}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.next(tt.n, tt.w); got != tt.want {
+				t.Errorf("next() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_signals(t *testing.T) {
+	var s signals
+
+	s.add(Result{URL: "https://liferay.com/b", Status: 429, Duration: 100 * time.Millisecond})
+	s.add(Result{URL: "https://liferay.com/c", Status: 503, Duration: 300 * time.Millisecond})
+	s.add(Result{URL: "https://liferay.com/d", Err: fmt.Errorf("timeout")})
+	s.add(Result{URL: "https://liferay.com/a", Cached: true})
+
+	want := window{requests: 3, errors: 2, throttled: 1, latency: 200 * time.Millisecond}
+	if w := s.take(); w != want {
+		t.Errorf("take() = %+v, want %+v", w, want)
+	}
+
+	if w := s.take(); w != (window{}) {
+		t.Errorf("take() after take = %+v, want empty window", w)
+	}
+}
+
+func TestCrawler_adaptiveSignals(t *testing.T) {
+	c := testCrawler(t, "https://liferay.com", CrawlOptions{
+		AllowedDomains:  []string{"cdn.liferay.com"},
+		AdaptiveWorkers: AdaptiveWorkers{Enabled: true},
+	})
+
+	c.handleResult(Result{URL: "https://liferay.com/a", Status: 200, Duration: 100 * time.Millisecond})
+	c.handleResult(Result{URL: "https://cdn.liferay.com/a.js", Status: 503, Duration: 100 * time.Millisecond})
+	c.handleResult(Result{URL: "https://api.example.com/slow", Status: 429, Duration: 10 * time.Second})
+	c.handleResult(Result{URL: "https://down.example.com/", Err: fmt.Errorf("timeout")})
+
+	want := window{requests: 2, errors: 1, latency: 100 * time.Millisecond}
+	if w := c.adaptive.take(); w != want {
+		t.Errorf("take() = %+v, want only the results of the allowed domains %+v", w, want)
+	}
+}
+
+func TestAdaptiveWorkers_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    AdaptiveWorkers
+		wantErr bool
+	}{
+		{"zero", AdaptiveWorkers{}, false},
+		{"valid", AdaptiveWorkers{MinWorkers: 2, MaxWorkers: 4, MaxErrorRate: 0.5}, false},
+		{"min above max", AdaptiveWorkers{MinWorkers: 5, MaxWorkers: 4}, true},
+		{"min below default max", AdaptiveWorkers{MinWorkers: 40}, false},
+		{"min above default max", AdaptiveWorkers{MinWorkers: 41}, true},
+		{"negative", AdaptiveWorkers{Interval: -1}, true},
+		{"error rate", AdaptiveWorkers{MaxErrorRate: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(10); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package brink
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Severities of the audit findings
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+const defaultMaxPageSize = 256 * 1024
+
+// Finding is a problem found on a page by an audit rule.
+type Finding struct {
+	URL      string `json:"url"`
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+
+	// LinkedFrom is the page the link to URL was first found on, set by the
+	// content rules, e.g. the wiki page linking to a missing article.
+	LinkedFrom string `json:"linked_from,omitempty"`
+}
+
+// AuditRule checks the HTML pages fetched by the crawler. Check is called
+// concurrently by the workers with every page fetched with a 200 status.
+type AuditRule interface {
+	// Name identifies the rule, e.g. in the findings and the list of
+	// disabled rules.
+	Name() string
+
+	// Check returns the problems found on the page.
+	Check(p *Page, r Result) []Finding
+}
+
+// SiteAuditRule is an AuditRule which can only report some of its findings
+// once all the pages have been seen, e.g. pages sharing the same title.
+type SiteAuditRule interface {
+	AuditRule
+
+	// Finish returns the findings of the rule across all the pages checked
+	// so far. The link graph of the crawl is passed along.
+	Finish(g *LinkGraph) []Finding
+}
+
+// AuditOptions configures the audit of the fetched pages.
+type AuditOptions struct {
+	// Enabled turns on the built-in audit rules.
+	Enabled bool `toml:"enabled" yaml:"enabled" json:"enabled"`
+
+	// DisabledRules lists the names of the built-in rules not to run.
+	DisabledRules []string `toml:"disabled-rules" yaml:"disabled-rules" json:"disabled-rules"`
+
+	// MaxPageSize is the size in bytes above which the oversized-page rule reports a page.
+	// Setting it to 0 will use the default value of 256Kb.
+	MaxPageSize int64 `toml:"max-page-size" yaml:"max-page-size" json:"max-page-size"`
+
+	// ContentRules report the pages containing, or not containing, certain texts. They are
+	// run even if the built-in rules are not enabled.
+	ContentRules []ContentRule `toml:"content-rules" yaml:"content-rules" json:"content-rules"`
+}
+
+func (a AuditOptions) validate() error {
+	for _, name := range a.DisabledRules {
+		if !builtinAuditRule(name) {
+			return fmt.Errorf("unknown audit rule %q", name)
+		}
+	}
+
+	if a.MaxPageSize < 0 {
+		return fmt.Errorf("max page size must not be negative")
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range a.ContentRules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("content rule %d: %v", i, err)
+		}
+
+		if names[rule.Name] {
+			return fmt.Errorf("content rule %d: duplicate name %s", i, rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return nil
+}
+
+// auditor runs the audit rules and collects their findings.
+type auditor struct {
+	mu       sync.Mutex
+	rules    []AuditRule
+	findings []Finding
+}
+
+func newAuditor(rules []AuditRule) *auditor {
+	return &auditor{rules: rules}
+}
+
+func (a *auditor) add(rule AuditRule) {
+	a.mu.Lock()
+	a.rules = append(a.rules, rule)
+	a.mu.Unlock()
+}
+
+// check runs the rules on the page.
+func (a *auditor) check(p *Page, r Result) {
+	a.mu.Lock()
+	rules := a.rules
+	a.mu.Unlock()
+
+	var findings []Finding
+	for _, rule := range rules {
+		findings = append(findings, rule.Check(p, r)...)
+	}
+
+	a.mu.Lock()
+	a.findings = append(a.findings, findings...)
+	a.mu.Unlock()
+}
+
+// result returns the findings of all the rules sorted by url and rule.
+func (a *auditor) result(g *LinkGraph) []Finding {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	findings := append([]Finding(nil), a.findings...)
+
+	for _, rule := range a.rules {
+		if sr, ok := rule.(SiteAuditRule); ok {
+			findings = append(findings, sr.Finish(g)...)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].URL != findings[j].URL {
+			return findings[i].URL < findings[j].URL
+		}
+
+		return findings[i].Rule < findings[j].Rule
+	})
+
+	return findings
+}
+
+// AddAuditRule adds the rule to the ones checking the fetched pages. Adding
+// a rule turns on the audit even if it was not enabled in the CrawlOptions,
+// in which case only the added rules are run. It should be called before
+// Start.
+func (c *Crawler) AddAuditRule(rule AuditRule) {
+	if c.audit == nil {
+		c.audit = newAuditor(nil)
+	}
+
+	c.audit.add(rule)
+}
+
+// AuditFindings returns the findings of the audit rules, sorted by url. It
+// returns nil if auditing is not turned on. Findings of site-wide rules are
+// only complete once the crawl has finished.
+func (c *Crawler) AuditFindings() []Finding {
+	if c.audit == nil {
+		return nil
+	}
+
+	return c.audit.result(c.graph)
+}
+
+// auditPage runs the audit rules on the page if it is an HTML page.
+func (c *Crawler) auditPage(r Result, body []byte) {
+	if isHTML(r.ContentType, body) {
+		c.audit.check(NewPage(r.URL, body), r)
+	}
+}
@@ -0,0 +1,102 @@
+package brink
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var auditPages = map[string]string{
+	"/": `<html><head><title>Home</title><meta name="description" content="The home page"></head>
+<body><h1>Home</h1><a href="/a">A</a> <a href="/b">B</a> <a href="/hidden">Hidden</a></body></html>`,
+	"/a": `<html><head><title>Same</title><link rel="canonical" href="/b"></head>
+<body><h1>A</h1><h1>A again</h1><img src="/logo.png"><img src="/ok.png" alt="ok"></body></html>`,
+	"/b": `<html><head><title>Same</title><meta name="description" content="B"><link rel="canonical" href="/b"></head>
+<body><h1>B</h1></body></html>`,
+	"/hidden": `<html><head><title>Hidden</title><meta name="description" content="Hidden">
+<meta name="robots" content="noindex, follow"></head><body></body></html>`,
+}
+
+func TestCrawler_audit(t *testing.T) {
+	ts := testSite(auditPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{
+		Normalization: URLNormalization{TrailingSlash: TrailingSlashRemove},
+		Audit:         AuditOptions{Enabled: true, DisabledRules: []string{RuleMissingMetaDescription}},
+	})
+	c.HandleResultFunc(func(r Result) {})
+
+	waitDone(t, startAsync(t, c))
+
+	var got []string
+	for _, f := range c.AuditFindings() {
+		got = append(got, strings.TrimPrefix(f.URL, ts.URL)+" "+f.Rule)
+	}
+
+	want := []string{
+		"/a canonical-mismatch",
+		"/a duplicate-title",
+		"/a missing-alt-text",
+		"/a multiple-h1",
+		"/b duplicate-title",
+		"/hidden noindex-linked-internally",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditFindings() = %v, want %v", got, want)
+	}
+}
+
+func TestAuditOptions_validate(t *testing.T) {
+	if err := (AuditOptions{DisabledRules: []string{RuleMixedContent}}).validate(); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+
+	if err := (AuditOptions{DisabledRules: []string{"no-such-rule"}}).validate(); err == nil {
+		t.Errorf("validate() expected error for unknown rule")
+	}
+}
+
+func Test_mixedContent(t *testing.T) {
+	body := []byte(`<html><head><link rel="stylesheet" href="http://cdn.liferay.com/a.css"></head>
+<body><img src="//cdn.liferay.com/a.png"><script src="http://cdn.liferay.com/a.js"></script>
+<a href="http://liferay.com">plain links are fine</a></body></html>`)
+
+	r := Result{URL: "https://www.liferay.com/"}
+	if got := mixedContent(NewPage(r.URL, body), r); len(got) != 2 {
+		t.Errorf("mixedContent() = %v, want 2 findings", got)
+	}
+
+	r.URL = "http://www.liferay.com/"
+	if got := mixedContent(NewPage(r.URL, body), r); len(got) != 0 {
+		t.Errorf("mixedContent() on http page = %v, want none", got)
+	}
+}
+
+type countingRule struct{ n int }
+
+func (cr *countingRule) Name() string { return "counting" }
+
+func (cr *countingRule) Check(p *Page, r Result) []Finding {
+	cr.n++
+	return []Finding{{URL: r.URL, Rule: cr.Name(), Severity: SeverityInfo, Message: p.Title()}}
+}
+
+func TestCrawler_AddAuditRule(t *testing.T) {
+	ts := testSite(auditPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{WorkerCount: 1})
+	c.HandleResultFunc(func(r Result) {})
+
+	rule := &countingRule{}
+	c.AddAuditRule(rule)
+
+	waitDone(t, startAsync(t, c))
+
+	findings := c.AuditFindings()
+	if rule.n != 4 || len(findings) != 4 {
+		t.Errorf("custom rule ran %d times with %d findings, want 4", rule.n, len(findings))
+	}
+}
@@ -0,0 +1,347 @@
+package brink
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Names of the built-in audit rules
+const (
+	RuleMissingTitle             = "missing-title"
+	RuleDuplicateTitle           = "duplicate-title"
+	RuleMissingMetaDescription   = "missing-meta-description"
+	RuleDuplicateMetaDescription = "duplicate-meta-description"
+	RuleMultipleH1               = "multiple-h1"
+	RuleMissingAltText           = "missing-alt-text"
+	RuleCanonicalMismatch        = "canonical-mismatch"
+	RuleNoindexLinkedInternally  = "noindex-linked-internally"
+	RuleOversizedPage            = "oversized-page"
+	RuleMixedContent             = "mixed-content"
+)
+
+var builtinAuditRules = []string{
+	RuleMissingTitle,
+	RuleDuplicateTitle,
+	RuleMissingMetaDescription,
+	RuleDuplicateMetaDescription,
+	RuleMultipleH1,
+	RuleMissingAltText,
+	RuleCanonicalMismatch,
+	RuleNoindexLinkedInternally,
+	RuleOversizedPage,
+	RuleMixedContent,
+}
+
+func builtinAuditRule(name string) bool {
+	for _, n := range builtinAuditRules {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newBuiltinAuditRules returns the built-in rules which are not disabled
+// in the options.
+func (c *Crawler) newBuiltinAuditRules(opts AuditOptions) []AuditRule {
+	maxPageSize := opts.MaxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = defaultMaxPageSize
+	}
+
+	all := []AuditRule{
+		pageRule{RuleMissingTitle, missingTitle},
+		newDuplicateRule(RuleDuplicateTitle, "title", (*Page).Title),
+		pageRule{RuleMissingMetaDescription, missingMetaDescription},
+		newDuplicateRule(RuleDuplicateMetaDescription, "meta description", (*Page).MetaDescription),
+		pageRule{RuleMultipleH1, multipleH1},
+		pageRule{RuleMissingAltText, missingAltText},
+		canonicalRule{normalize: c.normalizeURL},
+		newNoindexRule(),
+		pageRule{RuleOversizedPage, oversizedPage(maxPageSize)},
+		pageRule{RuleMixedContent, mixedContent},
+	}
+
+	disabled := make(map[string]bool)
+	for _, name := range opts.DisabledRules {
+		disabled[name] = true
+	}
+
+	var rules []AuditRule
+	for _, rule := range all {
+		if !disabled[rule.Name()] {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// pageRule is an audit rule looking at one page at a time.
+type pageRule struct {
+	name  string
+	check func(p *Page, r Result) []Finding
+}
+
+func (pr pageRule) Name() string { return pr.name }
+
+func (pr pageRule) Check(p *Page, r Result) []Finding {
+	findings := pr.check(p, r)
+	for i := range findings {
+		findings[i].URL = r.URL
+		findings[i].Rule = pr.name
+	}
+
+	return findings
+}
+
+func missingTitle(p *Page, r Result) []Finding {
+	if p.Title() != "" {
+		return nil
+	}
+
+	return []Finding{{Severity: SeverityError, Message: "page has no title"}}
+}
+
+func missingMetaDescription(p *Page, r Result) []Finding {
+	if p.MetaDescription() != "" {
+		return nil
+	}
+
+	return []Finding{{Severity: SeverityWarning, Message: "page has no meta description"}}
+}
+
+func multipleH1(p *Page, r Result) []Finding {
+	var n int
+	for _, h := range p.Headings() {
+		if h.Level == 1 {
+			n++
+		}
+	}
+
+	if n <= 1 {
+		return nil
+	}
+
+	return []Finding{{Severity: SeverityWarning, Message: fmt.Sprintf("page has %d h1 headings", n)}}
+}
+
+func missingAltText(p *Page, r Result) []Finding {
+	images, err := p.Find("img:not([alt])")
+	if err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, img := range images {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("image %q has no alt text", NodeAttr(img, "src")),
+		})
+	}
+
+	return findings
+}
+
+func oversizedPage(max int64) func(p *Page, r Result) []Finding {
+	return func(p *Page, r Result) []Finding {
+		if r.Size <= max {
+			return nil
+		}
+
+		return []Finding{{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("page is %d bytes, more than %d", r.Size, max),
+		}}
+	}
+}
+
+// mixedContentSources holds the attributes pointing to resources that are
+// loaded along with the page, by element.
+var mixedContentSources = map[string]string{
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+	"audio":  "src",
+	"video":  "src",
+	"source": "src",
+	"embed":  "src",
+	"track":  "src",
+	"object": "data",
+}
+
+func mixedContent(p *Page, r Result) []Finding {
+	if !strings.HasPrefix(r.URL, "https:") {
+		return nil
+	}
+
+	var findings []Finding
+	p.walk(func(n *html.Node) bool {
+		attr, ok := mixedContentSources[n.Data]
+		if !ok && n.Data == "link" && hasRel(n, "stylesheet") {
+			attr, ok = "href", true
+		}
+
+		if !ok {
+			return false
+		}
+
+		if src := p.resolve(NodeAttr(n, attr)); strings.HasPrefix(src, "http:") {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s loads insecure resource %s", n.Data, src),
+			})
+		}
+
+		return false
+	})
+
+	return findings
+}
+
+// canonicalRule reports pages whose canonical url points elsewhere.
+type canonicalRule struct {
+	normalize func(string) (string, error)
+}
+
+func (canonicalRule) Name() string { return RuleCanonicalMismatch }
+
+func (cr canonicalRule) Check(p *Page, r Result) []Finding {
+	canonical := p.Canonical()
+	if canonical == "" {
+		return nil
+	}
+
+	if normalized, err := cr.normalize(canonical); err == nil {
+		canonical = normalized
+	}
+
+	if canonical == r.URL {
+		return nil
+	}
+
+	return []Finding{{
+		URL:      r.URL,
+		Rule:     RuleCanonicalMismatch,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("canonical url is %s", canonical),
+	}}
+}
+
+// duplicateRule reports pages sharing the same non-empty value, e.g. title.
+type duplicateRule struct {
+	name  string
+	what  string
+	value func(p *Page) string
+
+	mu    sync.Mutex
+	pages map[string][]string
+}
+
+func newDuplicateRule(name, what string, value func(p *Page) string) *duplicateRule {
+	return &duplicateRule{
+		name:  name,
+		what:  what,
+		value: value,
+		pages: make(map[string][]string),
+	}
+}
+
+func (dr *duplicateRule) Name() string { return dr.name }
+
+func (dr *duplicateRule) Check(p *Page, r Result) []Finding {
+	v := dr.value(p)
+	if v == "" {
+		return nil
+	}
+
+	dr.mu.Lock()
+	dr.pages[v] = append(dr.pages[v], r.URL)
+	dr.mu.Unlock()
+
+	return nil
+}
+
+func (dr *duplicateRule) Finish(g *LinkGraph) []Finding {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	var findings []Finding
+	for v, urls := range dr.pages {
+		if len(urls) < 2 {
+			continue
+		}
+
+		sorted := append([]string(nil), urls...)
+		sort.Strings(sorted)
+
+		for _, u := range sorted {
+			findings = append(findings, Finding{
+				URL:      u,
+				Rule:     dr.name,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("%s %q is shared by %d pages", dr.what, v, len(sorted)),
+			})
+		}
+	}
+
+	return findings
+}
+
+// noindexRule reports pages excluded from indexing by their robots meta tag
+// or X-Robots-Tag header which are linked from other crawled pages.
+type noindexRule struct {
+	mu    sync.Mutex
+	pages []string
+}
+
+func newNoindexRule() *noindexRule {
+	return &noindexRule{}
+}
+
+func (*noindexRule) Name() string { return RuleNoindexLinkedInternally }
+
+func (nr *noindexRule) Check(p *Page, r Result) []Finding {
+	if r.NoIndex {
+		nr.mu.Lock()
+		nr.pages = append(nr.pages, r.URL)
+		nr.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (nr *noindexRule) Finish(g *LinkGraph) []Finding {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+
+	var findings []Finding
+	for _, u := range nr.pages {
+		var referrers []string
+		seen := make(map[string]bool)
+		for _, e := range g.Referrers(u) {
+			if e.Source != u && !seen[e.Source] {
+				seen[e.Source] = true
+				referrers = append(referrers, e.Source)
+			}
+		}
+
+		if len(referrers) == 0 {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			URL:      u,
+			Rule:     RuleNoindexLinkedInternally,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("noindex page is linked from %d pages, e.g. %s", len(referrers), referrers[0]),
+		})
+	}
+
+	return findings
+}
@@ -2,12 +2,12 @@ package brink
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -15,137 +15,352 @@ import (
 // links and then visit each of them, provided the domains are allowed. It will keep
 // repeating this process on each page until it runs out of pages to visit.
 //
-// Start requires at least one handler to be registered, otherwise errors out.
+// Start requires at least one handler or a result handler to be registered,
+// otherwise errors out.
 func (c *Crawler) Start() error {
 	// Prefetch checks
 	if c.RootDomain == "" {
 		return fmt.Errorf("root domain not specified")
 	}
 
-	if c.defaultHandler == nil && len(c.handlers) == 0 {
+	if c.defaultHandler == nil && len(c.handlers) == 0 && c.resultHandler == nil {
 		return fmt.Errorf("no handlers specified")
 	}
 
+	c.stats.start()
+
 	// Spawn workers
-	var wg sync.WaitGroup
-	c.spawnWorkers(&wg)
+	c.wmu.Lock()
+	c.started = true
+	for i := 0; i < c.opts.WorkerCount; i++ {
+		c.spawnWorker()
+	}
+	c.wmu.Unlock()
 
-	c.urls <- Link{LinkedFrom: "start", Href: c.RootDomain}
+	c.urls <- Link{LinkedFrom: seedReferrer, Href: c.RootDomain}
+	for _, s := range c.opts.Seeds {
+		c.urls <- s.link()
+	}
+
+	if c.opts.AdaptiveWorkers.Enabled {
+		go c.adaptWorkers()
+	}
 
 	// Spawn checker
 	go func() {
 		interval := time.Duration(c.opts.IdleWorkCheckInterval)
 
-	ticker:
-		for range time.Tick(interval * time.Millisecond) {
-			for _, running := range c.workersRunning {
-				if *running == true {
-					continue ticker
-				}
+		ticker := time.NewTicker(interval * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
 			}
 
-			log.Println("No urls to parse, exiting.")
-			c.Stop()
-			break ticker
+			if c.idle() {
+				c.logger.Info("no urls to parse, exiting")
+				c.Stop()
+				return
+			}
 		}
 	}()
 
-	wg.Wait()
+	c.wg.Wait()
+	c.closeRenderer()
 
 	return nil
 }
 
-func (c *Crawler) spawnWorkers(wg *sync.WaitGroup) {
-	wg.Add(c.opts.WorkerCount)
+// cached calls the handlers with the result marked as cached if the
+// request identified by the key has already been sent, and reports whether
+// it has.
+func (c *Crawler) cached(key string, result Result) bool {
+	st, ok := c.visitedURLs.Load(key)
+	if !ok {
+		return false
+	}
 
-	for i := 0; i < c.opts.WorkerCount; i++ {
-		name := fmt.Sprintf("worker-%d", i+1)
-		log.Printf("Spawning %s", name)
+	result.Status, _ = strconv.Atoi(st)
+	result.Cached = true
 
-		running := false
+	c.handle(result, nil)
 
-		c.workersRunning[i] = &running
+	return true
+}
 
-		go func(name string, running *bool) {
-			defer wg.Done()
+// claimUnfollowed reports whether the request is followable and leads to
+// a page whose links have not been followed, because it was only reached
+// through nofollow links or check-only requests, and whether the page is
+// yet to be audited. Only the first caller claims the page.
+func (c *Crawler) claimUnfollowed(key string, req *Request) (claimed, unaudited bool) {
+	if req.CheckOnly || req.Link.NoFollow && !c.opts.IgnoreRobotsDirectives {
+		return false, false
+	}
 
-		loop:
-			for link := range c.urls {
-				*running = true
-				_url, err := c.normalizeURL(link.Href)
-				if err != nil {
-					// Debug..
-					log.Printf("%s: failed normalize: %v", name, err)
-					*running = false
-					continue
-				}
+	v, ok := c.unfollowed.LoadAndDelete(key)
+	if !ok {
+		return false, false
+	}
 
-				if st, ok := c.visitedURLs.Load(_url); ok {
-					st, _ := strconv.Atoi(st)
-					if f, ok := c.handlers[st]; ok {
-						f(link.LinkedFrom, _url, st, "", true)
-					} else {
-						c.defaultHandler(link.LinkedFrom, _url, st, "", true)
-					}
+	return true, v.(bool)
+}
 
-					*running = false
-					continue
-				}
+// visit fetches the link, calls the handlers with the outcome, and returns
+// the links found on the page that should be visited next.
+func (c *Crawler) visit(name string, link Link) []Link {
+	_url, err := c.normalizeURL(link.Href)
+	if err != nil {
+		c.logger.Debug("failed normalizing url", "worker", name, "url", link.Href, "error", err)
+		return nil
+	}
 
-				st, bod, err := c.Fetch(_url)
-				if err != nil {
-					// Debug..
-					//log.Printf("%s: failed fetch: %v", name, err)
-					*running = false
-					continue
-				}
+	req, err := c.newRequest(_url, link)
+	if err != nil {
+		c.logger.Debug("request skipped by middleware", "worker", name, "url", _url, "error", err)
+		return nil
+	}
+	_url, method, key := req.URL, req.Method, req.key()
 
-				c.visitedURLs.Store(_url, strconv.Itoa(st))
+	if link.LinkedFrom != seedReferrer {
+		c.graph.add(Edge{Source: link.LinkedFrom, Target: _url, Text: link.Text, Kind: link.Kind})
+	}
 
-				if f, ok := c.handlers[st]; ok {
-					f(link.LinkedFrom, _url, st, string(bod), false)
-				} else {
-					c.defaultHandler(link.LinkedFrom, _url, st, string(bod), false)
-				}
+	result := Result{
+		URL:        _url,
+		Method:     method,
+		Body:       req.Body,
+		LinkedFrom: link.LinkedFrom,
+		Depth:      link.Depth,
+	}
 
-				if st != http.StatusOK || pathForbidden(c, _url) {
-					*running = false
-					continue
-				}
+	// Pages visited only through nofollow links or check-only requests so
+	// far are fetched again to follow their links.
+	refollow, unaudited := c.claimUnfollowed(key, req)
+	if !refollow && c.cached(key, result) {
+		return nil
+	}
 
-				// Parse links and send them all to the urls channel
-				links, err := AbsoluteLinksIn(link.Href, link.Href, bod, true)
-				if err != nil {
-					log.Printf("err in AbsLinksIn: %v", err)
-					*running = false
-					continue
-				}
+	// If another worker is fetching the same url, wait for it to finish
+	// instead of fetching it a second time.
+	ch := make(chan struct{})
+	for {
+		other, loaded := c.inflight.LoadOrStore(key, ch)
+		if !loaded {
+			break
+		}
+		<-other.(chan struct{})
 
-				for _, l := range links {
-					if l.Href == "" {
-						*running = false
-						continue
-					}
+		if !refollow {
+			if refollow, unaudited = c.claimUnfollowed(key, req); !refollow {
+				c.cached(key, result)
+				return nil
+			}
+		}
+	}
+	defer func() {
+		c.inflight.Delete(key)
+		close(ch)
+	}()
 
-					if c.stopping {
-						break loop
-					}
+	reported := result
 
-					c.urls <- l
-				}
-				*running = false
-				//log.Printf("%s: count: %d, linkCount: %d", name, count, lc)
+	resp, err := c.fetch(req)
+	if resp != nil {
+		result.Status = resp.status
+		result.ContentType = resp.header.Get("Content-Type")
+		result.Size = resp.size
+		result.Duration = resp.duration
+	}
+
+	switch err.(type) {
+	case nil, NotAllowed, ContentTooLarge:
+	default:
+		c.logger.Debug("failed fetching url", "worker", name, "url", _url, "error", err)
+		result.Err = err
+		c.handleResult(result)
+		return nil
+	}
+
+	c.visitedURLs.Store(key, strconv.Itoa(result.Status))
+	c.logger.Debug("visited url", "worker", name, "method", method, "url", _url, "status", result.Status, "duration", result.Duration)
+
+	var bod []byte
+	if err == nil {
+		bod = resp.body
+		c.stats.addDownloaded(resp.size)
+
+		d := robotsDirectivesOf(resp)
+		result.NoIndex, result.NoFollow = d.noIndex, d.noFollow
+	}
+
+	if refollow {
+		// The page was reported when it was visited through the nofollow
+		// link, only its links are new.
+		c.cached(key, reported)
+	} else {
+		c.handle(result, bod)
+
+		if c.fragments != nil && err == nil && result.Status == http.StatusOK && method == http.MethodGet {
+			c.fragments.addPage(_url, bod)
+		}
+	}
+
+	if c.audit != nil && err == nil && result.Status == http.StatusOK && !req.CheckOnly && (!refollow || unaudited) {
+		c.auditPage(result, bod)
+	}
+
+	if err != nil || result.Status != http.StatusOK || pathForbidden(c, _url) {
+		return nil
+	}
+
+	// Pages reached through nofollow links or check-only requests are only
+	// checked for their status until they are reached through a followable
+	// link. Check-only pages are yet to be audited then.
+	if req.CheckOnly || link.NoFollow && !c.opts.IgnoreRobotsDirectives {
+		c.unfollowed.Store(key, req.CheckOnly)
+		return nil
+	}
+
+	if c.opts.Normalization.HonorCanonical && !c.firstCanonical(_url, bod) {
+		return nil
+	}
+
+	if c.duplicates != nil && isHTML(result.ContentType, bod) && c.duplicates.add(_url, FingerprintOf(bod)) && c.opts.Duplicates.SkipLinks {
+		return nil
+	}
+
+	// Parse links and send them all to the urls channel
+	links, err := c.parse(_url, result.ContentType, bod)
+	if err != nil {
+		c.logger.Warn("failed parsing links", "worker", name, "url", _url, "error", err)
+		return nil
+	}
+
+	if c.opts.JSON.FollowLinkHeaders {
+		links = append(links, nextLinksIn(_url, resp.header)...)
+	}
+
+	next := make([]Link, 0, len(links))
+	for _, l := range links {
+		if l.Href == "" {
+			continue
+		}
+
+		if c.fragments != nil && !c.checkFragment(_url, l) {
+			continue
+		}
+
+		l.Depth = link.Depth + 1
+		l.NoFollow = l.NoFollow || result.NoFollow
+		next = append(next, l)
+	}
+
+	if c.forms != nil && isHTML(result.ContentType, bod) {
+		for _, f := range c.forms.add(FormsIn(_url, bod)) {
+			for _, l := range c.formLinks(f) {
+				l.Depth = link.Depth + 1
+				l.NoFollow = result.NoFollow
+				next = append(next, l)
 			}
-			*running = false
-		}(name, &running)
+		}
 	}
+
+	return next
 }
 
-// Stop attempts to stop the crawler.
+// handle calls the handler registered for the status of the result, or the
+// default handler if there is none, followed by the result handler.
+func (c *Crawler) handle(result Result, body []byte) {
+	if f, ok := c.handlers[result.Status]; ok {
+		f(result.LinkedFrom, result.URL, result.Status, string(body), result.Cached)
+	} else if c.defaultHandler != nil {
+		c.defaultHandler(result.LinkedFrom, result.URL, result.Status, string(body), result.Cached)
+	}
+
+	c.handleResult(result)
+}
+
+func (c *Crawler) handleResult(result Result) {
+	c.stats.add(result)
+
+	if c.adaptive != nil && c.domainAllowed(result.URL) {
+		c.adaptive.add(result)
+	}
+
+	if c.resultHandler != nil {
+		c.resultHandler(result)
+	}
+}
+
+// Stop attempts to stop the crawler. Running visits are finished, but the
+// links waiting to be visited are not. Paused crawlers are stopped as well.
+// Subsequent calls to Stop are no-ops.
 func (c *Crawler) Stop() {
-	log.Println("Received signal to stop... Will finish cached runs.")
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	if c.stopping {
+		return
+	}
+
+	c.logger.Info("received signal to stop, finishing running visits")
 	c.stopping = true
-	close(c.urls)
+	c.pcond.Broadcast()
+	close(c.done)
+}
+
+// Pause halts the workers once they finish their current visits. The links
+// waiting to be visited are kept until the crawl is resumed by Resume.
+func (c *Crawler) Pause() {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	if c.paused || c.stopping {
+		return
+	}
+
+	c.logger.Info("pausing crawl")
+	c.paused = true
+}
+
+// Resume continues a crawl paused by Pause.
+func (c *Crawler) Resume() {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	if !c.paused {
+		return
+	}
+
+	c.logger.Info("resuming crawl")
+	c.paused = false
+	c.pcond.Broadcast()
+}
+
+// Paused reports whether the crawl is paused.
+func (c *Crawler) Paused() bool {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	return c.paused
+}
+
+func (c *Crawler) waitWhilePaused() {
+	c.pmu.Lock()
+	for c.paused && !c.stopping {
+		c.pcond.Wait()
+	}
+	c.pmu.Unlock()
+}
+
+func (c *Crawler) isStopping() bool {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+
+	return c.stopping
 }
 
 // AllowDomains instructs the crawler which domains it is allowed
@@ -153,24 +368,93 @@ func (c *Crawler) Stop() {
 // Domains not allowed will be checked for http status, but will
 // not be traversed.
 //
+// Domains have the form of [scheme://][*.]host[:port]. If the scheme
+// is left out, any scheme is allowed. If the port is left out, only
+// the default port of the scheme is allowed. Prefixing the host with
+// "*." allows the host and all of its subdomains. Invalid domains
+// are logged and skipped.
+//
 // Subsequent calls to AllowDomains adds to the list of domains
 // allowed to the crawler to traverse.
 func (c *Crawler) AllowDomains(domains ...string) {
 	for _, domain := range domains {
-		c.allowedDomains.StoreKey(domain)
+		if err := c.allowDomain(domain); err != nil {
+			c.logger.Warn("failed allowing domain", "domain", domain, "error", err)
+		}
 	}
 }
 
+func (c *Crawler) allowDomain(domain string) error {
+	rule, err := parseDomainRule(domain)
+	if err != nil {
+		return err
+	}
+
+	if c.allowedDomains.Contains(rule.String()) {
+		return nil
+	}
+
+	c.dmu.Lock()
+	c.domainRules = append(c.domainRules, rule)
+	c.dmu.Unlock()
+
+	c.allowedDomains.StoreKey(rule.String())
+
+	return nil
+}
+
 // Fetch fetches the URL and returns its status, body and/or any errors it
-// encountered.
+// encountered. If a renderer is set, the body of HTML pages is the one
+// returned by the renderer.
 func (c *Crawler) Fetch(url string) (status int, body []byte, err error) {
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest(url, Link{Href: url})
+	if err != nil {
+		return 0, nil, fmt.Errorf("request middleware: %v", err)
+	}
+
+	resp, err := c.fetch(req)
+	if resp == nil {
+		return 0, nil, err
+	}
+
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed creating new request: %v", err)
+		return resp.status, nil, err
 	}
 
+	return resp.status, resp.body, nil
+}
+
+// response holds the parts of an http response that the crawler is
+// interested in.
+type response struct {
+	status   int
+	header   http.Header
+	body     []byte
+	size     int64
+	duration time.Duration
+}
+
+// fetch sends the request. If the URL is not allowed or its content is too
+// large, the returned response contains everything but the body along with
+// the error.
+func (c *Crawler) fetch(request *Request) (*response, error) {
+	method, url := request.Method, request.URL
+
+	var reqBody io.Reader
+	if request.Body != "" {
+		reqBody = strings.NewReader(request.Body)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating new request: %v", err)
+	}
+
+	allowed := c.domainAllowed(url)
+	profile := c.profileFor(req.URL)
+
 	// Add cookies
-	reqCookies := c.cookies()
+	reqCookies := c.cookies(req.URL.Hostname(), allowed)
 	if len(reqCookies) != 0 {
 		for _, cookie := range reqCookies {
 			req.AddCookie(cookie)
@@ -184,48 +468,107 @@ func (c *Crawler) Fetch(url string) (status int, body []byte, err error) {
 		}
 	}
 
-	// Add headers
-	if c.reqHeaders.Size() != 0 {
+	if c.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+
+	// Add headers, which may hold credentials, so only for the allowed
+	// domains
+	if allowed && c.reqHeaders.Size() != 0 {
 		for key, value := range c.reqHeaders.ToMap() {
 			req.Header.Add(key, value)
 		}
 	}
 
-	resp, err := c.client.Do(req)
+	// Headers of the profile replace the global ones
+	if profile != nil {
+		if profile.userAgent != "" {
+			req.Header.Set("User-Agent", profile.userAgent)
+		}
+
+		for key, values := range profile.header {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+
+	// Headers of the request replace the ones set for all requests
+	for key, values := range request.Header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if request.Body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentTypeOf(request.Body))
+	}
+
+	client := c.client
+	if profile != nil && profile.client != nil {
+		client = profile.client
+	}
+
+	if l := c.limiterFor(req.URL.Host, profile); l != nil && !l.wait(c.done) {
+		return nil, fmt.Errorf("crawler stopped")
+	}
+
+	start := time.Now()
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return 0, nil, fmt.Errorf("get failed: %v", err)
+		return nil, fmt.Errorf("%s failed: %v", strings.ToLower(method), err)
 	}
 	defer resp.Body.Close()
 
 	// Add response cookies
 	respCookies := resp.Cookies()
-	if len(respCookies) != 0 {
-		c.addCookies(respCookies)
+	if len(respCookies) != 0 && allowed {
+		c.addCookies(req.URL.Hostname(), respCookies)
 	}
 
 	scheme, host, err := schemeAndHost(url)
 	if err != nil {
-		return 0, nil, fmt.Errorf("malformed url: %v", err)
+		return nil, fmt.Errorf("malformed url: %v", err)
+	}
+
+	r := response{
+		status:   resp.StatusCode,
+		header:   resp.Header,
+		size:     resp.ContentLength,
+		duration: time.Since(start),
 	}
 
-	domain := fmt.Sprintf("%s://%s", scheme, host)
 	// if URL is not allowed, return with only its status code
-	if !c.domainAllowed(domain) {
-		return resp.StatusCode, nil, NotAllowed{domain}
+	if !allowed {
+		return &r, NotAllowed{fmt.Sprintf("%s://%s", scheme, host)}
 	}
 
 	// if response size is too large (or unknown), return early with
 	// only the status code
 	if resp.ContentLength > c.opts.MaxContentLength {
-		return resp.StatusCode, nil, ContentTooLarge{url}
+		return &r, ContentTooLarge{url}
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed reading response body: %v", err)
+		return nil, fmt.Errorf("failed reading response body: %v", err)
+	}
+
+	r.body = b
+	r.size = int64(len(b))
+
+	if method == http.MethodGet && c.shouldRender(url, r.status, resp.Header.Get("Content-Type"), b) {
+		rendered, err := c.render(req)
+		if err != nil {
+			c.logger.Warn("failed rendering page", "url", url, "error", err)
+		} else {
+			r.body = rendered
+		}
 	}
 
-	return resp.StatusCode, b, nil
+	r.duration = time.Since(start)
+
+	return &r, nil
 }
 
 // HandleDefaultFunc will be called for all pages returned by a status
@@ -236,6 +579,15 @@ func (c *Crawler) HandleDefaultFunc(h func(linkedFrom string, url string, status
 	c.defaultHandler = h
 }
 
+// HandleResultFunc registers a function to be called with the Result of
+// every visit, including the ones that failed or were served from the
+// list of already visited URLs. It is called after the handlers registered
+// by HandleFunc or HandleDefaultFunc. Subsequent calls to HandleResultFunc
+// will overwrite the previously set function, if any.
+func (c *Crawler) HandleResultFunc(h func(r Result)) {
+	c.resultHandler = h
+}
+
 // HandleFunc is used to register a function to be called when a new page is
 // found with the specified status. Subsequent calls to register functions
 // to the same statuses will silently overwrite previously set handlers, if any.
@@ -243,32 +595,160 @@ func (c *Crawler) HandleFunc(status int, h func(linkedFrom string, url string, s
 	c.handlers[status] = h
 }
 
+// BrokenFragments returns the links pointing to fragments (e.g. "#section")
+// that do not exist on their target pages. Only links to pages that have
+// been fetched are validated. It returns nil if fragment validation is not
+// enabled in the CrawlOptions.
+func (c *Crawler) BrokenFragments() []BrokenFragment {
+	if c.fragments == nil {
+		return nil
+	}
+
+	return c.fragments.broken()
+}
+
+// checkFragment records the fragment of the link found on the source page
+// to be validated later. It reports whether the link should be visited,
+// which is false for in-page links.
+func (c *Crawler) checkFragment(source string, l Link) bool {
+	base, fragment, ok := splitFragment(l.Href)
+	if !ok {
+		return true
+	}
+
+	if base == "" {
+		c.fragments.addRef(source, source, fragment, l.Text)
+		return false
+	}
+
+	target, err := c.normalizeURL(base)
+	if err != nil {
+		return true
+	}
+
+	c.fragments.addRef(source, target, fragment, l.Text)
+
+	return true
+}
+
+// Stats returns a snapshot of the statistics of the crawl. It is safe
+// to call while the crawler is running.
+func (c *Crawler) Stats() Stats {
+	s := c.stats.snapshot()
+	s.FrontierSize = len(c.urls)
+
+	return s
+}
+
+// SetLogger sets the logger the crawler logs its progress through. Passing
+// nil discards the logs, which is the default.
+func (c *Crawler) SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+
+	c.logger = l
+}
+
+// Graph returns the graph of all the links found so far, including the ones
+// pointing to already visited URLs.
+func (c *Crawler) Graph() *LinkGraph {
+	return c.graph
+}
+
+// DuplicateGroups returns the pages found to have the same or nearly the same
+// content as a page visited earlier, grouped by the earlier page. It returns
+// nil if duplicate detection is not enabled in the CrawlOptions.
+func (c *Crawler) DuplicateGroups() []DuplicateGroup {
+	if c.duplicates == nil {
+		return nil
+	}
+
+	return c.duplicates.report()
+}
+
+// firstCanonical reports whether the page is the first one to be visited
+// among the pages sharing its canonical url.
+func (c *Crawler) firstCanonical(_url string, body []byte) bool {
+	canonical := _url
+
+	if href := CanonicalIn(body); href != "" {
+		abs, err := resolveURL(_url, href)
+		if err == nil {
+			abs, err = c.normalizeURL(abs)
+		}
+
+		if err != nil {
+			c.logger.Debug("failed resolving canonical url", "url", _url, "canonical", href, "error", err)
+		} else {
+			canonical = abs
+		}
+	}
+
+	_, loaded := c.canonicalURLs.LoadOrStore(canonical, _url)
+
+	return !loaded
+}
+
 func (c *Crawler) seenURL(url string) bool {
 	return c.visitedURLs.Contains(url)
 }
 
-func (c *Crawler) domainAllowed(domain string) bool {
-	_, ok := c.allowedDomains.Load(domain)
+func (c *Crawler) domainAllowed(_url string) bool {
+	u, err := url.ParseRequestURI(_url)
+	if err != nil {
+		return false
+	}
+
+	c.dmu.RLock()
+	defer c.dmu.RUnlock()
+
+	for _, rule := range c.domainRules {
+		if rule.matches(u) {
+			return true
+		}
+	}
 
-	return ok
+	return false
 }
 
-func (c *Crawler) cookies() (cks []*http.Cookie) {
+// cookies returns the cookies to be sent to the host: the ones whose
+// Domain matches it, and the ones without a Domain if the host is allowed.
+func (c *Crawler) cookies(host string, allowed bool) (cks []*http.Cookie) {
 	c.cmu.RLock()
 	defer c.cmu.RUnlock()
 
 	for _, cookie := range c.opts.Cookies {
+		if cookie.Domain == "" && !allowed || cookie.Domain != "" && !cookieDomainMatches(host, cookie.Domain) {
+			continue
+		}
+
 		cks = append(cks, cookie)
 	}
 
 	return cks
 }
 
-func (c *Crawler) addCookies(cookies []*http.Cookie) {
+// addCookies adds the cookies set by the host, which should be an allowed
+// one. Cookies for domains the host does not belong to are ignored.
+func (c *Crawler) addCookies(host string, cookies []*http.Cookie) {
 	c.cmu.Lock()
 	defer c.cmu.Unlock()
 
 	for _, newCookie := range cookies {
+		if newCookie.Domain != "" && !cookieDomainMatches(host, newCookie.Domain) {
+			continue
+		}
+
 		c.opts.Cookies[newCookie.Name] = newCookie
 	}
 }
+
+// cookieDomainMatches reports whether the host is the domain of a cookie
+// or one of its subdomains.
+func cookieDomainMatches(host, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
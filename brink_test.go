@@ -0,0 +1,240 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSite serves a small site of linked pages. The pages map holds the
+// bodies by their paths. Unknown paths return 404.
+func testSite(pages map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func testCrawler(t *testing.T, rootDomain string, opts CrawlOptions) *Crawler {
+	if opts.IdleWorkCheckInterval == 0 {
+		opts.IdleWorkCheckInterval = 20
+	}
+
+	c, err := NewCrawlerWithOpts(rootDomain, opts)
+	if err != nil {
+		t.Fatalf("NewCrawlerWithOpts() error = %v", err)
+	}
+
+	return c
+}
+
+// startAsync starts the crawler in the background, and returns a channel
+// which is closed once Start returns.
+func startAsync(t *testing.T, c *Crawler) chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if err := c.Start(); err != nil {
+			t.Errorf("Start() error = %v", err)
+		}
+	}()
+
+	return done
+}
+
+func waitDone(t *testing.T, done chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("crawl did not finish in time")
+	}
+}
+
+var testPages = map[string]string{
+	"/":  `<html><body><a href="/a">A</a> <a href="/b">B</a></body></html>`,
+	"/a": `<html><body><a href="/b">B again</a> <a href="/missing">Missing</a></body></html>`,
+	"/b": `<html><body><a href="/">Home</a></body></html>`,
+}
+
+func TestCrawler_Start(t *testing.T) {
+	ts := testSite(testPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{
+		WorkerCount:   2,
+		Normalization: URLNormalization{TrailingSlash: TrailingSlashRemove},
+	})
+
+	var (
+		mu      sync.Mutex
+		results = make(map[string]Result)
+	)
+	c.HandleResultFunc(func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !r.Cached {
+			results[r.URL] = r
+		}
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	if len(results) != 4 {
+		t.Fatalf("expected 4 visited urls, got %d: %v", len(results), results)
+	}
+
+	if r := results[ts.URL+"/missing"]; r.Status != http.StatusNotFound || r.Depth != 2 {
+		t.Errorf("unexpected result of missing page: %+v", r)
+	}
+
+	if r := results[ts.URL+"/a"]; r.ContentType != "text/html; charset=utf-8" || r.Size == 0 {
+		t.Errorf("unexpected result of page a: %+v", r)
+	}
+
+	referrers := c.Graph().Referrers(ts.URL + "/b")
+	if len(referrers) != 2 {
+		t.Errorf("expected 2 referrers of page b, got %v", referrers)
+	}
+
+	if s := c.Stats(); s.PagesFetched != 4 || s.StatusCounts[http.StatusNotFound] != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestCrawler_PauseResume(t *testing.T) {
+	ts := testSite(testPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{WorkerCount: 1})
+
+	var once sync.Once
+	paused := make(chan struct{})
+	c.HandleResultFunc(func(r Result) {
+		once.Do(func() {
+			c.Pause()
+			close(paused)
+		})
+	})
+
+	done := startAsync(t, c)
+	<-paused
+
+	// Wait for a few idle checks to make sure the paused crawl is not
+	// considered finished.
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case <-done:
+		t.Fatalf("paused crawl finished")
+	default:
+	}
+
+	if !c.Paused() {
+		t.Errorf("Paused() = false")
+	}
+
+	if s := c.Stats(); s.PagesFetched != 1 {
+		t.Errorf("pages fetched while paused: %d", s.PagesFetched)
+	}
+
+	c.Resume()
+	waitDone(t, done)
+
+	// The root is visited both with and without the trailing slash
+	if s := c.Stats(); s.PagesFetched != 5 {
+		t.Errorf("PagesFetched = %d after resume, want 5", s.PagesFetched)
+	}
+}
+
+func TestCrawler_StopWhilePaused(t *testing.T) {
+	ts := testSite(testPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{WorkerCount: 1})
+
+	var once sync.Once
+	paused := make(chan struct{})
+	c.HandleResultFunc(func(r Result) {
+		once.Do(func() {
+			c.Pause()
+			close(paused)
+		})
+	})
+
+	done := startAsync(t, c)
+	<-paused
+
+	c.Stop()
+	c.Stop()
+	waitDone(t, done)
+}
+
+func TestCrawler_SetWorkerCount(t *testing.T) {
+	ts := testSite(testPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{WorkerCount: 2})
+
+	if err := c.SetWorkerCount(0); err == nil {
+		t.Errorf("SetWorkerCount(0) expected error")
+	}
+
+	if err := c.SetWorkerCount(3); err != nil || c.WorkerCount() != 3 {
+		t.Errorf("SetWorkerCount(3) before start: err = %v, count = %d", err, c.WorkerCount())
+	}
+
+	var once sync.Once
+	paused := make(chan struct{})
+	c.HandleResultFunc(func(r Result) {
+		once.Do(func() {
+			c.Pause()
+			close(paused)
+		})
+	})
+
+	done := startAsync(t, c)
+	<-paused
+
+	if got := c.WorkerCount(); got != 3 {
+		t.Errorf("WorkerCount() = %d after start, want 3", got)
+	}
+
+	if err := c.SetWorkerCount(5); err != nil || c.WorkerCount() != 5 {
+		t.Errorf("SetWorkerCount(5): err = %v, count = %d", err, c.WorkerCount())
+	}
+
+	if err := c.SetWorkerCount(1); err != nil || c.WorkerCount() != 1 {
+		t.Errorf("SetWorkerCount(1): err = %v, count = %d", err, c.WorkerCount())
+	}
+
+	if err := c.AddSeeds(ts.URL + "/unlinked"); err != nil {
+		t.Errorf("AddSeeds() error = %v", err)
+	}
+
+	if err := c.AddSeeds("/relative"); err == nil {
+		t.Errorf("AddSeeds() expected error for relative url")
+	}
+
+	c.Resume()
+	waitDone(t, done)
+
+	if s := c.Stats(); s.StatusCounts[http.StatusNotFound] != 2 {
+		t.Errorf("seed was not visited: %+v", s.StatusCounts)
+	}
+
+	if err := c.SetWorkerCount(2); err == nil {
+		t.Errorf("SetWorkerCount() expected error after stop")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/djavorszky/brink"
+	"github.com/djavorszky/brink/report"
+)
+
+const checkDoc = `Check fetches the page of the url and the links found on it, without
+following them further, and prints the broken links and the audit findings
+of the page. The pages linked to are only checked for their status, they are
+neither audited nor parsed. The options of the -conf configuration, e.g. the
+credentials and headers, are used if it is given; its entrypoint is replaced
+by the url. -set requires -conf.
+`
+
+func check(args []string) int {
+	fs := flagSet("check")
+	config := fs.String("conf", "", "Specify the configuration filename to be used, a .toml, .yaml or .json file")
+	logLevel := fs.String("log-level", "warn", "Specify the level of the crawler's logs: debug, info, warn or error")
+	format := fs.String("format", report.FormatSummary, "Specify the format of the results: summary, jsonl, csv or junit")
+
+	var sets overrides
+	fs.Var(&sets, "set", "Override an option of the -conf configuration, e.g. -set worker-count=20. Can be repeated")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Expected a single url to check\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	page := fs.Arg(0)
+
+	if len(sets) != 0 && *config == "" {
+		fmt.Fprintf(os.Stderr, "-set requires a configuration given with -conf\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	if !report.ValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+		return exitUsage
+	}
+
+	level, err := brink.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		return exitUsage
+	}
+
+	var opts brink.CrawlOptions
+	if *config != "" {
+		if opts, err = readOptions(*config, sets); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed reading configuration: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	opts.EntryPoint = page
+	opts.Seeds = []brink.Seed{{URL: page}}
+
+	c, err := brink.NewCrawlerWithOpts(page, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed initializing crawler:\n%s\n", indent(err))
+		return exitFailure
+	}
+
+	c.SetLogger(brink.NewStdLogger(log.Writer(), level))
+	c.UseRequestMiddleware(singlePage(page))
+
+	collector := report.NewCollector()
+	c.HandleResultFunc(collector.Add)
+
+	stopOnSignal(c)
+
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed checking %s: %v\n", page, err)
+		return exitFailure
+	}
+
+	collector.AddFindings(c.AuditFindings())
+
+	entries := collector.Entries()
+	if err := report.Write(os.Stdout, *format, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed writing results: %v\n", err)
+		return exitFailure
+	}
+
+	if failed(entries) {
+		return exitProblems
+	}
+
+	return exitOK
+}
+
+// singlePage returns the middleware skipping the requests of everything but
+// the page and the links found on it, which are only checked. The crawl is
+// started with the root of the page's domain too, which is skipped unless
+// it is the page itself.
+func singlePage(page string) brink.RequestMiddleware {
+	return func(r *brink.Request) error {
+		switch {
+		case r.Link.Depth == 0 && r.Link.Href == page:
+		case r.Link.Depth == 1:
+			r.CheckOnly = true
+		default:
+			return fmt.Errorf("not linked from %s", page)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/djavorszky/brink"
+)
+
+func Test_check_usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no url", nil},
+		{"two urls", []string{"https://liferay.com", "https://liferay.com/a"}},
+		{"unknown format", []string{"-format", "xml", "https://liferay.com"}},
+		{"set without conf", []string{"-set", "worker-count=2", "https://liferay.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(tt.args); got != exitUsage {
+				t.Errorf("check(%q) = %d, want %d", tt.args, got, exitUsage)
+			}
+		})
+	}
+}
+
+func Test_singlePage(t *testing.T) {
+	const page = "https://liferay.com/a"
+
+	tests := []struct {
+		name          string
+		link          brink.Link
+		wantErr       bool
+		wantCheckOnly bool
+	}{
+		{"page", brink.Link{Href: page}, false, false},
+		{"root", brink.Link{Href: "https://liferay.com"}, true, false},
+		{"linked", brink.Link{Href: "https://liferay.com/b", LinkedFrom: page, Depth: 1}, false, true},
+		{"linked from linked", brink.Link{Href: "https://liferay.com/c", LinkedFrom: "https://liferay.com/b", Depth: 2}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &brink.Request{URL: tt.link.Href, Link: tt.link}
+
+			err := singlePage(page)(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("singlePage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if r.CheckOnly != tt.wantCheckOnly {
+				t.Errorf("CheckOnly = %v, want %v", r.CheckOnly, tt.wantCheckOnly)
+			}
+		})
+	}
+}
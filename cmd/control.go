@@ -0,0 +1,376 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/djavorszky/brink"
+)
+
+// controlTokenEnv is the environment variable the token of the control API
+// is read from if it is not given by a flag.
+const controlTokenEnv = "BRINK_CONTROL_TOKEN"
+
+// status is the response of the /status endpoint of the control API.
+type status struct {
+	Started      time.Time       `json:"started"`
+	Paused       bool            `json:"paused"`
+	Workers      int             `json:"workers"`
+	FrontierSize int             `json:"frontierSize"`
+	PagesFetched int64           `json:"pagesFetched"`
+	CachedVisits int64           `json:"cachedVisits"`
+	Errors       int64           `json:"errors"`
+	StatusCounts map[int]int64   `json:"statusCounts"`
+	Bytes        int64           `json:"bytesDownloaded"`
+	Hosts        map[string]host `json:"hosts"`
+}
+
+type host struct {
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+}
+
+type recentError struct {
+	Time       time.Time `json:"time"`
+	URL        string    `json:"url"`
+	LinkedFrom string    `json:"linkedFrom"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// controlHandler returns the handler of the control API:
+//
+//	GET  /status   crawl status, frontier size and statistics
+//	GET  /errors   the most recent failed requests and error responses
+//	POST /pause    pause the crawl
+//	POST /resume   resume the crawl
+//	POST /stop     stop the crawl
+//	POST /seeds    add urls to visit, e.g. {"urls": ["https://example.com/new"]}, or requests
+//	               to send, e.g. {"requests": [{"url": "https://example.com/api", "method": "POST"}]};
+//	               if the buffer fills up, 503 tells how many of them were added, the urls first
+//	GET  /workers  the number of workers
+//	POST /workers  change the number of workers, e.g. {"count": 8}
+//
+// If the token is not empty, every request must carry it in an
+// "Authorization: Bearer <token>" header. POST requests sent by browsers,
+// which carry an Origin header, and the ones with a body other than JSON
+// are rejected, so that web pages cannot use the API through the browser
+// of the operator.
+func controlHandler(c *brink.Crawler, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", get(func(w http.ResponseWriter, r *http.Request) {
+		s := c.Stats()
+
+		hosts := make(map[string]host, len(s.Hosts))
+		for name, h := range s.Hosts {
+			hosts[name] = host{Requests: h.Requests, Errors: h.Errors}
+		}
+
+		writeJSON(w, http.StatusOK, status{
+			Started:      s.Started,
+			Paused:       c.Paused(),
+			Workers:      c.WorkerCount(),
+			FrontierSize: s.FrontierSize,
+			PagesFetched: s.PagesFetched,
+			CachedVisits: s.CachedVisits,
+			Errors:       s.Errors,
+			StatusCounts: s.StatusCounts,
+			Bytes:        s.BytesDownloaded,
+			Hosts:        hosts,
+		})
+	}))
+
+	mux.HandleFunc("/errors", get(func(w http.ResponseWriter, r *http.Request) {
+		errs := make([]recentError, 0)
+		for _, e := range c.Stats().RecentErrors {
+			errs = append(errs, recentError{
+				Time:       e.Time,
+				URL:        e.URL,
+				LinkedFrom: e.LinkedFrom,
+				Status:     e.Status,
+				Error:      e.Err,
+			})
+		}
+
+		writeJSON(w, http.StatusOK, errs)
+	}))
+
+	mux.HandleFunc("/pause", post(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Pausing crawl on request of %s", r.RemoteAddr)
+		c.Pause()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/resume", post(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Resuming crawl on request of %s", r.RemoteAddr)
+		c.Resume()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/stop", post(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Stopping crawl on request of %s", r.RemoteAddr)
+		c.Stop()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/seeds", post(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URLs     []string     `json:"urls"`
+			Requests []brink.Seed `json:"requests"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed decoding request: %v", err))
+			return
+		}
+
+		seeds := make([]brink.Seed, 0, len(req.URLs)+len(req.Requests))
+		for _, u := range req.URLs {
+			seeds = append(seeds, brink.Seed{URL: u})
+		}
+		seeds = append(seeds, req.Requests...)
+
+		err := c.AddSeedRequests(seeds...)
+		if full, ok := err.(brink.BufferFull); ok {
+			writeJSON(w, http.StatusServiceUnavailable, struct {
+				Error string `json:"error"`
+				Added int    `json:"added"`
+			}{err.Error(), full.Added})
+			return
+		}
+
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	mux.HandleFunc("/workers", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Count int `json:"count"`
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("failed decoding request: %v", err))
+				return
+			}
+
+			if err := c.SetWorkerCount(req.Count); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		default:
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		req.Count = c.WorkerCount()
+		writeJSON(w, http.StatusOK, req)
+	})
+
+	return withToken(token, notFromBrowsers(mux))
+}
+
+// notFromBrowsers returns the handler rejecting the POST requests which
+// carry an Origin header, or a body whose Content-Type is not JSON.
+func notFromBrowsers(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("requests from browsers are not allowed"))
+			return
+		}
+
+		if r.ContentLength != 0 {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withToken returns the handler rejecting the requests without the bearer
+// token, or h itself if the token is empty.
+func withToken(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+
+	want := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// controlToken returns the token given by the flag, or the one in the
+// BRINK_CONTROL_TOKEN environment variable.
+func controlToken(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	return os.Getenv(controlTokenEnv)
+}
+
+// serverToken returns the token the control API of a crawl requires: the
+// one given by the flag or the environment, or a random one, which is
+// printed to stderr.
+func serverToken(flagValue string) (string, error) {
+	if token := controlToken(flagValue); token != "" {
+		return token, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed generating control token: %v", err)
+	}
+
+	token := hex.EncodeToString(b)
+	fmt.Fprintf(os.Stderr, "Control API token: %s\n", token)
+
+	return token, nil
+}
+
+// localAddr returns the address with localhost as its host if it has none,
+// e.g. ":9091", so that the control API is not exposed to the network
+// unless asked to.
+func localAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+
+	return addr
+}
+
+const pauseDoc = `Pause pauses the crawl served with the control API on -control, e.g. the
+one of "brink crawl -control localhost:9091" or "brink serve". The workers
+finish their current visits, and the links waiting to be visited are kept
+until the crawl is resumed. The token of the control API, the one printed
+by the crawl if it was not given one, is passed with -control-token or the
+BRINK_CONTROL_TOKEN environment variable.
+`
+
+const resumeDoc = `Resume resumes the crawl paused through the control API on -control, or by
+SIGUSR1.
+`
+
+func pause(args []string) int {
+	return sendControl("pause", args)
+}
+
+func resume(args []string) int {
+	return sendControl("resume", args)
+}
+
+// sendControl sends the POST request of the command to the control API of
+// a running crawl.
+func sendControl(cmd string, args []string) int {
+	fs := flagSet(cmd)
+	addr := fs.String("control", "localhost:9091", "Specify the address of the control API of the crawl")
+	token := fs.String("control-token", "", "Specify the token of the control API, "+controlTokenEnv+" is used if not given")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "http://"+localAddr(*addr)+"/"+cmd, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid control API address %s: %v\n", *addr, err)
+		return exitUsage
+	}
+
+	if t := controlToken(*token); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed reaching the control API: %v\n", err)
+		return exitFailure
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&e)
+
+		fmt.Fprintf(os.Stderr, "Failed to %s the crawl: %s %s\n", cmd, resp.Status, e.Error)
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+func serveControl(addr, token string, c *brink.Crawler) {
+	addr = localAddr(addr)
+
+	log.Printf("Serving control API on http://%s", addr)
+	if err := http.ListenAndServe(addr, controlHandler(c, token)); err != nil {
+		log.Printf("Control API stopped: %v", err)
+	}
+}
+
+func get(h http.HandlerFunc) http.HandlerFunc {
+	return onlyMethod(http.MethodGet, h)
+}
+
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return onlyMethod(http.MethodPost, h)
+}
+
+func onlyMethod(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed writing control API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/djavorszky/brink"
+)
+
+func testControl(t *testing.T, token string) (*brink.Crawler, *httptest.Server) {
+	c, err := brink.NewCrawlerWithOpts("https://liferay.com", brink.CrawlOptions{WorkerCount: 2})
+	if err != nil {
+		t.Fatalf("NewCrawlerWithOpts() error = %v", err)
+	}
+
+	return c, httptest.NewServer(controlHandler(c, token))
+}
+
+func send(t *testing.T, method, url, token, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	resp.Body.Close()
+
+	return resp
+}
+
+func Test_controlHandler(t *testing.T) {
+	c, ts := testControl(t, "")
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"status", http.MethodGet, "/status", "", http.StatusOK},
+		{"errors", http.MethodGet, "/errors", "", http.StatusOK},
+		{"status by post", http.MethodPost, "/status", "", http.StatusMethodNotAllowed},
+		{"pause by get", http.MethodGet, "/pause", "", http.StatusMethodNotAllowed},
+		{"pause", http.MethodPost, "/pause", "", http.StatusNoContent},
+		{"resume", http.MethodPost, "/resume", "", http.StatusNoContent},
+		{"seeds", http.MethodPost, "/seeds", `{"urls": ["https://liferay.com/new"]}`, http.StatusNoContent},
+		{"seed requests", http.MethodPost, "/seeds", `{"requests": [{"url": "https://liferay.com/api", "method": "POST"}]}`, http.StatusNoContent},
+		{"relative seed", http.MethodPost, "/seeds", `{"urls": ["/new"]}`, http.StatusBadRequest},
+		{"invalid seeds", http.MethodPost, "/seeds", `{"urls": `, http.StatusBadRequest},
+		{"workers", http.MethodGet, "/workers", "", http.StatusOK},
+		{"set workers", http.MethodPost, "/workers", `{"count": 4}`, http.StatusOK},
+		{"invalid workers", http.MethodPost, "/workers", `{"count": 0}`, http.StatusBadRequest},
+		{"workers by put", http.MethodPut, "/workers", "", http.StatusMethodNotAllowed},
+		{"stop", http.MethodPost, "/stop", "", http.StatusNoContent},
+		{"seeds after stop", http.MethodPost, "/seeds", `{"urls": ["https://liferay.com/late"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := send(t, tt.method, ts.URL+tt.path, "", tt.body); resp.StatusCode != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if c.WorkerCount() != 4 {
+		t.Errorf("WorkerCount() = %d, want 4", c.WorkerCount())
+	}
+}
+
+func Test_controlHandler_status(t *testing.T) {
+	c, ts := testControl(t, "")
+	defer ts.Close()
+
+	c.Pause()
+
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatalf("GET /status error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var got status
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed decoding status: %v", err)
+	}
+
+	if !got.Paused || got.Workers != 2 {
+		t.Errorf("GET /status = %+v, want paused with 2 workers", got)
+	}
+}
+
+func Test_controlHandler_token(t *testing.T) {
+	c, ts := testControl(t, "secret")
+	defer ts.Close()
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "guess", http.StatusUnauthorized},
+		{"valid", "secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(t, http.MethodPost, ts.URL+"/pause", tt.token, "")
+			if resp.StatusCode != tt.want {
+				t.Errorf("POST /pause with token %q = %d, want %d", tt.token, resp.StatusCode, tt.want)
+			}
+
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("missing WWW-Authenticate header: %v", resp.Header)
+			}
+		})
+	}
+
+	if !c.Paused() {
+		t.Errorf("crawler not paused with valid token")
+	}
+}
+
+func Test_controlHandler_seedsBufferFull(t *testing.T) {
+	c, err := brink.NewCrawlerWithOpts("https://liferay.com", brink.CrawlOptions{URLBufferSize: 1})
+	if err != nil {
+		t.Fatalf("NewCrawlerWithOpts() error = %v", err)
+	}
+
+	ts := httptest.NewServer(controlHandler(c, ""))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/seeds", "application/json", strings.NewReader(`{"urls": ["https://liferay.com/a"], "requests": [{"url": "https://liferay.com/b"}]}`))
+	if err != nil {
+		t.Fatalf("POST /seeds error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var got struct {
+		Added int `json:"added"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed decoding response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable || got.Added != 1 {
+		t.Errorf("POST /seeds = %d with %d added, want %d with 1 added", resp.StatusCode, got.Added, http.StatusServiceUnavailable)
+	}
+}
+
+func Test_controlHandler_browsers(t *testing.T) {
+	c, ts := testControl(t, "")
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		origin      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"cross-origin", "https://evil.example", "", "", http.StatusForbidden},
+		{"same origin", ts.URL, "application/json", `{"count": 4}`, http.StatusForbidden},
+		{"text body", "", "text/plain", `{"count": 4}`, http.StatusUnsupportedMediaType},
+		{"form body", "", "application/x-www-form-urlencoded", `count=4`, http.StatusUnsupportedMediaType},
+		{"missing content type", "", "", `{"count": 4}`, http.StatusUnsupportedMediaType},
+		{"json body", "", "application/json; charset=utf-8", `{"count": 4}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/workers", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("NewRequest() error = %v", err)
+			}
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST /workers error = %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("POST /workers = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	if c.WorkerCount() != 4 {
+		t.Errorf("WorkerCount() = %d, want 4", c.WorkerCount())
+	}
+}
+
+func Test_serverToken(t *testing.T) {
+	os.Setenv(controlTokenEnv, "")
+	defer os.Unsetenv(controlTokenEnv)
+
+	if got, err := serverToken("secret"); err != nil || got != "secret" {
+		t.Errorf("serverToken(secret) = %q, %v, want secret", got, err)
+	}
+
+	first, err := serverToken("")
+	if err != nil || len(first) != 32 {
+		t.Fatalf("serverToken() = %q, %v, want a random token of 32 characters", first, err)
+	}
+
+	if second, _ := serverToken(""); second == first {
+		t.Errorf("serverToken() returned the same random token twice: %q", first)
+	}
+}
+
+func Test_localAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":9091", "localhost:9091"},
+		{"localhost:9091", "localhost:9091"},
+		{"0.0.0.0:9091", "0.0.0.0:9091"},
+		{"10.0.0.1:9091", "10.0.0.1:9091"},
+	}
+	for _, tt := range tests {
+		if got := localAddr(tt.addr); got != tt.want {
+			t.Errorf("localAddr(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/djavorszky/brink"
+	"github.com/djavorszky/brink/report"
+)
+
+const crawlDoc = `Crawl visits the pages of the site configured with -conf, starting at its
+entrypoint, and logs the status of every visited url. Broken fragments,
+audit findings and duplicate pages are logged when the crawl finishes.
+
+The crawl stops on SIGINT or SIGTERM, and can be paused with SIGUSR1 and
+resumed with SIGUSR2, or through the control API served with -control. Its
+requests must carry the token set with -control-token or the
+BRINK_CONTROL_TOKEN environment variable in an "Authorization: Bearer
+<token>" header. If no token is set, a random one is printed to stderr.
+POST requests sent by browsers, and the ones with a body other than JSON,
+are rejected.
+`
+
+const serveDoc = `Serve runs the crawl of the configuration while serving on -addr:
+
+  /metrics   the statistics of the crawl in the Prometheus text format
+  /report    the results so far, in the format of the format query parameter,
+             e.g. /report?format=csv; jsonl by default
+  /status, /errors, /pause, /resume, /stop, /seeds and /workers
+             the control API
+
+The results stay available after the crawl finishes, until brink is stopped
+with SIGINT or SIGTERM. Every request must carry the token set with
+-control-token or the BRINK_CONTROL_TOKEN environment variable in an
+"Authorization: Bearer <token>" header. If no token is set, a random one is
+printed to stderr.
+`
+
+// crawlFlags are the flags shared by the crawl and serve commands.
+type crawlFlags struct {
+	config       *string
+	out          *string
+	logLevel     *string
+	reportFile   *string
+	reportFormat *string
+	graphFile    *string
+	graphFormat  *string
+	fail         *bool
+	sets         overrides
+}
+
+func newCrawlFlags(fs *flag.FlagSet) *crawlFlags {
+	f := &crawlFlags{
+		config:       fs.String("conf", "brink.toml", "Specify the configuration filename to be used, a .toml, .yaml or .json file"),
+		out:          fs.String("out", "std", "Specify where to log"),
+		logLevel:     fs.String("log-level", "info", "Specify the level of the crawler's logs: debug, info, warn or error"),
+		reportFile:   fs.String("report", "", "Specify the file to write the crawl report to"),
+		reportFormat: fs.String("format", report.FormatJSONLines, "Specify the format of the report: jsonl, csv, junit or summary"),
+		graphFile:    fs.String("graph", "", "Specify the file to write the link graph to"),
+		graphFormat:  fs.String("graph-format", brink.GraphFormatCSV, "Specify the format of the link graph: csv, dot or graphml"),
+		fail:         fs.Bool("fail", false, "Exit with code 3 if broken links or audit findings of error severity were found"),
+	}
+
+	fs.Var(&f.sets, "set", "Override an option of the configuration, e.g. -set worker-count=20 or -set pass=${PASS}. Can be repeated")
+
+	return f
+}
+
+// newCrawler sets up the logging and returns the crawler of the
+// configuration, with its results logged and collected into the collector.
+func (f *crawlFlags) newCrawler(collector *report.Collector) (*brink.Crawler, error) {
+	if *f.out != "std" {
+		out, err := os.Create(*f.out)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating logfile: %v", err)
+		}
+
+		log.SetOutput(out)
+	}
+
+	level, err := brink.ParseLevel(*f.logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %v", err)
+	}
+
+	if *f.reportFile != "" && !report.ValidFormat(*f.reportFormat) {
+		return nil, fmt.Errorf("unknown report format: %s", *f.reportFormat)
+	}
+
+	opts, err := readOptions(*f.config, f.sets)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading configuration: %v", err)
+	}
+
+	c, err := brink.NewCrawlerWithOpts(opts.EntryPoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed initializing crawler:\n%s", indent(err))
+	}
+
+	c.SetLogger(brink.NewStdLogger(log.Writer(), level))
+
+	c.HandleResultFunc(func(r brink.Result) {
+		logResult(r)
+		collector.Add(r)
+	})
+
+	handlePauseSignals(c)
+
+	return c, nil
+}
+
+// finish adds the audit findings and forms to the collected results, writes
+// the report and the link graph, and logs the problems found. It returns
+// the exit code.
+func (f *crawlFlags) finish(c *brink.Crawler, collector *report.Collector) int {
+	findings := c.AuditFindings()
+
+	collector.AddFindings(findings)
+	collector.AddForms(c.Forms())
+
+	entries := collector.Entries()
+
+	if *f.reportFile != "" {
+		if err := writeReport(*f.reportFile, *f.reportFormat, entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed writing report: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	if *f.graphFile != "" {
+		if err := writeGraph(*f.graphFile, *f.graphFormat, c.Graph()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed writing link graph: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	for _, broken := range c.BrokenFragments() {
+		log.Printf("Broken fragment: %s -> %s#%s", broken.Source, broken.Target, broken.Fragment)
+	}
+
+	for _, finding := range findings {
+		if finding.LinkedFrom != "" {
+			log.Printf("Audit %s: %s -> %s: %s: %s", finding.Severity, finding.LinkedFrom, finding.URL, finding.Rule, finding.Message)
+		} else {
+			log.Printf("Audit %s: %s: %s: %s", finding.Severity, finding.URL, finding.Rule, finding.Message)
+		}
+	}
+
+	for _, group := range c.DuplicateGroups() {
+		log.Printf("Duplicate content of %s:", group.URL)
+		for _, dup := range group.Duplicates {
+			if dup.Exact {
+				log.Printf("    %s (exact)", dup.URL)
+			} else {
+				log.Printf("    %s (distance: %d)", dup.URL, dup.Distance)
+			}
+		}
+	}
+
+	if *f.fail && failed(entries) {
+		return exitProblems
+	}
+
+	return exitOK
+}
+
+func crawl(args []string) int {
+	fs := flagSet("crawl")
+	f := newCrawlFlags(fs)
+	metricsAddr := fs.String("metrics", "", "Specify the address to serve metrics on at /metrics, e.g. localhost:9090")
+	controlAddr := fs.String("control", "", "Specify the address to serve the control API on, e.g. localhost:9091 or :9091 for localhost")
+	controlTokenFlag := fs.String("control-token", "", "Specify the token the requests of the control API must carry, "+controlTokenEnv+" or a random one is used if not given")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	collector := report.NewCollector()
+
+	c, err := f.newCrawler(collector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return exitFailure
+	}
+
+	stopOnSignal(c)
+
+	if *metricsAddr != "" {
+		go serveMetrics(*metricsAddr, c)
+	}
+
+	if *controlAddr != "" {
+		token, err := serverToken(*controlTokenFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return exitFailure
+		}
+
+		go serveControl(*controlAddr, token, c)
+	}
+
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed crawling: %v\n", err)
+		return exitFailure
+	}
+
+	return f.finish(c, collector)
+}
+
+func serve(args []string) int {
+	fs := flagSet("serve")
+	f := newCrawlFlags(fs)
+	addr := fs.String("addr", "localhost:9090", "Specify the address to serve on, :9090 serves on localhost too")
+	tokenFlag := fs.String("control-token", "", "Specify the token the requests must carry, "+controlTokenEnv+" or a random one is used if not given")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	collector := report.NewCollector()
+
+	c, err := f.newCrawler(collector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return exitFailure
+	}
+
+	token, err := serverToken(*tokenFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return exitFailure
+	}
+
+	l, err := net.Listen("tcp", localAddr(*addr))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed listening on %s: %v\n", *addr, err)
+		return exitFailure
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", controlHandler(c, ""))
+	mux.HandleFunc("/metrics", metricsHandler(c))
+	mux.HandleFunc("/report", get(reportHandler(collector)))
+
+	srv := &http.Server{Handler: withToken(token, mux)}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Printf("Server stopped: %v", err)
+		}
+	}()
+	defer srv.Close()
+
+	log.Printf("Serving on http://%s", l.Addr())
+
+	interrupted := stopOnSignal(c)
+
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed crawling: %v\n", err)
+		return exitFailure
+	}
+
+	code := f.finish(c, collector)
+
+	select {
+	case <-interrupted:
+	default:
+		log.Printf("Crawl finished, serving the results on http://%s until stopped", l.Addr())
+		<-interrupted
+	}
+
+	return code
+}
+
+// reportHandler serves the collected results in the format of the format
+// query parameter.
+func reportHandler(collector *report.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = report.FormatJSONLines
+		}
+
+		if !report.ValidFormat(format) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown report format %q", format))
+			return
+		}
+
+		if err := report.Write(w, format, collector.Entries()); err != nil {
+			log.Printf("Failed writing report: %v", err)
+		}
+	}
+}
+
+// stopOnSignal stops the crawler on SIGINT or SIGTERM. The returned channel
+// is closed when the signal is received.
+func stopOnSignal(c *brink.Crawler) <-chan struct{} {
+	interrupted := make(chan struct{})
+
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		close(interrupted)
+		c.Stop()
+	}()
+
+	return interrupted
+}
+
+// logResult logs the status of the visited url, and the page linking to it
+// if it is broken.
+func logResult(r brink.Result) {
+	switch {
+	case r.Err != nil:
+		log.Printf("ERROR: %s -> %s: %v", r.LinkedFrom, r.URL, r.Err)
+	case r.Status >= http.StatusBadRequest && r.Cached:
+		log.Printf("%d: CACHED: %s -> %s", r.Status, r.LinkedFrom, r.URL)
+	case r.Status >= http.StatusBadRequest:
+		log.Printf("%d: %s -> %s", r.Status, r.LinkedFrom, r.URL)
+	case !r.Cached:
+		log.Printf("%d: %s", r.Status, r.URL)
+	}
+}
+
+// failed reports whether any of the entries is broken or has audit
+// findings of error severity.
+func failed(entries []report.Entry) bool {
+	for _, e := range entries {
+		if e.Failed() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,72 +6,216 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 
 	"github.com/djavorszky/brink"
+	"github.com/djavorszky/brink/report"
 )
 
+// Exit codes of the commands.
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitProblems = 3
+)
+
+// command is a subcommand of brink.
+type command struct {
+	name    string
+	args    string
+	summary string
+	doc     string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"crawl", "[flags]", "crawl the site of the configuration", crawlDoc, crawl},
+		{"check", "[flags] <url>", "check the links of a single page", checkDoc, check},
+		{"serve", "[flags]", "crawl while serving the control API, metrics and the report", serveDoc, serve},
+		{"pause", "[flags]", "pause a crawl through its control API", pauseDoc, pause},
+		{"resume", "[flags]", "resume a paused crawl through its control API", resumeDoc, resume},
+		{"report", "[flags] <report.jsonl>", "render a saved jsonl report", reportDoc, renderReport},
+		{"validate", "[flags]", "validate the configuration and print the effective settings", validateDoc, validate},
+	}
+}
+
 func main() {
-	config := flag.String("conf", "brink.toml", "Specify the configuration filename to be used")
-	out := flag.String("out", "std", "Specify where to log")
+	args := os.Args[1:]
+
+	// Without a command the flags are the ones of crawl, as before the
+	// commands were introduced.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0]) {
+		os.Exit(crawl(args))
+	}
+
+	if isHelpFlag(args[0]) || args[0] == "help" {
+		os.Exit(help(args[1:]))
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			os.Exit(cmd.run(args[1:]))
+		}
+	}
 
-	flag.Parse()
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	usage()
+	os.Exit(exitUsage)
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
 
-	if *out != "std" {
-		f, err := os.Create(*out)
-		if err != nil {
-			fmt.Printf("Failed creating logfile: %v", err)
-			os.Exit(1)
+// help prints the usage of the command given by the arguments, or the list
+// of commands.
+func help(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run([]string{"-h"})
 		}
-		defer f.Close()
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	usage()
 
-		log.SetOutput(f)
+	return exitUsage
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: brink <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
 	}
 
-	c, err := brink.NewCrawlerFromToml(*config)
+	fmt.Fprintf(os.Stderr, `
+Without a command, brink crawls with the flags of the crawl command.
+Run "brink help <command>" for the flags of a command.
+
+Exit codes:
+  0  success
+  1  failure, e.g. an invalid configuration or an unreachable control API
+  2  invalid command line
+  3  problems found: broken links or audit findings of error severity
+`)
+}
+
+// flagSet returns the flag set of the command, printing its documentation
+// and flags on -h.
+func flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	for i := range commands {
+		if cmd := commands[i]; cmd.name == name {
+			fs.Usage = func() {
+				fmt.Fprintf(fs.Output(), "Usage: brink %s %s\n\n%s\nFlags:\n", cmd.name, cmd.args, cmd.doc)
+				fs.PrintDefaults()
+			}
+		}
+	}
+
+	return fs
+}
+
+// parseFlags parses the arguments of the command, and returns the exit code
+// to return with if the command should not be run.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return exitOK, false
+	}
+
+	if err != nil {
+		return exitUsage, false
+	}
+
+	return exitOK, true
+}
+
+// readOptions reads the configuration file and applies the -set overrides.
+func readOptions(filename string, sets overrides) (brink.CrawlOptions, error) {
+	opts, err := brink.ReadOptions(filename)
 	if err != nil {
-		fmt.Printf("Failed initializing crawler: %v\n", err)
-		os.Exit(1)
+		return opts, err
 	}
 
-	ch := make(chan os.Signal, 2)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-ch
-		c.Stop()
-	}()
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return opts, fmt.Errorf("invalid override %q, expected key=value", set)
+		}
 
-	c.HandleDefaultFunc(handler)
-	c.HandleFunc(http.StatusNotFound, notFoundHandler)
+		if err := opts.Set(key, value); err != nil {
+			return opts, fmt.Errorf("invalid override: %v", err)
+		}
+	}
 
-	c.Start()
+	return opts, nil
 }
 
-var oks int
+// indent returns the lines of the error indented, for the ConfigErrors
+// listing several problems.
+func indent(err error) string {
+	return "    " + strings.ReplaceAll(err.Error(), "\n", "\n    ")
+}
 
-func handler(linkedFrom, url string, status int, body string, cached bool) {
-	oks++
-	log.Printf("%d: %s", status, url)
+// overrides collects the values of the repeated -set flags.
+type overrides []string
 
-	if oks%100 == 0 {
-		log.Printf("Links seen: %d", oks)
+func (o *overrides) String() string {
+	return strings.Join(*o, " ")
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+func writeReport(filename, format string, entries []report.Entry) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed creating report file: %v", err)
 	}
+	defer f.Close()
+
+	return report.Write(f, format, entries)
+}
 
-	if cached {
-		return
+func writeGraph(filename, format string, graph *brink.LinkGraph) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed creating graph file: %v", err)
 	}
+	defer f.Close()
+
+	return graph.Write(f, format)
+}
 
-	if strings.Contains(body, "Use the buttons below to create it or to search for the words in the title.") {
-		log.Printf("%s -> %s: linked wiki article does not exist", linkedFrom, url)
+func serveMetrics(addr string, c *brink.Crawler) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler(c))
+
+	log.Printf("Serving metrics on http://%s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
 	}
 }
 
-func notFoundHandler(linkedFrom, url string, status int, body string, cached bool) {
-	if cached {
-		log.Printf("404: CACHED: %s -> %s", linkedFrom, url)
-	} else {
-		log.Printf("404: %s -> %s", linkedFrom, url)
+func metricsHandler(c *brink.Crawler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		if err := c.Stats().WritePrometheus(w); err != nil {
+			log.Printf("Failed writing metrics: %v", err)
+		}
 	}
 }
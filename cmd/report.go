@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/djavorszky/brink/report"
+)
+
+const reportDoc = `Report renders the report saved by "brink crawl -report <file> -format jsonl"
+in another format, by default as a summary of the broken links and audit
+findings.
+`
+
+// renderReport is the report command.
+func renderReport(args []string) int {
+	fs := flagSet("report")
+	format := fs.String("format", report.FormatSummary, "Specify the format to render the report in: summary, jsonl, csv or junit")
+	out := fs.String("out", "", "Specify the file to write the rendered report to instead of stdout")
+	fail := fs.Bool("fail", false, "Exit with code 3 if the report has broken links or audit findings of error severity")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Expected a single report file\n\n")
+		fs.Usage()
+		return exitUsage
+	}
+
+	if !report.ValidFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+		return exitUsage
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed opening report: %v\n", err)
+		return exitFailure
+	}
+	defer f.Close()
+
+	entries, err := report.ReadJSONLines(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed reading report %s: %v\n", fs.Arg(0), err)
+		return exitFailure
+	}
+
+	if *out != "" {
+		err = writeReport(*out, *format, entries)
+	} else {
+		err = report.Write(os.Stdout, *format, entries)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed writing report: %v\n", err)
+		return exitFailure
+	}
+
+	if *fail && failed(entries) {
+		return exitProblems
+	}
+
+	return exitOK
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/djavorszky/brink"
+)
+
+// handlePauseSignals pauses the crawler on SIGUSR1 and resumes it on SIGUSR2.
+func handlePauseSignals(c *brink.Crawler) {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR1:
+				c.Pause()
+			case syscall.SIGUSR2:
+				c.Resume()
+			}
+		}
+	}()
+}
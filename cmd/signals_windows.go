@@ -0,0 +1,7 @@
+package main
+
+import "github.com/djavorszky/brink"
+
+// handlePauseSignals is a no-op, as there are no user-defined signals on
+// Windows to pause and resume the crawler with.
+func handlePauseSignals(c *brink.Crawler) {}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/djavorszky/brink"
+)
+
+const validateDoc = `Validate reads the configuration, applies the -set overrides and reports
+every problem found, with the line of the configuration it is on. If the
+configuration is valid, the effective settings are printed to stdout as
+TOML, with the defaults applied and the passwords, header and cookie
+values and seed bodies masked.
+`
+
+// validate checks the configuration given by the arguments, and prints the
+// effective settings with the defaults applied. It returns the exit code.
+func validate(args []string) int {
+	fs := flagSet("validate")
+	config := fs.String("conf", "brink.toml", "Specify the configuration filename to be validated, a .toml, .yaml or .json file")
+
+	var sets overrides
+	fs.Var(&sets, "set", "Override an option of the configuration, e.g. -set worker-count=20. Can be repeated")
+
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	opts, err := readOptions(*config, sets)
+	if err == nil {
+		err = opts.Validate()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration %s:\n%s\n", *config, indent(err))
+		return exitFailure
+	}
+
+	effective := opts.WithDefaults()
+	maskSecrets(&effective)
+
+	fmt.Fprintf(os.Stderr, "Configuration %s is valid, the effective settings are:\n", *config)
+	if err := toml.NewEncoder(os.Stdout).Encode(effective); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed printing the settings: %v\n", err)
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// masked replaces the values which may be secrets.
+const masked = "********"
+
+// maskSecrets replaces the passwords, the header and cookie values, the
+// bodies of the seeds and the passwords of the proxies of the options, so
+// that the settings can be printed.
+func maskSecrets(opts *brink.CrawlOptions) {
+	opts.Pass = maskValue(opts.Pass)
+	opts.Proxy = maskProxy(opts.Proxy)
+	opts.Headers = maskHeaders(opts.Headers)
+
+	cookies := make(map[string]*http.Cookie, len(opts.Cookies))
+	for name, cookie := range opts.Cookies {
+		if cookie != nil {
+			c := *cookie
+			c.Value, c.Raw = maskValue(c.Value), maskValue(c.Raw)
+			cookie = &c
+		}
+
+		cookies[name] = cookie
+	}
+	opts.Cookies = cookies
+
+	seeds := append([]brink.Seed(nil), opts.Seeds...)
+	for i, seed := range seeds {
+		seeds[i].Body = maskValue(seed.Body)
+		seeds[i].Headers = maskHeaders(seed.Headers)
+	}
+	opts.Seeds = seeds
+
+	profiles := make(map[string]brink.DomainProfile, len(opts.Profiles))
+	for pattern, p := range opts.Profiles {
+		p.Pass = maskValue(p.Pass)
+		p.Proxy = maskProxy(p.Proxy)
+		p.Headers = maskHeaders(p.Headers)
+		profiles[pattern] = p
+	}
+	opts.Profiles = profiles
+}
+
+// maskValue masks the value unless it is empty.
+func maskValue(v string) string {
+	if v == "" {
+		return ""
+	}
+
+	return masked
+}
+
+func maskHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	m := make(map[string]string, len(headers))
+	for k, v := range headers {
+		m[k] = maskValue(v)
+	}
+
+	return m
+}
+
+// maskProxy replaces the password of the proxy url, if any, with "xxxxx".
+func maskProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+
+	return u.Redacted()
+}
@@ -0,0 +1,964 @@
+package brink
+
+import (
+	"encoding"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// fileRefPrefix marks a value to be replaced with the contents of a file.
+const fileRefPrefix = "file:"
+
+// ReadTomlOptions decodes the toml file into CrawlOptions, expanding the
+// environment variables and file references of its values, see Expand.
+// Unknown keys and values not fitting their options are reported along
+// with their lines as ConfigErrors.
+func ReadTomlOptions(filename string) (CrawlOptions, error) {
+	return readOptions(filename, parseToml)
+}
+
+// ReadOptions reads the configuration file into CrawlOptions, decoding it
+// as toml, yaml or json by its extension: .toml, .yaml or .yml, or .json.
+// The keys of all the formats are the same, and they are checked and
+// expanded the same way as by ReadTomlOptions.
+func ReadOptions(filename string) (CrawlOptions, error) {
+	var parse parser
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".toml":
+		parse = parseToml
+	case ".yaml", ".yml":
+		parse = parseYAML
+	case ".json":
+		parse = parseJSON
+	default:
+		return CrawlOptions{}, fmt.Errorf("unknown configuration format %q, expected .toml, .yaml, .yml or .json", ext)
+	}
+
+	return readOptions(filename, parse)
+}
+
+// parser parses a configuration document into the values toml documents
+// are decoded into, and returns the lines of its keys, see keyLines.
+type parser func(doc []byte) (map[string]interface{}, map[string]int, error)
+
+func readOptions(filename string, parse parser) (CrawlOptions, error) {
+	var opts CrawlOptions
+
+	doc, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return opts, fmt.Errorf("failed reading file: %v", err)
+	}
+
+	if err := decode(doc, parse, &opts); err != nil {
+		return opts, err
+	}
+
+	if err := opts.Expand(filepath.Dir(filename)); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+func parseToml(doc []byte) (map[string]interface{}, map[string]int, error) {
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(doc), &raw); err != nil {
+		return nil, nil, err
+	}
+
+	return raw, keyLines(string(doc)), nil
+}
+
+// optionAliases maps the former keys of top level options to their
+// current keys.
+var optionAliases = map[string]string{
+	"ignore-path-visits": "forbidden-paths",
+}
+
+// decode parses the document and decodes it into the options. The parsed
+// document is checked against the options before, so that all the unknown
+// keys and mistyped values are reported at once. It is decoded as toml in
+// the end, so that all formats are decoded the same way.
+func decode(doc []byte, parse parser, opts *CrawlOptions) error {
+	raw, lines, err := parse(doc)
+	if err != nil {
+		return fmt.Errorf("failed decoding file: %v", err)
+	}
+
+	var errs ConfigErrors
+	report := func(key, msg string) {
+		errs = append(errs, &ConfigError{Key: key, Line: lineOf(lines, key), Msg: msg})
+	}
+
+	for alias, key := range optionAliases {
+		value, ok := raw[alias]
+		if !ok {
+			continue
+		}
+
+		if _, ok := raw[key]; ok {
+			report(alias, fmt.Sprintf("can not be set along with %s, which replaces it", key))
+		} else {
+			raw[key] = value
+		}
+
+		delete(raw, alias)
+	}
+
+	checkTypes(raw, reflect.TypeOf(*opts), "", report)
+	if len(errs) != 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Line < errs[j].Line
+		})
+
+		return errs
+	}
+
+	var b strings.Builder
+	if err := toml.NewEncoder(&b).Encode(raw); err != nil {
+		return fmt.Errorf("failed decoding file: %v", err)
+	}
+
+	if _, err := toml.Decode(b.String(), opts); err != nil {
+		return fmt.Errorf("failed decoding file: %v", err)
+	}
+
+	return nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// checkTypes calls report with the keys of the decoded data which are not
+// options of t, or whose values do not fit their options. It returns the
+// value converted to the type of its option where it is unambiguous, i.e.
+// integers for floats and RFC 3339 strings for datetimes, which yaml and
+// json documents can not tell apart.
+func checkTypes(data interface{}, t reflect.Type, key string, report func(key, msg string)) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	want, got := tomlTypeOf(t), tomlTypeOfValue(data)
+	switch {
+	case want == "":
+		return data
+	case want == "array" && got == "array of tables":
+	case want == "float" && got == "integer":
+		return float64(data.(int64))
+	case want == "datetime" && got == "string":
+		tm, err := time.Parse(time.RFC3339, data.(string))
+		if err != nil {
+			report(key, fmt.Sprintf("expected datetime, found string %q", data))
+			return data
+		}
+
+		return tm
+	case want != got:
+		report(key, fmt.Sprintf("expected %s, found %s", want, got))
+		return data
+	}
+
+	if t == timeType {
+		return data
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		table := data.(map[string]interface{})
+		for _, k := range sortedKeys(table) {
+			f, ok := optionField(t, k)
+			if !ok {
+				report(joinPath(key, k), "unknown option")
+				continue
+			}
+
+			table[k] = checkTypes(table[k], f.Type, joinPath(key, k), report)
+		}
+	case reflect.Map:
+		table := data.(map[string]interface{})
+		for _, k := range sortedKeys(table) {
+			table[k] = checkTypes(table[k], t.Elem(), joinPath(key, k), report)
+		}
+	case reflect.Slice:
+		v := reflect.ValueOf(data)
+		for i := 0; i < v.Len(); i++ {
+			if e := checkTypes(v.Index(i).Interface(), t.Elem(), fmt.Sprintf("%s[%d]", key, i), report); e != nil {
+				v.Index(i).Set(reflect.ValueOf(e))
+			}
+		}
+	}
+
+	return data
+}
+
+// tomlTypeOf returns the name of the toml type decoded into t, or "" if t
+// accepts any value.
+func tomlTypeOf(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "datetime"
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Struct, reflect.Map:
+		return "table"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+
+	return ""
+}
+
+// tomlTypeOfValue returns the name of the toml type of the decoded value.
+func tomlTypeOfValue(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case time.Time:
+		return "datetime"
+	case map[string]interface{}:
+		return "table"
+	case []map[string]interface{}:
+		return "array of tables"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// optionField returns the field of the struct type decoded from the key,
+// matching it the same way as the toml decoder does.
+func optionField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var (
+		folded reflect.StructField
+		found  bool
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, ok := optionName(f)
+		switch {
+		case !ok:
+		case name == key:
+			return f, true
+		case !found && strings.EqualFold(name, key):
+			folded, found = f, true
+		}
+	}
+
+	return folded, found
+}
+
+// keyLines returns the lines of the keys and table headers of the toml
+// document, by their keys as reported in ConfigErrors. The document is only
+// scanned line by line, so the keys of inline tables are not included.
+func keyLines(doc string) map[string]int {
+	var (
+		lines = make(map[string]int)
+
+		// arrays holds the number of elements of the arrays of tables.
+		arrays = make(map[string]int)
+
+		table string
+
+		// multiline is the delimiter of the multi-line string being read.
+		multiline string
+	)
+
+	for i, line := range strings.Split(doc, "\n") {
+		n := i + 1
+		line = strings.TrimSpace(line)
+
+		if multiline != "" {
+			if strings.Count(line, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			element := strings.HasPrefix(line, "[[")
+
+			end := strings.Index(line, "]")
+			if end == -1 {
+				continue
+			}
+
+			segments, err := splitTomlKey(strings.TrimLeft(line[:end], "["))
+			if err != nil {
+				continue
+			}
+
+			table = ""
+			for j, segment := range segments {
+				table = joinPath(table, segment)
+				if _, ok := lines[table]; !ok {
+					lines[table] = n
+				}
+
+				if element && j == len(segments)-1 {
+					arrays[table]++
+				}
+
+				if count := arrays[table]; count != 0 {
+					table = fmt.Sprintf("%s[%d]", table, count-1)
+				}
+			}
+
+			lines[table] = n
+			continue
+		}
+
+		end := tomlKeyEnd(line)
+		if end == -1 {
+			continue
+		}
+
+		segments, err := splitTomlKey(line[:end])
+		if err != nil {
+			continue
+		}
+
+		key := table
+		for _, segment := range segments {
+			key = joinPath(key, segment)
+		}
+
+		if _, ok := lines[key]; !ok {
+			lines[key] = n
+		}
+
+		value := strings.TrimSpace(line[end+1:])
+		for _, delim := range []string{`"""`, "'''"} {
+			if strings.HasPrefix(value, delim) && strings.Count(value, delim)%2 == 1 {
+				multiline = delim
+			}
+		}
+	}
+
+	return lines
+}
+
+// tomlKeyEnd returns the index of the "=" after the key of the line, or -1
+// if the line does not start with a key.
+func tomlKeyEnd(line string) int {
+	var quote rune
+
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '=':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lineOf returns the line of the key, or of its closest parent whose line
+// is known, or 0.
+func lineOf(lines map[string]int, key string) int {
+	for key != "" {
+		if n, ok := lines[key]; ok {
+			return n
+		}
+
+		key = parentKey(key)
+	}
+
+	return 0
+}
+
+// parentKey returns the key without its last segment or index.
+func parentKey(key string) string {
+	if strings.HasSuffix(key, "]") {
+		if i := strings.LastIndex(key, "["); i != -1 {
+			return key[:i]
+		}
+	}
+
+	last, quoted := -1, false
+	for i, r := range key {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			last = i
+		}
+	}
+
+	if last == -1 {
+		return ""
+	}
+
+	return key[:last]
+}
+
+// Expand expands the references in all the string values of the options,
+// so that secrets do not need to be written in configuration files:
+//
+//	${NAME}           the value of the environment variable NAME, which must be set
+//	${NAME:-default}  the value of NAME, or default if it is unset or empty
+//	$${               a literal "${"
+//	file:path         the contents of the file, without trailing newlines, if the
+//	                  value starts with "file:". Relative paths are relative to dir.
+//
+// Environment variables are expanded first, so file references may contain
+// them, e.g. "file:${SECRETS_DIR}/pass".
+func (o *CrawlOptions) Expand(dir string) error {
+	return expandValue(reflect.ValueOf(o).Elem(), "", func(s string) (string, error) {
+		return expandString(s, dir)
+	})
+}
+
+// expandValue calls expand with every string inside v, replacing them with
+// the result. The path of v is used in the errors.
+func expandValue(v reflect.Value, path string, expand func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := expand(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		v.SetString(s)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandValue(v.Elem(), path, expand)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := optionName(t.Field(i))
+			if !ok {
+				continue
+			}
+
+			if err := expandValue(v.Field(i), joinPath(path, name), expand); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), expand); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map elements are not addressable, so a copy is expanded
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+
+			if err := expandValue(elem, joinPath(path, key.String()), expand); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
+
+// expandString expands the environment variables and the file reference
+// of the value.
+func expandString(s, dir string) (string, error) {
+	var b strings.Builder
+
+	for {
+		i := strings.Index(s, "${")
+		if i == -1 {
+			b.WriteString(s)
+			break
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("missing } in %q", s[i:])
+		}
+
+		value, err := lookupEnv(s[i+2 : i+end])
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+
+	expanded := b.String()
+	if !strings.HasPrefix(expanded, fileRefPrefix) {
+		return expanded, nil
+	}
+
+	name := strings.TrimPrefix(expanded, fileRefPrefix)
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+
+	contents, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("failed reading referenced file: %v", err)
+	}
+
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// lookupEnv returns the value of the reference of the form NAME or
+// NAME:-default.
+func lookupEnv(ref string) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+
+	if !validEnvName(name) {
+		return "", fmt.Errorf("invalid environment variable name %q", name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	switch {
+	case hasDefault && value == "":
+		return def, nil
+	case !ok:
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Set sets the option identified by its key in the configuration file to
+// the value, e.g. Set("worker-count", "20"). Keys of nested tables are
+// separated by dots, and quoted if they contain dots themselves, e.g.
+// `profiles."api.example.com".user`. Lists are given as comma separated
+// values, or as toml arrays, e.g. `["a", "b"]`. The value is expanded the
+// same way as by Expand, relative file references are relative to the
+// working directory.
+func (o *CrawlOptions) Set(key, value string) error {
+	path, err := splitTomlKey(key)
+	if err != nil {
+		return fmt.Errorf("invalid key %q: %v", key, err)
+	}
+
+	if alias, ok := optionAliases[path[0]]; ok {
+		path[0] = alias
+	}
+
+	value, err = expandString(value, "")
+	if err != nil {
+		return fmt.Errorf("failed expanding %s: %v", key, err)
+	}
+
+	if err := setValue(reflect.ValueOf(o).Elem(), path, value); err != nil {
+		return fmt.Errorf("failed setting %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// splitTomlKey splits the dotted toml key into its segments, which are
+// either bare, or quoted if they contain dots themselves.
+func splitTomlKey(key string) ([]string, error) {
+	var segments []string
+
+	s := strings.TrimSpace(key)
+	for {
+		var segment string
+
+		switch {
+		case s == "":
+			return nil, fmt.Errorf("empty key")
+		case s[0] == '"' || s[0] == '\'':
+			end := strings.IndexByte(s[1:], s[0])
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+
+			segment, s = s[1:end+1], s[end+2:]
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+			})
+			if end == -1 {
+				end = len(s)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("unexpected %q", s[0])
+			}
+
+			segment, s = s[:end], s[end:]
+		}
+
+		segments = append(segments, segment)
+
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return segments, nil
+		}
+
+		if s[0] != '.' {
+			return nil, fmt.Errorf("unexpected %q", s[0])
+		}
+
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+func setValue(v reflect.Value, path []string, value string) error {
+	if len(path) == 0 {
+		return setLeaf(v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return setValue(v.Elem(), path, value)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := optionName(t.Field(i))
+			if ok && strings.EqualFold(name, path[0]) {
+				return setValue(v.Field(i), path[1:], value)
+			}
+		}
+
+		return fmt.Errorf("unknown option %q", path[0])
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		key := reflect.ValueOf(path[0])
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+
+		if err := setValue(elem, path[1:], value); err != nil {
+			return err
+		}
+
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+
+	return fmt.Errorf("%q is not a table", path[0])
+}
+
+func setLeaf(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of %s can not be set", v.Type().Elem())
+		}
+
+		list, err := parseList(value)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("values of type %s can not be set", v.Type())
+	}
+
+	return nil
+}
+
+// parseList parses a toml array of strings, or comma separated values.
+func parseList(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "[") {
+		var doc struct {
+			List []string `toml:"list"`
+		}
+
+		if _, err := toml.Decode("list = "+value, &doc); err != nil {
+			return nil, fmt.Errorf("invalid list: %v", err)
+		}
+
+		return doc.List, nil
+	}
+
+	list := []string{}
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+
+	return list, nil
+}
+
+// optionName returns the name of the field in configuration files, which
+// is its toml key or, if it has none, its name. It returns false for
+// unexported fields and the ones left out of configuration files.
+func optionName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	tag := strings.Split(f.Tag.Get("toml"), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+
+	return tag, true
+}
+
+func joinPath(path, name string) string {
+	if strings.Contains(name, ".") {
+		name = strconv.Quote(name)
+	}
+
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// Validate checks the options, returning ConfigErrors listing all of their
+// problems, or nil if there are none.
+func (o CrawlOptions) Validate() error {
+	var errs ConfigErrors
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, &ConfigError{Key: key, Msg: err.Error()})
+		}
+	}
+
+	if o.EntryPoint == "" {
+		check("entrypoint", fmt.Errorf("must be set"))
+	} else if _, _, err := schemeAndHost(o.EntryPoint); err != nil {
+		check("entrypoint", fmt.Errorf("invalid url %q: %v", o.EntryPoint, err))
+	}
+
+	switch o.AuthType {
+	case AuthNone, AuthBasic:
+	default:
+		check("auth-type", fmt.Errorf("unknown auth type %d", o.AuthType))
+	}
+
+	check("rate-limit", notNegative(o.RateLimit))
+	check("timeout", notNegative(float64(o.Timeout)))
+	check("url-buffer-size", notNegative(float64(o.URLBufferSize)))
+	check("worker-count", notNegative(float64(o.WorkerCount)))
+	check("idle-work-check-interval", notNegative(float64(o.IdleWorkCheckInterval)))
+
+	if o.MaxContentLength < -1 {
+		check("max-content-length", fmt.Errorf("must be -1 for unlimited, 0 for the default or a size"))
+	}
+
+	if o.Proxy != "" {
+		_, err := parseProxy(o.Proxy)
+		check("proxy", err)
+	}
+
+	check("adaptive-workers", o.AdaptiveWorkers.validate(o.WithDefaults().WorkerCount))
+
+	for i, s := range o.Seeds {
+		check(fmt.Sprintf("seeds[%d]", i), s.validate())
+	}
+
+	for i, domain := range o.AllowedDomains {
+		if _, err := parseDomainRule(domain); err != nil {
+			check(fmt.Sprintf("allowed-domains[%d]", i), fmt.Errorf("invalid domain %q: %v", domain, err))
+		}
+	}
+
+	names := make([]string, 0, len(o.Cookies))
+	for name := range o.Cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if c := o.Cookies[name]; c == nil || c.Name == "" {
+			check(joinPath("cookies", name), fmt.Errorf("missing name"))
+		}
+	}
+
+	check("normalization", o.Normalization.validate())
+
+	if o.Duplicates.NearThreshold < 0 {
+		check("duplicates.near-threshold", fmt.Errorf("must not be negative"))
+	}
+
+	check("forms", o.Forms.validate())
+	check("json", o.JSON.validate())
+	check("rendering", o.Rendering.validate())
+	check("audit", o.Audit.validate())
+
+	patterns := make([]string, 0, len(o.Profiles))
+	for pattern := range o.Profiles {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if _, err := parseDomainRule(pattern); err != nil {
+			check(joinPath("profiles", pattern), fmt.Errorf("invalid domain: %v", err))
+			continue
+		}
+
+		check(joinPath("profiles", pattern), o.Profiles[pattern].validate())
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func notNegative(v float64) error {
+	if v < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+
+	return nil
+}
+
+// WithDefaults returns the options with the unset values replaced by the
+// defaults the crawler uses for them.
+func (o CrawlOptions) WithDefaults() CrawlOptions {
+	if o.MaxContentLength == 0 {
+		o.MaxContentLength = defaultMaxContentLength
+	}
+
+	if o.URLBufferSize == 0 {
+		o.URLBufferSize = defaultURLBufferSize
+	}
+
+	if o.WorkerCount <= 0 {
+		o.WorkerCount = defaultWorkerCount
+	}
+
+	if o.IdleWorkCheckInterval <= 0 {
+		o.IdleWorkCheckInterval = defaultIdleWorkCheckInterval
+	}
+
+	o.AdaptiveWorkers = o.AdaptiveWorkers.withDefaults(o.WorkerCount)
+
+	if o.Duplicates.NearThreshold == 0 {
+		o.Duplicates.NearThreshold = defaultNearDuplicateThreshold
+	}
+
+	if o.Forms.MaxSubmissions == 0 {
+		o.Forms.MaxSubmissions = defaultMaxFormSubmissions
+	}
+
+	if o.Audit.MaxPageSize == 0 {
+		o.Audit.MaxPageSize = defaultMaxPageSize
+	}
+
+	return o
+}
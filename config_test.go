@@ -0,0 +1,288 @@
+package brink
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_expandString(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "pass"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("BRINK_USER", "admin")
+	t.Setenv("BRINK_EMPTY", "")
+	t.Setenv("BRINK_SECRETS", dir)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "http://example.com/$path", "http://example.com/$path", false},
+		{"variable", "user ${BRINK_USER}!", "user admin!", false},
+		{"default", "${BRINK_UNSET_FOR_TEST:-guest}", "guest", false},
+		{"default of empty", "${BRINK_EMPTY:-guest}", "guest", false},
+		{"empty", "${BRINK_EMPTY}", "", false},
+		{"escaped", "$${BRINK_USER} ${BRINK_USER}", "${BRINK_USER} admin", false},
+		{"relative file", "file:pass", "s3cret", false},
+		{"absolute file", "file:${BRINK_SECRETS}/pass", "s3cret", false},
+		{"missing variable", "${BRINK_UNSET_FOR_TEST}", "", true},
+		{"invalid name", "${1BRINK}", "", true},
+		{"unterminated", "${BRINK_USER", "", true},
+		{"missing file", "file:nothing", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandString(tt.value, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("expandString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawlOptions_Expand(t *testing.T) {
+	t.Setenv("BRINK_TOKEN", "42")
+
+	opts := CrawlOptions{
+		Pass:    "${BRINK_TOKEN}",
+		Headers: map[string]string{"X-Token": "${BRINK_TOKEN}"},
+		Profiles: map[string]DomainProfile{
+			"api.example.com": {Pass: "token-${BRINK_TOKEN}"},
+		},
+		Seeds: []Seed{{URL: "http://example.com/?token=${BRINK_TOKEN}"}},
+	}
+
+	if err := opts.Expand(""); err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	got := []string{opts.Pass, opts.Headers["X-Token"], opts.Profiles["api.example.com"].Pass, opts.Seeds[0].URL}
+	want := []string{"42", "42", "token-42", "http://example.com/?token=42"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand() = %q, want %q", got, want)
+	}
+
+	opts.Profiles["api.example.com"] = DomainProfile{User: "${BRINK_UNSET_FOR_TEST}"}
+	if err := opts.Expand(""); err == nil || err.Error() != `profiles."api.example.com".user: environment variable BRINK_UNSET_FOR_TEST is not set` {
+		t.Errorf("Expand() error = %v", err)
+	}
+}
+
+func TestCrawlOptions_Set(t *testing.T) {
+	t.Setenv("BRINK_USER", "admin")
+
+	var opts CrawlOptions
+
+	sets := [][2]string{
+		{"worker-count", "20"},
+		{"normalization.lowercase-host", "true"},
+		{"headers.X-Token", "42"},
+		{`profiles."api.example.com".user`, "${BRINK_USER}"},
+		{`profiles."api.example.com".rate-limit`, "0.5"},
+		{"allowed-domains", "a.com, b.com"},
+		{"ignore-get-parameters", `["utm_source", "utm_medium"]`},
+	}
+	for _, s := range sets {
+		if err := opts.Set(s[0], s[1]); err != nil {
+			t.Fatalf("Set(%q, %q) error = %v", s[0], s[1], err)
+		}
+	}
+
+	want := CrawlOptions{
+		WorkerCount:         20,
+		Normalization:       opts.Normalization,
+		Headers:             map[string]string{"X-Token": "42"},
+		Profiles:            map[string]DomainProfile{"api.example.com": {User: "admin", RateLimit: 0.5}},
+		AllowedDomains:      []string{"a.com", "b.com"},
+		IgnoreGETParameters: []string{"utm_source", "utm_medium"},
+	}
+	if !reflect.DeepEqual(opts, want) || !opts.Normalization.LowercaseHost {
+		t.Errorf("Set() = %+v, want %+v", opts, want)
+	}
+
+	invalid := [][2]string{
+		{"unknown", "1"},
+		{"worker-count", "many"},
+		{"worker-count.x", "1"},
+		{`profiles."api.example.com`, "1"},
+		{"headers..x", "1"},
+	}
+	for _, s := range invalid {
+		if err := opts.Set(s[0], s[1]); err == nil {
+			t.Errorf("Set(%q, %q) succeeded", s[0], s[1])
+		}
+	}
+}
+
+func TestReadTomlOptions(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "pass"), []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("BRINK_USER", "admin")
+
+	conf := filepath.Join(dir, "conf.toml")
+	if err := ioutil.WriteFile(conf, []byte("user = \"${BRINK_USER}\"\npass = \"file:pass\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := ReadTomlOptions(conf)
+	if err != nil {
+		t.Fatalf("ReadTomlOptions() error = %v", err)
+	}
+
+	if opts.User != "admin" || opts.Pass != "s3cret" {
+		t.Errorf("ReadTomlOptions() user = %q, pass = %q", opts.User, opts.Pass)
+	}
+
+	if _, err := ReadTomlOptions(filepath.Join(os.TempDir(), "brink-missing.toml")); err == nil {
+		t.Errorf("ReadTomlOptions() read a missing file")
+	}
+}
+
+func Test_decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"valid", "worker-count = 2\n[profiles.\"api.example.com\"]\nuser = \"api\"\n", ""},
+		{"unknown keys", "wroker-count = 2\n\n[normalization]\nlowercase-host = true\nlowercase-hots = true\n", "line 1: wroker-count: unknown option\nline 5: normalization.lowercase-hots: unknown option"},
+		{"type errors", "worker-count = \"2\"\n[[seeds]]\nurl = \"http://example.com\"\n[[seeds]]\nurl = 5\n", "line 1: worker-count: expected integer, found string\nline 5: seeds[1].url: expected string, found integer"},
+		{"integer for float", "rate-limit = 2\n[cookies.session]\nName = \"session\"\nExpires = \"2030-01-01T00:00:00Z\"\n", ""},
+		{"invalid datetime", "[cookies.session]\nExpires = \"tomorrow\"\n", `line 2: cookies.session.Expires: expected datetime, found string "tomorrow"`},
+		{"table expected", "[[cookies]]\nName = \"session\"\n", "line 1: cookies: expected table, found array of tables"},
+		{"inline table", "headers = { X-Token = 42 }\n", "line 1: headers.X-Token: expected string, found integer"},
+		{"alias conflict", "ignore-path-visits = [\"/a\"]\nforbidden-paths = [\"/b\"]\n", "line 1: ignore-path-visits: can not be set along with forbidden-paths, which replaces it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts CrawlOptions
+
+			err := decode([]byte(tt.doc), parseToml, &opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("decode() error = %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("decode() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+
+	var opts CrawlOptions
+	if err := decode([]byte("worker-count = 2\nignore-path-visits = [\"/admin\"]\n"), parseToml, &opts); err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+
+	if opts.WorkerCount != 2 || !reflect.DeepEqual(opts.ForbiddenPaths, []string{"/admin"}) {
+		t.Errorf("decode() = %+v, want the forbidden paths set by their former key", opts)
+	}
+}
+
+func Test_keyLines(t *testing.T) {
+	doc := `entrypoint = "http://example.com"
+description = """
+not = "a key"
+"""
+
+[profiles."api.example.com"]
+  user = 'api' # comment
+
+[[seeds]]
+url = "/a"
+[[seeds]]
+url = "/b"
+`
+
+	want := map[string]int{
+		"entrypoint":                      1,
+		"description":                     2,
+		"profiles":                        6,
+		`profiles."api.example.com"`:      6,
+		`profiles."api.example.com".user`: 7,
+		"seeds":                           9,
+		"seeds[0]":                        9,
+		"seeds[0].url":                    10,
+		"seeds[1]":                        11,
+		"seeds[1].url":                    12,
+	}
+	if got := keyLines(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("keyLines() = %v, want %v", got, want)
+	}
+
+	if got := lineOf(want, `profiles."api.example.com".headers.X`); got != 6 {
+		t.Errorf("lineOf() = %d, want the line of the profile", got)
+	}
+}
+
+func TestCrawlOptions_Validate(t *testing.T) {
+	valid := CrawlOptions{EntryPoint: "http://example.com"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	invalid := CrawlOptions{
+		AuthType:         3,
+		WorkerCount:      -1,
+		MaxContentLength: -2,
+		Proxy:            "localhost:3128",
+		AdaptiveWorkers:  AdaptiveWorkers{MinWorkers: 41},
+		Seeds:            []Seed{{URL: "http://example.com"}, {URL: "example"}},
+		Cookies:          map[string]*http.Cookie{"session": {}},
+		Forms:            FormOptions{MaxSubmissions: -1},
+		Profiles:         map[string]DomainProfile{"api.example.com": {RateLimit: -1}},
+	}
+
+	want := []string{
+		"entrypoint: must be set",
+		"auth-type: unknown auth type 3",
+		"worker-count: must not be negative",
+		"max-content-length: must be -1 for unlimited, 0 for the default or a size",
+		`proxy: invalid proxy "localhost:3128"`,
+		"adaptive-workers: min workers (41) is greater than max workers (40)",
+		`seeds[1]: invalid url "example": parse "example": invalid URI for request`,
+		"cookies.session: missing name",
+		"forms: max submissions must not be negative",
+		`profiles."api.example.com": rate limit and timeout must not be negative`,
+	}
+
+	errs, ok := invalid.Validate().(ConfigErrors)
+	if !ok {
+		t.Fatalf("Validate() did not return ConfigErrors")
+	}
+
+	got := make([]string, len(errs))
+	for i, e := range errs {
+		got[i] = e.Error()
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %q, want %q", got, want)
+	}
+}
+
+func TestCrawlOptions_WithDefaults(t *testing.T) {
+	got := CrawlOptions{WorkerCount: 3, MaxContentLength: -1}.WithDefaults()
+
+	if got.WorkerCount != 3 || got.MaxContentLength != -1 || got.URLBufferSize != defaultURLBufferSize ||
+		got.AdaptiveWorkers.MaxWorkers != 12 || got.Forms.MaxSubmissions != defaultMaxFormSubmissions {
+		t.Errorf("WithDefaults() = %+v", got)
+	}
+}
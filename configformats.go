@@ -0,0 +1,288 @@
+package brink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses the yaml document, following its anchors and merge
+// keys.
+func parseYAML(doc []byte) (map[string]interface{}, map[string]int, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(doc, &root); err != nil {
+		return nil, nil, err
+	}
+
+	lines := make(map[string]int)
+	if root.Kind == 0 {
+		return map[string]interface{}{}, lines, nil
+	}
+
+	v, err := yamlValue(&root, "", lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("line %d: expected a mapping of options", root.Line)
+	}
+
+	return raw, lines, nil
+}
+
+// yamlValue returns the value of the node in the form decoded from toml
+// documents, adding the lines of the keys below it to lines. Null values
+// are returned as nil.
+func yamlValue(n *yaml.Node, key string, lines map[string]int) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+
+		return yamlValue(n.Content[0], key, lines)
+	case yaml.AliasNode:
+		return yamlValue(n.Alias, key, lines)
+	case yaml.MappingNode:
+		table := make(map[string]interface{})
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+
+			if k.Tag == "!!merge" {
+				if err := mergeYAML(table, v, key, lines); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			path := joinPath(key, k.Value)
+			lines[path] = k.Line
+
+			value, err := yamlValue(v, path, lines)
+			if err != nil {
+				return nil, err
+			}
+
+			if value != nil {
+				table[k.Value] = value
+			}
+		}
+
+		return table, nil
+	case yaml.SequenceNode:
+		list := make([]interface{}, len(n.Content))
+
+		for i, e := range n.Content {
+			path := fmt.Sprintf("%s[%d]", key, i)
+			lines[path] = e.Line
+
+			value, err := yamlValue(e, path, lines)
+			if err != nil {
+				return nil, err
+			}
+
+			list[i] = value
+		}
+
+		return arrayValue(list), nil
+	case yaml.ScalarNode:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n.Line, err)
+		}
+
+		switch t := v.(type) {
+		case int:
+			return int64(t), nil
+		case uint64:
+			return nil, fmt.Errorf("line %d: integer %d is too large", n.Line, t)
+		}
+
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("line %d: unexpected yaml node", n.Line)
+}
+
+// mergeYAML adds the keys of the mappings merged by a "<<" key to the table,
+// except for the ones set already.
+func mergeYAML(table map[string]interface{}, n *yaml.Node, key string, lines map[string]int) error {
+	merged := []*yaml.Node{n}
+	if n.Kind == yaml.SequenceNode {
+		merged = n.Content
+	}
+
+	for _, m := range merged {
+		v, err := yamlValue(m, key, lines)
+		if err != nil {
+			return err
+		}
+
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("line %d: only mappings can be merged", m.Line)
+		}
+
+		for k, value := range values {
+			if _, ok := table[k]; !ok {
+				table[k] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseJSON parses the json document.
+func parseJSON(doc []byte) (map[string]interface{}, map[string]int, error) {
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		if se, ok := err.(*json.SyntaxError); ok {
+			return nil, nil, fmt.Errorf("line %d: %v", lineAt(doc, se.Offset), err)
+		}
+
+		return nil, nil, err
+	}
+
+	value, err := jsonValue(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("expected an object of options")
+	}
+
+	lines := make(map[string]int)
+	if err := jsonLines(json.NewDecoder(bytes.NewReader(doc)), doc, "", lines); err != nil {
+		return nil, nil, err
+	}
+
+	return raw, lines, nil
+}
+
+// jsonValue returns the decoded json value in the form decoded from toml
+// documents. Null values are returned as nil.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			value, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+
+			if value != nil {
+				table[k] = value
+			}
+		}
+
+		return table, nil
+	case []interface{}:
+		list := make([]interface{}, len(t))
+		for i, e := range t {
+			value, err := jsonValue(e)
+			if err != nil {
+				return nil, err
+			}
+
+			list[i] = value
+		}
+
+		return arrayValue(list), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+
+		f, err := t.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t)
+		}
+
+		return f, nil
+	}
+
+	return v, nil
+}
+
+// jsonLines adds the lines of the keys of the json value read next by the
+// decoder to lines.
+func jsonLines(d *json.Decoder, doc []byte, key string, lines map[string]int) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for d.More() {
+			tok, err := d.Token()
+			if err != nil {
+				return err
+			}
+
+			path := joinPath(key, tok.(string))
+			lines[path] = lineAt(doc, d.InputOffset())
+
+			if err := jsonLines(d, doc, path, lines); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; d.More(); i++ {
+			if err := jsonLines(d, doc, fmt.Sprintf("%s[%d]", key, i), lines); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	// The closing delimiter
+	if _, err := d.Token(); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+// lineAt returns the line of the offset in the document.
+func lineAt(doc []byte, offset int64) int {
+	if offset > int64(len(doc)) {
+		offset = int64(len(doc))
+	}
+
+	return bytes.Count(doc[:offset], []byte("\n")) + 1
+}
+
+// arrayValue returns the list as an array of tables if all of its elements
+// are tables, as decoded from toml documents.
+func arrayValue(list []interface{}) interface{} {
+	if len(list) == 0 {
+		return list
+	}
+
+	tables := make([]map[string]interface{}, len(list))
+	for i, e := range list {
+		table, ok := e.(map[string]interface{})
+		if !ok {
+			return list
+		}
+
+		tables[i] = table
+	}
+
+	return tables
+}
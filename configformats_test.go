@@ -0,0 +1,143 @@
+package brink
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	tomlConfig = `entrypoint = "http://example.com"
+worker-count = 4
+rate-limit = 2
+allowed-domains = ["*.example.com"]
+
+[headers]
+X-Token = "42"
+
+[[seeds]]
+url = "http://example.com/api/search"
+method = "POST"
+body = '{"q": ""}'
+
+[profiles."api.example.com"]
+user = "api"
+timeout = 500
+
+[profiles."v2.api.example.com"]
+user = "api"
+timeout = 500
+
+[cookies.session]
+Name = "session"
+Expires = 2030-01-01T00:00:00Z
+`
+
+	yamlConfig = `entrypoint: http://example.com
+worker-count: 4
+rate-limit: 2
+allowed-domains: ["*.example.com"]
+headers:
+  X-Token: "42"
+seeds:
+  - url: http://example.com/api/search
+    method: POST
+    body: '{"q": ""}'
+profiles:
+  api.example.com: &api
+    user: api
+    timeout: 500
+  v2.api.example.com:
+    <<: *api
+cookies:
+  session:
+    Name: session
+    Expires: 2030-01-01T00:00:00Z
+`
+
+	jsonConfig = `{
+	"entrypoint": "http://example.com",
+	"worker-count": 4,
+	"rate-limit": 2,
+	"allowed-domains": ["*.example.com"],
+	"headers": {"X-Token": "42"},
+	"seeds": [{"url": "http://example.com/api/search", "method": "POST", "body": "{\"q\": \"\"}"}],
+	"profiles": {"api.example.com": {"user": "api", "timeout": 500}, "v2.api.example.com": {"user": "api", "timeout": 500}},
+	"proxy": null,
+	"cookies": {"session": {"Name": "session", "Expires": "2030-01-01T00:00:00Z"}}
+}`
+)
+
+func TestReadOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	read := func(name, contents string) (CrawlOptions, error) {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return ReadOptions(filename)
+	}
+
+	want, err := read("brink.toml", tomlConfig)
+	if err != nil {
+		t.Fatalf("ReadOptions() error = %v", err)
+	}
+
+	if want.RateLimit != 2 || want.Profiles["api.example.com"].Timeout != 500 || want.Cookies["session"].Expires.Year() != 2030 {
+		t.Fatalf("ReadOptions() = %+v", want)
+	}
+
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"brink.yaml", yamlConfig},
+		{"brink.yml", yamlConfig},
+		{"brink.json", jsonConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := read(tt.name, tt.contents)
+			if err != nil {
+				t.Fatalf("ReadOptions() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadOptions() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := read("brink.ini", ""); err == nil {
+		t.Errorf("ReadOptions() accepted an unknown format")
+	}
+}
+
+func Test_decodeFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   parser
+		doc     string
+		wantErr string
+	}{
+		{"yaml", parseYAML, "entrypoint: http://example.com\nwroker-count: 2\nseeds:\n  - url: http://example.com\n  - url: 5\n",
+			"line 2: wroker-count: unknown option\nline 5: seeds[1].url: expected string, found integer"},
+		{"yaml syntax", parseYAML, "entrypoint: [\n", "failed decoding file: yaml: line 1: did not find expected node content"},
+		{"yaml list", parseYAML, "- entrypoint\n", "failed decoding file: line 1: expected a mapping of options"},
+		{"json", parseJSON, "{\n  \"entrypoint\": \"http://example.com\",\n  \"normalization\": {\n    \"lowercase-hots\": true\n  }\n}",
+			"line 4: normalization.lowercase-hots: unknown option"},
+		{"json syntax", parseJSON, "{\n  \"entrypoint\": \"http://example.com\"\n  \"worker-count\": 2\n}", "failed decoding file: line 3: invalid character '\"' after object key:value pair"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts CrawlOptions
+
+			if err := decode([]byte(tt.doc), tt.parse, &opts); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("decode() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
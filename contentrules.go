@@ -0,0 +1,126 @@
+package brink
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// ContentRule is an audit rule reporting the pages whose body contains, or
+// does not contain, a text or a regular expression, e.g. the pages saying
+// that the linked wiki article does not exist. Like the other audit rules,
+// content rules check the HTML pages fetched with a 200 status.
+type ContentRule struct {
+	// Name identifies the rule in the findings.
+	Name string `toml:"name" yaml:"name" json:"name"`
+
+	// Contains is the text looked for in the body of the pages.
+	Contains string `toml:"contains" yaml:"contains" json:"contains"`
+
+	// Pattern is the regular expression looked for in the body of the pages, instead of
+	// Contains.
+	Pattern string `toml:"pattern" yaml:"pattern" json:"pattern"`
+
+	// Absent makes the rule report the pages not containing the text instead of the ones
+	// containing it.
+	Absent bool `toml:"absent" yaml:"absent" json:"absent"`
+
+	// URLPattern is a regular expression limiting the rule to the pages whose url matches it.
+	URLPattern string `toml:"url-pattern" yaml:"url-pattern" json:"url-pattern"`
+
+	// Severity of the findings, one of "error", "warning" or "info". Defaults to "warning".
+	Severity string `toml:"severity" yaml:"severity" json:"severity"`
+
+	// Message of the findings. Defaults to one naming the text looked for.
+	Message string `toml:"message" yaml:"message" json:"message"`
+}
+
+func (cr ContentRule) validate() error {
+	if cr.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+
+	if builtinAuditRule(cr.Name) {
+		return fmt.Errorf("%s is the name of a built-in rule", cr.Name)
+	}
+
+	if (cr.Contains == "") == (cr.Pattern == "") {
+		return fmt.Errorf("exactly one of contains and pattern must be set")
+	}
+
+	for _, p := range []string{cr.Pattern, cr.URLPattern} {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+	}
+
+	switch cr.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityError:
+	default:
+		return fmt.Errorf("unknown severity %q", cr.Severity)
+	}
+
+	return nil
+}
+
+// contentRule is the compiled form of a ContentRule.
+type contentRule struct {
+	ContentRule
+
+	pattern    *regexp.Regexp
+	urlPattern *regexp.Regexp
+}
+
+// newContentRule returns the AuditRule of the ContentRule, which must be
+// valid.
+func newContentRule(cr ContentRule) AuditRule {
+	rule := contentRule{ContentRule: cr}
+
+	if cr.Pattern != "" {
+		rule.pattern = regexp.MustCompile(cr.Pattern)
+	}
+
+	if cr.URLPattern != "" {
+		rule.urlPattern = regexp.MustCompile(cr.URLPattern)
+	}
+
+	if rule.Severity == "" {
+		rule.Severity = SeverityWarning
+	}
+
+	if rule.Message == "" {
+		what := fmt.Sprintf("%q", cr.Contains)
+		if cr.Pattern != "" {
+			what = fmt.Sprintf("a match of %q", cr.Pattern)
+		}
+
+		if cr.Absent {
+			rule.Message = fmt.Sprintf("page does not contain %s", what)
+		} else {
+			rule.Message = fmt.Sprintf("page contains %s", what)
+		}
+	}
+
+	return rule
+}
+
+func (cr contentRule) Name() string { return cr.ContentRule.Name }
+
+func (cr contentRule) Check(p *Page, r Result) []Finding {
+	if cr.urlPattern != nil && !cr.urlPattern.MatchString(r.URL) {
+		return nil
+	}
+
+	var found bool
+	if cr.pattern != nil {
+		found = cr.pattern.Match(p.Body)
+	} else {
+		found = bytes.Contains(p.Body, []byte(cr.Contains))
+	}
+
+	if found == cr.Absent {
+		return nil
+	}
+
+	return []Finding{{URL: r.URL, Rule: cr.ContentRule.Name, Severity: cr.Severity, Message: cr.Message, LinkedFrom: r.LinkedFrom}}
+}
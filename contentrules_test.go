@@ -0,0 +1,109 @@
+package brink
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContentRule_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ContentRule
+		wantErr bool
+	}{
+		{"contains", ContentRule{Name: "missing-article", Contains: "create it"}, false},
+		{"pattern", ContentRule{Name: "stack-trace", Pattern: `at [\w.]+\(`, URLPattern: "/wiki/", Severity: SeverityError}, false},
+		{"missingName", ContentRule{Contains: "create it"}, true},
+		{"builtinName", ContentRule{Name: RuleMissingTitle, Contains: "create it"}, true},
+		{"nothingToLookFor", ContentRule{Name: "empty"}, true},
+		{"bothContainsAndPattern", ContentRule{Name: "both", Contains: "a", Pattern: "b"}, true},
+		{"invalidPattern", ContentRule{Name: "invalid", Pattern: "("}, true},
+		{"invalidURLPattern", ContentRule{Name: "invalid", Contains: "a", URLPattern: "["}, true},
+		{"unknownSeverity", ContentRule{Name: "severe", Contains: "a", Severity: "fatal"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_contentRule_Check(t *testing.T) {
+	body := []byte(`<html><body><p>This page does not exist yet. Use the buttons below to create it.</p></body></html>`)
+
+	tests := []struct {
+		name string
+		rule ContentRule
+		url  string
+		want []Finding
+	}{
+		{
+			name: "contains",
+			rule: ContentRule{Name: "missing-article", Contains: "Use the buttons below to create it"},
+			url:  "https://liferay.com/wiki/a",
+			want: []Finding{{URL: "https://liferay.com/wiki/a", Rule: "missing-article", Severity: SeverityWarning, Message: `page contains "Use the buttons below to create it"`, LinkedFrom: "https://liferay.com/wiki"}},
+		},
+		{
+			name: "pattern",
+			rule: ContentRule{Name: "not-existing", Pattern: `does not\s+exist`, Severity: SeverityError, Message: "linked page does not exist"},
+			url:  "https://liferay.com/wiki/a",
+			want: []Finding{{URL: "https://liferay.com/wiki/a", Rule: "not-existing", Severity: SeverityError, Message: "linked page does not exist", LinkedFrom: "https://liferay.com/wiki"}},
+		},
+		{
+			name: "notContained",
+			rule: ContentRule{Name: "error-page", Contains: "Internal error"},
+			url:  "https://liferay.com/wiki/a",
+		},
+		{
+			name: "absent",
+			rule: ContentRule{Name: "missing-footer", Contains: "<footer", Absent: true},
+			url:  "https://liferay.com/wiki/a",
+			want: []Finding{{URL: "https://liferay.com/wiki/a", Rule: "missing-footer", Severity: SeverityWarning, Message: `page does not contain "<footer"`, LinkedFrom: "https://liferay.com/wiki"}},
+		},
+		{
+			name: "otherURL",
+			rule: ContentRule{Name: "missing-article", Contains: "create it", URLPattern: "/wiki/"},
+			url:  "https://liferay.com/blog/a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Result{URL: tt.url, LinkedFrom: "https://liferay.com/wiki"}
+			if got := newContentRule(tt.rule).Check(NewPage(tt.url, body), r); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_contentRules(t *testing.T) {
+	ts := testSite(map[string]string{
+		"/":       `<html><body><a href="/wiki/a">A</a> <a href="/wiki/b">B</a></body></html>`,
+		"/wiki/a": `<html><body>Article A</body></html>`,
+		"/wiki/b": `<html><body>Use the buttons below to create it.</body></html>`,
+	})
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{
+		Audit: AuditOptions{ContentRules: []ContentRule{{Name: "missing-article", Contains: "create it"}}},
+	})
+	c.HandleResultFunc(func(r Result) {})
+
+	waitDone(t, startAsync(t, c))
+
+	var got []string
+	for _, f := range c.AuditFindings() {
+		got = append(got, strings.TrimPrefix(f.URL, ts.URL)+" "+f.Rule)
+
+		if f.LinkedFrom != ts.URL {
+			t.Errorf("finding of %s linked from %q, want %q", f.URL, f.LinkedFrom, ts.URL)
+		}
+	}
+
+	if want := []string{"/wiki/b missing-article"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditFindings() = %v, want %v", got, want)
+	}
+}
@@ -1,7 +1,9 @@
 package brink
 
 import (
+	"io"
 	"net/http"
+	"regexp"
 	"sync"
 
 	"github.com/djavorszky/brink/store"
@@ -14,6 +16,9 @@ const (
 	AuthBasic
 )
 
+// seedReferrer is the LinkedFrom of the links the crawl is started with.
+const seedReferrer = "start"
+
 // Crawler represents a web crawler, starting from a RootDomain
 // and visiting all the links in the AllowedDomains map. It will only
 // download the body of an URL if it is less than MaxContentLength.
@@ -25,9 +30,20 @@ type Crawler struct {
 	// Handlers...
 	defaultHandler func(linkedFrom string, url string, status int, body string, cached bool)
 	handlers       map[int]func(linkedFrom string, url string, status int, body string, cached bool)
+	resultHandler  func(r Result)
+
+	// middleware changes the requests before they are sent.
+	middleware []RequestMiddleware
+
+	// parsers find the links in the pages, keyed by media type
+	parsers map[string]Parser
 
-	// workers state
-	workersRunning []*bool
+	// workers state, guarded by wmu
+	wmu       sync.Mutex
+	wg        sync.WaitGroup
+	workers   []*worker
+	workerSeq int
+	started   bool
 
 	// urls is the channel from which the workers will receive the URLs
 	// to process.
@@ -41,61 +57,203 @@ type Crawler struct {
 	ignoredGETParams store.CStore
 	forbiddenPaths   store.CStore
 
+	// canonicalURLs holds the canonical urls of the pages whose links
+	// have already been followed.
+	canonicalURLs store.CStore
+
+	// inflight holds a channel for each url being fetched, which is closed
+	// once the url is stored among the visited ones.
+	inflight sync.Map
+
+	// unfollowed holds the keys of the pages visited only through nofollow
+	// links or check-only requests, whose links have not been followed,
+	// mapped to whether the page is yet to be audited.
+	unfollowed sync.Map
+
+	logger Logger
+
+	// stats collects the statistics of the crawl.
+	stats *stats
+
+	// adaptive collects the results of the allowed domains the worker pool
+	// is resized by, if adaptive workers are enabled.
+	adaptive *signals
+
+	// graph holds all the links found during the crawl.
+	graph *LinkGraph
+
+	// fragments collects the anchors of the pages and the links pointing
+	// to them. It is nil if fragment validation is disabled.
+	fragments *fragments
+
+	// duplicates holds the content fingerprints of the visited pages. It
+	// is nil if duplicate detection is disabled.
+	duplicates *duplicates
+
+	// forms collects the forms found on the pages. It is nil if form
+	// discovery is disabled. postPatterns holds the compiled patterns of
+	// the actions of the POST forms which may be submitted.
+	forms        *forms
+	postPatterns []*regexp.Regexp
+
+	// renderer renders the HTML pages before their links are extracted.
+	// ownRenderer is set if the crawler created the renderer itself, so
+	// that it can be stopped once the crawl is over.
+	renderer      Renderer
+	ownRenderer   io.Closer
+	renderDomains []domainRule
+
+	// audit runs the audit rules on the fetched pages. It is nil if
+	// auditing is disabled.
+	audit *auditor
+
+	// profiles override the request settings for the domains matching
+	// them, ordered from the most to the least specific. limiters holds
+	// the rate limiter of each host, if the requests are limited.
+	profiles []*domainProfile
+	limiters sync.Map
+
+	// domainRules holds the parsed form of the entries in allowedDomains.
+	dmu         sync.RWMutex
+	domainRules []domainRule
+
+	// pmu guards the paused and stopping states, and pcond is used to
+	// wake the paused workers up.
+	pmu      sync.Mutex
+	pcond    *sync.Cond
+	paused   bool
 	stopping bool
+
+	// done is closed when the crawler is stopped.
+	done chan struct{}
 }
 
 // CrawlOptions contains options for the crawler
 type CrawlOptions struct {
-	AuthType int    `toml:"auth-type"`
-	User     string `toml:"user"`
-	Pass     string `toml:"pass"`
+	// AuthType, User and Pass configure the authentication. The credentials are only sent
+	// to the allowed domains.
+	AuthType int    `toml:"auth-type" yaml:"auth-type" json:"auth-type"`
+	User     string `toml:"user" yaml:"user" json:"user"`
+	Pass     string `toml:"pass" yaml:"pass" json:"pass"`
+
+	// UserAgent is sent in the User-Agent header of the requests, if set.
+	UserAgent string `toml:"user-agent" yaml:"user-agent" json:"user-agent"`
+
+	// RateLimit is the most number of requests per second sent to each host. Setting it to 0
+	// does not limit the requests.
+	RateLimit float64 `toml:"rate-limit" yaml:"rate-limit" json:"rate-limit"`
+
+	// Timeout is the time in milliseconds a request may take. Setting it to 0 does not limit
+	// the requests.
+	Timeout int `toml:"timeout" yaml:"timeout" json:"timeout"`
+
+	// Proxy is the url of the proxy to send the requests through, e.g. "http://proxy:3128".
+	// If empty, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables.
+	Proxy string `toml:"proxy" yaml:"proxy" json:"proxy"`
 
 	// URLBufferSize is the amount of URLs that can be waiting to be visited.
-	URLBufferSize int `toml:"url-buffer-size"`
+	URLBufferSize int `toml:"url-buffer-size" yaml:"url-buffer-size" json:"url-buffer-size"`
 
 	// WorkerCount specifies the number of goroutines that will work on crawling the domains.
-	WorkerCount int `toml:"worker-count"`
+	WorkerCount int `toml:"worker-count" yaml:"worker-count" json:"worker-count"`
 
 	// IdleWorkCheckInterval configures how frequently the crawler checks if there is any work
 	// to do. If there is no url to be processed, it will gracefully stop itself. Setting it to
 	// 0 will use the default value of 5000 milliseconds.
-	IdleWorkCheckInterval int `toml:"idle-work-check-interval"`
+	IdleWorkCheckInterval int `toml:"idle-work-check-interval" yaml:"idle-work-check-interval" json:"idle-work-check-interval"`
+
+	// AdaptiveWorkers configures the resizing of the worker pool based on the latency of
+	// the requests and the errors returned by the servers.
+	AdaptiveWorkers AdaptiveWorkers `toml:"adaptive-workers" yaml:"adaptive-workers" json:"adaptive-workers"`
 
 	// MaxContentLength specifies the maximum size of pages to be crawled. Setting it to 0
 	// will default to 512Kb. Set it to -1 to allow unlimited size
-	MaxContentLength int64 `toml:"max-content-length"`
+	MaxContentLength int64 `toml:"max-content-length" yaml:"max-content-length" json:"max-content-length"`
 
 	// Entrypoint is the first url that will be fetched.
-	EntryPoint string `toml:"entrypoint"`
+	EntryPoint string `toml:"entrypoint" yaml:"entrypoint" json:"entrypoint"`
+
+	// Seeds are requests the crawl is started with in addition to the entrypoint, e.g. POST
+	// requests to the listing endpoints of APIs.
+	Seeds []Seed `toml:"seeds" yaml:"seeds" json:"seeds"`
 
 	// AllowedDomains will be used to check whether a domain is allowed to be crawled or not.
-	AllowedDomains []string `toml:"allowed-domains"`
+	// Entries have the form of [scheme://][*.]host[:port]. Leaving out the scheme allows
+	// any scheme, leaving out the port allows the default port of the scheme, and prefixing
+	// the host with "*." allows the domain along with all of its subdomains.
+	AllowedDomains []string `toml:"allowed-domains" yaml:"allowed-domains" json:"allowed-domains"`
 
-	// Cookies holds a list of cookies to be added to all requests in addition to the one
-	// sent by the servers
-	Cookies map[string]*http.Cookie `toml:"cookies"`
+	// Cookies holds a list of cookies to be added to the requests in addition to the ones
+	// sent by the servers of the allowed domains. Cookies with a Domain are only sent to
+	// that domain and its subdomains, the ones without to the allowed domains only.
+	Cookies map[string]*http.Cookie `toml:"cookies" yaml:"cookies" json:"cookies"`
 
-	// Headers holds a mapping for key->values to be added to all requests
-	Headers map[string]string `toml:"headers"`
+	// Headers holds a mapping for key->values to be added to the requests to the allowed
+	// domains. As they may hold credentials, e.g. API keys, they are not sent to other hosts;
+	// the headers of those can be set by Profiles.
+	Headers map[string]string `toml:"headers" yaml:"headers" json:"headers"`
 
 	// Ignore certain GET parameters when comparing whether an URL has been visited or not
-	IgnoreGETParameters []string `toml:"ignore-get-parameters"`
+	IgnoreGETParameters []string `toml:"ignore-get-parameters" yaml:"ignore-get-parameters" json:"ignore-get-parameters"`
 
 	// FuzzyGETParameterChecks will decide whether to try to do exact matches for parameters.
 	// If set to false, GET parameters are only ignored if they are an exact match. If set
 	// to true, they are checked with a substring fashion.
-	FuzzyGETParameterChecks bool `toml:"fuzzy-get-parameter-checks"`
+	FuzzyGETParameterChecks bool `toml:"fuzzy-get-parameter-checks" yaml:"fuzzy-get-parameter-checks" json:"fuzzy-get-parameter-checks"`
 
 	// Ignore certain URL Paths. URLs containing Paths that contain sections that are specified
-	// in this list will not be visited.
-	ForbiddenPaths []string `toml:"ignore-path-visits"`
+	// in this list will not be visited. The former "ignore-path-visits" key is still accepted.
+	ForbiddenPaths []string `toml:"forbidden-paths" yaml:"forbidden-paths" json:"forbidden-paths"`
 
 	// SessionCookieNames holds all the cookie names that can represent a sessionId. It is
 	// necessary in order to check whether authorization has been successful to make sure
 	// not to try and re-authorize on every request.
-	SessionCookieNames []string `toml:"session-cookie-names"`
+	SessionCookieNames []string `toml:"session-cookie-names" yaml:"session-cookie-names" json:"session-cookie-names"`
+
+	// Normalization configures how URLs are normalized before checking whether they have
+	// been visited or not.
+	Normalization URLNormalization `toml:"normalization" yaml:"normalization" json:"normalization"`
+
+	// ValidateFragments makes the crawler check whether the fragments (e.g. "#section") of
+	// links exist on their target pages as either an id, or the name of an anchor. Both in-page
+	// and cross-page links are checked.
+	ValidateFragments bool `toml:"validate-fragments" yaml:"validate-fragments" json:"validate-fragments"`
+
+	// Duplicates configures the detection of pages having the same or nearly the same
+	// content under different URLs.
+	Duplicates DuplicateOptions `toml:"duplicates" yaml:"duplicates" json:"duplicates"`
+
+	// IgnoreRobotsDirectives makes the crawler expand the links of pages marked nofollow by
+	// their robots meta tags or X-Robots-Tag headers, and of the pages linked by rel="nofollow"
+	// anchors. Otherwise such links are only visited to check their status.
+	IgnoreRobotsDirectives bool `toml:"ignore-robots-directives" yaml:"ignore-robots-directives" json:"ignore-robots-directives"`
+
+	// Forms configures the discovery of forms, and the submission of GET forms and of the
+	// POST forms explicitly allowed, to find the pages only reachable through them.
+	Forms FormOptions `toml:"forms" yaml:"forms" json:"forms"`
+
+	// JSON configures how links are found in JSON documents, e.g. the responses of hypermedia
+	// APIs, and the following of paginated responses.
+	JSON JSONOptions `toml:"json" yaml:"json" json:"json"`
+
+	// Rendering configures the rendering of the HTML pages in a headless browser, so that
+	// the links added by JavaScript are found as well.
+	Rendering RenderOptions `toml:"rendering" yaml:"rendering" json:"rendering"`
+
+	// Audit configures the audit rules checking the fetched HTML pages, e.g. for missing
+	// titles or mixed content.
+	Audit AuditOptions `toml:"audit" yaml:"audit" json:"audit"`
+
+	// Profiles override the headers, authentication, user agent, rate limit, timeout and
+	// proxy for the domains matching their keys, which have the same form as the entries of
+	// AllowedDomains. If several profiles match a url, the most specific one is used.
+	Profiles map[string]DomainProfile `toml:"profiles" yaml:"profiles" json:"profiles"`
+
+	// Logger is used to log the progress of the crawler. Leaving it nil discards the logs.
+	// A *slog.Logger can be used as is.
+	Logger Logger `toml:"-" yaml:"-" json:"-"`
 
 	// todo: add ctx
-	// todo: add proxy support
 	// todo: add beforeFunc and afterFunc
 }
@@ -0,0 +1,171 @@
+package brink
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// domainRule is a parsed entry of the allowed domains list. An entry
+// has the form of [scheme://][*.]host[:port], e.g.
+//
+//	https://example.com    - https only, on the default port
+//	example.com            - any scheme, on the scheme's default port
+//	*.example.com          - example.com and all of its subdomains
+//	http://example.com:8080 - http only, on port 8080
+type domainRule struct {
+	// scheme is empty if the rule matches any scheme.
+	scheme string
+
+	// host is lowercased and converted to its ASCII (punycode) form.
+	host string
+
+	// port is empty if the rule matches the default port of the
+	// visited url's scheme.
+	port string
+
+	// subdomains is true if the rule matches the subdomains of host.
+	subdomains bool
+}
+
+func parseDomainRule(entry string) (domainRule, error) {
+	var r domainRule
+
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return r, fmt.Errorf("empty domain")
+	}
+
+	if i := strings.Index(entry, "://"); i != -1 {
+		r.scheme = strings.ToLower(entry[:i])
+		entry = entry[i+3:]
+	}
+	entry = strings.TrimPrefix(entry, "//")
+
+	// Drop anything after the host, e.g. paths
+	if i := strings.IndexAny(entry, "/?#"); i != -1 {
+		entry = entry[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(entry, "*."):
+		r.subdomains = true
+		entry = entry[2:]
+	case strings.HasPrefix(entry, "."):
+		r.subdomains = true
+		entry = entry[1:]
+	}
+
+	host, port := splitHostPort(entry)
+
+	normalized, err := normalizeHost(host)
+	if err != nil {
+		return r, fmt.Errorf("invalid host %q: %v", host, err)
+	}
+
+	if normalized == "" {
+		return r, fmt.Errorf("missing host")
+	}
+
+	r.host = normalized
+	if port != "" && port != defaultPort(r.scheme) {
+		r.port = port
+	}
+
+	return r, nil
+}
+
+// matches reports whether the url is covered by the rule.
+func (r domainRule) matches(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	if r.scheme != "" && r.scheme != scheme {
+		return false
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	if host != r.host && !(r.subdomains && strings.HasSuffix(host, "."+r.host)) {
+		return false
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(scheme)
+	}
+
+	if r.port == "" {
+		return port == defaultPort(scheme)
+	}
+
+	return port == r.port
+}
+
+// String returns the canonical representation of the rule, which
+// is also the key under which the rule is stored in the crawler.
+func (r domainRule) String() string {
+	var b strings.Builder
+
+	if r.scheme != "" {
+		b.WriteString(r.scheme)
+		b.WriteString("://")
+	}
+
+	if r.subdomains {
+		b.WriteString("*.")
+	}
+
+	b.WriteString(r.host)
+
+	if r.port != "" {
+		b.WriteString(":")
+		b.WriteString(r.port)
+	}
+
+	return b.String()
+}
+
+// splitHostPort is like net.SplitHostPort, but it does not fail
+// if the port is missing.
+func splitHostPort(hostport string) (host, port string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), ""
+	}
+
+	return host, port
+}
+
+// normalizeHost lowercases the host and converts internationalized
+// domain names to their punycode form, so that "Example.COM" and
+// "bücher.example" can be compared to their canonical forms.
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+
+	return ascii, nil
+}
+
+func defaultPort(scheme string) string {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+
+	return ""
+}
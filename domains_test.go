@@ -0,0 +1,122 @@
+package brink
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_parseDomainRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{"scheme and host", "https://example.com", "https://example.com", false},
+		{"trailing path", "https://example.com/some/path", "https://example.com", false},
+		{"no scheme", "example.com", "example.com", false},
+		{"protocol relative", "//example.com", "example.com", false},
+		{"subdomains", "*.example.com", "*.example.com", false},
+		{"subdomains dot", ".example.com", "*.example.com", false},
+		{"uppercase", "HTTPS://Example.COM", "https://example.com", false},
+		{"default port", "https://example.com:443", "https://example.com", false},
+		{"explicit port", "http://example.com:8080", "http://example.com:8080", false},
+		{"idn", "https://bücher.example", "https://xn--bcher-kva.example", false},
+		{"ip with port", "http://127.0.0.1:8080", "http://127.0.0.1:8080", false},
+
+		{"empty", "", "", true},
+		{"missing host", "https://", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDomainRule(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDomainRule() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("parseDomainRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDomainRule_invalidHost(t *testing.T) {
+	_, err := parseDomainRule("https://exa_mple.com")
+	if err == nil || !strings.Contains(err.Error(), `"exa_mple.com"`) {
+		t.Errorf("parseDomainRule() error = %v, want one naming the host", err)
+	}
+}
+
+func Test_domainRule_matches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		url  string
+		want bool
+	}{
+		{"exact", "https://example.com", "https://example.com/path", true},
+		{"other scheme", "https://example.com", "http://example.com/path", false},
+		{"default port", "https://example.com", "https://example.com:443/path", true},
+		{"other port", "https://example.com", "https://example.com:8443/path", false},
+		{"subdomain not allowed", "https://example.com", "https://www.example.com", false},
+		{"any scheme http", "example.com", "http://example.com", true},
+		{"any scheme https", "example.com", "https://example.com", true},
+		{"any scheme default ports", "example.com", "http://example.com:443", false},
+		{"explicit port", "example.com:8080", "http://example.com:8080", true},
+		{"explicit port missing", "example.com:8080", "http://example.com", false},
+		{"subdomains apex", "*.example.com", "https://example.com", true},
+		{"subdomains www", "*.example.com", "https://www.example.com", true},
+		{"subdomains deep", "*.example.com", "http://docs.eu.example.com", true},
+		{"subdomains suffix only", "*.example.com", "https://notexample.com", false},
+		{"host case", "https://example.com", "https://WWW.Example.com", false},
+		{"host case apex", "https://example.com", "https://Example.COM", true},
+		{"idn unicode url", "bücher.example", "https://bücher.example", true},
+		{"idn punycode url", "bücher.example", "https://xn--bcher-kva.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseDomainRule(tt.rule)
+			if err != nil {
+				t.Fatalf("parseDomainRule() error = %v", err)
+			}
+
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("url.Parse() error = %v", err)
+			}
+
+			if got := rule.matches(u); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_domainAllowed(t *testing.T) {
+	c, _ := NewCrawlerWithOpts("https://www.example.com", CrawlOptions{
+		AllowedDomains: []string{"*.example.org", "localhost:8080"},
+	})
+
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{"root domain", "https://www.example.com/page", true},
+		{"root domain other scheme", "http://www.example.com/page", false},
+		{"subdomain", "https://docs.example.org/page", true},
+		{"local", "http://localhost:8080/", true},
+		{"not allowed", "https://example.net", false},
+		{"malformed", "example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.domainAllowed(tt.url); got != tt.want {
+				t.Errorf("domainAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package brink
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // NotAllowed error is returned by Fetch when a domain is not allowed
 // to be visited.
@@ -21,3 +24,51 @@ type ContentTooLarge struct {
 func (ctl ContentTooLarge) Error() string {
 	return fmt.Sprintf("content-length too large of url: %v", ctl.url)
 }
+
+// BufferFull error is returned by AddSeeds and AddSeedRequests when the url
+// buffer fills up. The seeds before the one that did not fit were added, and
+// are not to be added again.
+type BufferFull struct {
+	// Added is the number of seeds added before the buffer filled up.
+	Added int
+
+	// URL is the url of the first seed which was not added.
+	URL string
+}
+
+func (bf BufferFull) Error() string {
+	return fmt.Sprintf("no room for seed %q, url buffer is full after adding %d seeds", bf.URL, bf.Added)
+}
+
+// ConfigError describes a problem of an option of the configuration.
+type ConfigError struct {
+	// Key is the key of the option in configuration files, e.g. "worker-count" or
+	// `profiles."api.example.com".proxy`.
+	Key string
+
+	// Line is the line of the option in the configuration file, or 0 if it is not known.
+	Line int
+
+	Msg string
+}
+
+func (ce *ConfigError) Error() string {
+	if ce.Line != 0 {
+		return fmt.Sprintf("line %d: %s: %s", ce.Line, ce.Key, ce.Msg)
+	}
+
+	return fmt.Sprintf("%s: %s", ce.Key, ce.Msg)
+}
+
+// ConfigErrors is returned when the configuration has problems, listing
+// all of them.
+type ConfigErrors []*ConfigError
+
+func (ce ConfigErrors) Error() string {
+	msgs := make([]string, len(ce))
+	for i, e := range ce {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
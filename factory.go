@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"regexp"
+	"sync"
 
-	"github.com/BurntSushi/toml"
 	"github.com/djavorszky/brink/store"
 )
 
@@ -37,9 +38,14 @@ func NewCrawler(rootDomain string) (*Crawler, error) {
 		ignoredGETParams: store.New(),
 		reqHeaders:       store.New(),
 		forbiddenPaths:   store.New(),
+		canonicalURLs:    store.New(),
 		handlers:         make(map[int]func(linkedFrom string, url string, status int, body string, cached bool)),
-		workersRunning:   make([]*bool, defaultWorkerCount),
+		parsers:          defaultParsers(),
+		done:             make(chan struct{}),
 		client:           &http.Client{},
+		graph:            newLinkGraph(),
+		stats:            newStats(),
+		logger:           nopLogger{},
 		opts: CrawlOptions{
 			MaxContentLength:      defaultMaxContentLength,
 			URLBufferSize:         defaultURLBufferSize,
@@ -50,6 +56,7 @@ func NewCrawler(rootDomain string) (*Crawler, error) {
 	}
 
 	c.urls = make(chan Link, c.opts.URLBufferSize)
+	c.pcond = sync.NewCond(&c.pmu)
 
 	c.AllowDomains(rootDomainURL)
 
@@ -57,13 +64,26 @@ func NewCrawler(rootDomain string) (*Crawler, error) {
 }
 
 // NewCrawlerWithOpts returns a Crawler initialized with the provided CrawlOptions
-// struct.
+// struct. The options are checked by CrawlOptions.Validate, using the root domain
+// as the entrypoint if none is set.
 func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler, error) {
+	if userOptions.EntryPoint == "" {
+		userOptions.EntryPoint = rootDomain
+	}
+
+	if err := userOptions.Validate(); err != nil {
+		return nil, err
+	}
+	defaults := userOptions.WithDefaults()
+
 	c, err := NewCrawler(rootDomain)
 	if err != nil {
 		return nil, fmt.Errorf("failed creating new crawler: %v", err)
 	}
 
+	// Logger
+	c.SetLogger(userOptions.Logger)
+
 	// Headers
 	if userOptions.Headers != nil {
 		for k, v := range userOptions.Headers {
@@ -72,7 +92,7 @@ func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler,
 	}
 
 	// Domains
-	err = setupDomains(&c.allowedDomains, c.RootDomain, userOptions.AllowedDomains)
+	err = setupDomains(c, userOptions.AllowedDomains)
 	if err != nil {
 		return nil, fmt.Errorf("allowed domains setup: %v", err)
 	}
@@ -84,6 +104,9 @@ func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler,
 		}
 	}
 
+	// Seeds
+	c.opts.Seeds = userOptions.Seeds
+
 	// Session cookie names
 	if userOptions.SessionCookieNames != nil {
 		c.opts.SessionCookieNames = userOptions.SessionCookieNames
@@ -93,9 +116,7 @@ func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler,
 	c.opts.MaxContentLength = getMaxContentLength(userOptions.MaxContentLength)
 
 	// Idle check interval
-	if userOptions.IdleWorkCheckInterval > 0 {
-		c.opts.IdleWorkCheckInterval = userOptions.IdleWorkCheckInterval
-	}
+	c.opts.IdleWorkCheckInterval = defaults.IdleWorkCheckInterval
 
 	// Ignore GET Parameters
 	for _, v := range userOptions.IgnoreGETParameters {
@@ -113,6 +134,25 @@ func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler,
 		return nil, fmt.Errorf("failed setting up auth: %v", err)
 	}
 
+	// Client
+	c.opts.UserAgent = userOptions.UserAgent
+	c.opts.RateLimit = userOptions.RateLimit
+	c.opts.Timeout = userOptions.Timeout
+	c.opts.Proxy = userOptions.Proxy
+
+	if c.opts.Timeout != 0 || c.opts.Proxy != "" {
+		c.client, err = newClient(c.opts.Timeout, c.opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed setting up client: %v", err)
+		}
+	}
+
+	// Domain profiles
+	if err = setupProfiles(c, userOptions.Profiles); err != nil {
+		return nil, fmt.Errorf("profiles: %v", err)
+	}
+	c.opts.Profiles = userOptions.Profiles
+
 	// Make sure we overwrite the default channel size in case it is specified in the
 	// userOptions
 	if userOptions.URLBufferSize != 0 {
@@ -120,22 +160,83 @@ func NewCrawlerWithOpts(rootDomain string, userOptions CrawlOptions) (*Crawler,
 		c.urls = make(chan Link, userOptions.URLBufferSize)
 	}
 
-	if userOptions.WorkerCount > 0 {
-		c.opts.WorkerCount = userOptions.WorkerCount
-		c.workersRunning = make([]*bool, userOptions.WorkerCount)
+	c.opts.WorkerCount = defaults.WorkerCount
+
+	// Adaptive worker pool
+	c.opts.AdaptiveWorkers = defaults.AdaptiveWorkers
+	if c.opts.AdaptiveWorkers.Enabled {
+		c.adaptive = &signals{}
 	}
 
 	c.opts.FuzzyGETParameterChecks = userOptions.FuzzyGETParameterChecks
+	c.opts.IgnoreRobotsDirectives = userOptions.IgnoreRobotsDirectives
+
+	// URL normalization
+	c.opts.Normalization = userOptions.Normalization
+
+	// Fragment validation
+	c.opts.ValidateFragments = userOptions.ValidateFragments
+	if c.opts.ValidateFragments {
+		c.fragments = newFragments()
+	}
+
+	// Duplicate detection
+	c.opts.Duplicates = defaults.Duplicates
+
+	if c.opts.Duplicates.Enabled {
+		c.duplicates = newDuplicates(c.opts.Duplicates.NearThreshold)
+	}
+
+	// Forms
+	c.opts.Forms = userOptions.Forms
+
+	for _, p := range c.opts.Forms.AllowPOST {
+		c.postPatterns = append(c.postPatterns, regexp.MustCompile(p))
+	}
+
+	if c.opts.Forms.Enabled {
+		c.forms = newForms()
+	}
+
+	// JSON
+	c.opts.JSON = userOptions.JSON
+
+	if len(c.opts.JSON.Paths) != 0 || c.opts.JSON.IgnoreHypermedia {
+		p, err := NewJSONParser(c.opts.JSON.Paths, !c.opts.JSON.IgnoreHypermedia)
+		if err != nil {
+			return nil, fmt.Errorf("json: %v", err)
+		}
+
+		c.RegisterParser("application/json", p)
+	}
+
+	// Rendering
+	if err = setupRendering(c, userOptions.Rendering); err != nil {
+		return nil, fmt.Errorf("rendering: %v", err)
+	}
+	c.opts.Rendering = userOptions.Rendering
+
+	// Audit
+	c.opts.Audit = userOptions.Audit
+
+	if c.opts.Audit.Enabled {
+		c.audit = newAuditor(c.newBuiltinAuditRules(c.opts.Audit))
+	}
+
+	for _, rule := range c.opts.Audit.ContentRules {
+		c.AddAuditRule(newContentRule(rule))
+	}
 
 	return c, nil
 }
 
 // NewCrawlerFromToml reads up a file and parses it as a toml property file.
+// Environment variables and file references in its values are expanded,
+// see CrawlOptions.Expand.
 func NewCrawlerFromToml(filename string) (*Crawler, error) {
-	var opts CrawlOptions
-
-	if _, err := toml.DecodeFile(filename, &opts); err != nil {
-		return nil, fmt.Errorf("failed decoding file: %v", err)
+	opts, err := ReadTomlOptions(filename)
+	if err != nil {
+		return nil, err
 	}
 
 	c, err := NewCrawlerWithOpts(opts.EntryPoint, opts)
@@ -146,16 +247,27 @@ func NewCrawlerFromToml(filename string) (*Crawler, error) {
 	return c, nil
 }
 
-func setupDomains(allowedDomains *store.CStore, rootDomain string, otherDomains []string) error {
-	otherDomains = append(otherDomains, rootDomain)
+// NewCrawlerFromFile reads up a toml, yaml or json file, picked by its
+// extension, see ReadOptions. All formats have the same keys.
+func NewCrawlerFromFile(filename string) (*Crawler, error) {
+	opts, err := ReadOptions(filename)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, domain := range otherDomains {
-		scheme, host, err := schemeAndHost(domain)
-		if err != nil {
-			return fmt.Errorf("failed parsing allowed domain url %q: %v", domain, err)
-		}
+	c, err := NewCrawlerWithOpts(opts.EntryPoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating crawler: %v", err)
+	}
+
+	return c, nil
+}
 
-		allowedDomains.Store(fmt.Sprintf("%s://%s", scheme, host), "")
+func setupDomains(c *Crawler, domains []string) error {
+	for _, domain := range domains {
+		if err := c.allowDomain(domain); err != nil {
+			return fmt.Errorf("failed parsing allowed domain %q: %v", domain, err)
+		}
 	}
 
 	return nil
@@ -184,10 +296,16 @@ func configureAuth(c *Crawler, authType int, user, pass string) error {
 }
 
 func configureBasicAuth(c *Crawler, user, pass string) error {
+	c.reqHeaders.Store(authorizationHeaderName, basicAuth(user, pass))
+
+	return nil
+}
+
+// basicAuth returns the value of the Authorization header of basic
+// authentication.
+func basicAuth(user, pass string) string {
 	userPass := fmt.Sprintf("%s:%s", user, pass)
 	encodedUserPass := base64.StdEncoding.EncodeToString([]byte(userPass))
 
-	c.reqHeaders.Store(authorizationHeaderName, fmt.Sprintf("Basic %s", encodedUserPass))
-
-	return nil
+	return fmt.Sprintf("Basic %s", encodedUserPass)
 }
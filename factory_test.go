@@ -91,6 +91,8 @@ func TestNewCrawlerWithOpts(t *testing.T) {
 		wantErr bool
 	}{
 		{"emptyOpts", args{defaultCrawler.RootDomain, CrawlOptions{}}, defaultCrawler, false},
+		{"invalidOpts", args{defaultCrawler.RootDomain, CrawlOptions{WorkerCount: -1}}, nil, true},
+		{"missingRootDomain", args{"", CrawlOptions{}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -99,6 +101,9 @@ func TestNewCrawlerWithOpts(t *testing.T) {
 				t.Errorf("NewCrawlerWithOpts() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 			if err = compareCrawlers(got, tt.want); err != nil {
 				t.Errorf("Crawler mismatch: %v", err)
 			}
@@ -140,11 +145,11 @@ allowed-domains = ["http://www.example.com"]
 ignore-get-parameters = ["redirect"]
 fuzzy-get-parameter-checks = true
 idle-work-check-interval = 2000
-[[cookies]]
-Name = "Cookie Name"
+[cookies.CookieName]
+Name = "CookieName"
 Value = "Cookie Value"
 Path = "/"
-Domain = "http://example.com"
+Domain = "example.com"
 Expires = 2018-12-31T22:59:59Z
 RawExpires = ""
 MaxAge = 0
@@ -152,11 +157,11 @@ Secure = true
 HttpOnly = false
 Raw = ""
 
-[[cookies]]
-Name = "Second Cookie Name"
+[cookies.SecondCookieName]
+Name = "SecondCookieName"
 Value = "Second Cookie Value"
 Path = "/"
-Domain = "http://example.com"
+Domain = "example.com"
 Expires = 2018-12-31T22:59:59Z
 RawExpires = ""
 MaxAge = 0
@@ -194,7 +199,7 @@ header-name = "header-value"`)
 		IdleWorkCheckInterval:   2000,
 		Cookies: map[string]*http.Cookie{
 			"CookieName": &http.Cookie{
-				Domain:  "http://example.com",
+				Domain:  "example.com",
 				Name:    "CookieName",
 				Value:   "Cookie Value",
 				Path:    "/",
@@ -202,7 +207,7 @@ header-name = "header-value"`)
 				Secure:  true,
 			},
 			"SecondCookieName": &http.Cookie{
-				Domain:  "http://example.com",
+				Domain:  "example.com",
 				Name:    "SecondCookieName",
 				Value:   "Second Cookie Value",
 				Path:    "/",
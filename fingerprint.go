@@ -0,0 +1,286 @@
+package brink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultNearDuplicateThreshold = 3
+
+	// shingleSize is the number of consecutive words hashed together
+	// when calculating the SimHash of a page.
+	shingleSize = 3
+
+	// minShingles is the number of shingles a page needs for its SimHash
+	// to tell it apart from other pages. Pages with less text, e.g.
+	// redirect stubs or empty application shells, would all have nearly
+	// the same SimHash.
+	minShingles = 5
+)
+
+// DuplicateOptions configures the detection of pages having the same or nearly
+// the same content under different URLs.
+type DuplicateOptions struct {
+	// Enabled turns on content fingerprinting of the fetched pages.
+	Enabled bool `toml:"enabled" yaml:"enabled" json:"enabled"`
+
+	// NearThreshold is the maximum number of differing bits between the SimHashes of two
+	// pages for them to be considered near-duplicates. Setting it to 0 will use the default
+	// value of 3. Set it to -1 to only detect exact duplicates.
+	NearThreshold int `toml:"near-threshold" yaml:"near-threshold" json:"near-threshold"`
+
+	// SkipLinks instructs the crawler not to follow the links found on duplicate pages.
+	SkipLinks bool `toml:"skip-links" yaml:"skip-links" json:"skip-links"`
+}
+
+// Fingerprint identifies the content of a page. Hash is the SHA-256 of the
+// body, while SimHash is calculated from the text of the page so that
+// pages differing only slightly have SimHashes differing in only a few bits.
+type Fingerprint struct {
+	Hash    [sha256.Size]byte
+	SimHash uint64
+
+	// Shingles is the number of word shingles the SimHash was calculated
+	// from.
+	Shingles int
+}
+
+// FingerprintOf calculates the fingerprint of an HTML body.
+func FingerprintOf(body []byte) Fingerprint {
+	w := words(textIn(body))
+
+	return Fingerprint{
+		Hash:     sha256.Sum256(body),
+		SimHash:  simHash(w),
+		Shingles: shingles(w),
+	}
+}
+
+// Distance returns the number of differing bits between the SimHashes
+// of the two fingerprints.
+func (f Fingerprint) Distance(other Fingerprint) int {
+	return bits.OnesCount64(f.SimHash ^ other.SimHash)
+}
+
+// Duplicate is a page whose content matches that of an earlier page.
+type Duplicate struct {
+	URL string
+
+	// Exact is true if the bodies of the pages are byte-by-byte equal.
+	Exact bool
+
+	// Distance is the number of differing bits between the SimHashes of
+	// the pages. It is 0 for exact duplicates.
+	Distance int
+}
+
+// DuplicateGroup holds the first page visited with some content along with
+// all the pages found later having the same or nearly the same content.
+type DuplicateGroup struct {
+	URL        string
+	Duplicates []Duplicate
+}
+
+// duplicates keeps track of the fingerprints of the visited pages.
+type duplicates struct {
+	mu        sync.Mutex
+	threshold int
+
+	exact  map[[sha256.Size]byte]string
+	prints map[string]Fingerprint
+
+	// bands indexes urls by each 16 bit block of their SimHash. Two
+	// SimHashes differing in at most 3 bits have at least one equal
+	// block, so only the urls in the matching bands need to be checked.
+	bands [4]map[uint16][]string
+
+	groups map[string][]Duplicate
+}
+
+func newDuplicates(threshold int) *duplicates {
+	d := duplicates{
+		threshold: threshold,
+		exact:     make(map[[sha256.Size]byte]string),
+		prints:    make(map[string]Fingerprint),
+		groups:    make(map[string][]Duplicate),
+	}
+
+	for i := range d.bands {
+		d.bands[i] = make(map[uint16][]string)
+	}
+
+	return &d
+}
+
+// add records the fingerprint of the url and reports whether it is a
+// duplicate of a page added earlier. Pages with too little text to tell
+// them apart are not checked.
+func (d *duplicates) add(_url string, fp Fingerprint) bool {
+	if fp.Shingles < minShingles {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if original, ok := d.exact[fp.Hash]; ok {
+		d.groups[original] = append(d.groups[original], Duplicate{URL: _url, Exact: true})
+		return true
+	}
+	d.exact[fp.Hash] = _url
+
+	if d.threshold >= 0 {
+		if original, dist, ok := d.nearest(fp); ok {
+			d.groups[original] = append(d.groups[original], Duplicate{URL: _url, Distance: dist})
+			return true
+		}
+	}
+
+	d.prints[_url] = fp
+	for i := range d.bands {
+		band := uint16(fp.SimHash >> (16 * uint(i)))
+		d.bands[i][band] = append(d.bands[i][band], _url)
+	}
+
+	return false
+}
+
+// nearest returns the url with the closest SimHash within the threshold.
+func (d *duplicates) nearest(fp Fingerprint) (string, int, bool) {
+	var candidates []string
+
+	if d.threshold < len(d.bands) {
+		for i := range d.bands {
+			candidates = append(candidates, d.bands[i][uint16(fp.SimHash>>(16*uint(i)))]...)
+		}
+	} else {
+		for u := range d.prints {
+			candidates = append(candidates, u)
+		}
+	}
+
+	var (
+		best     string
+		bestDist = d.threshold + 1
+	)
+
+	for _, u := range candidates {
+		dist := fp.Distance(d.prints[u])
+		if dist < bestDist || (dist == bestDist && u < best) {
+			best, bestDist = u, dist
+		}
+	}
+
+	return best, bestDist, best != ""
+}
+
+// report returns the groups of duplicates sorted by their first url.
+func (d *duplicates) report() []DuplicateGroup {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	groups := make([]DuplicateGroup, 0, len(d.groups))
+	for u, dups := range d.groups {
+		groups = append(groups, DuplicateGroup{URL: u, Duplicates: append([]Duplicate(nil), dups...)})
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].URL < groups[j].URL })
+
+	return groups
+}
+
+// simHash calculates the 64 bit SimHash of the shingles of the words.
+func simHash(words []string) uint64 {
+	if len(words) == 0 {
+		return 0
+	}
+
+	var v [64]int
+
+	size := shingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+
+		for b := uint(0); b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				v[b]++
+			} else {
+				v[b]--
+			}
+		}
+	}
+
+	var result uint64
+	for b := uint(0); b < 64; b++ {
+		if v[b] > 0 {
+			result |= 1 << b
+		}
+	}
+
+	return result
+}
+
+// shingles returns the number of shingles simHash hashes the words in.
+func shingles(words []string) int {
+	if len(words) < shingleSize {
+		return 0
+	}
+
+	return len(words) - shingleSize + 1
+}
+
+// words splits the text into lowercase words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// textIn returns the text content of an HTML body, leaving out the
+// contents of script and style tags.
+func textIn(body []byte) string {
+	var (
+		b    strings.Builder
+		skip int
+	)
+
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isRawTextTag(string(name)) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isRawTextTag(string(name)) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func isRawTextTag(name string) bool {
+	return name == "script" || name == "style" || name == "noscript"
+}
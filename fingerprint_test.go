@@ -0,0 +1,146 @@
+package brink
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fingerprintArticle = `<html><head><title>Release notes</title><style>body { color: red; }</style></head>
+<body><h1>Release notes</h1><p>The crawler now detects pages that have the same or nearly the same
+content under different URLs, such as pages with session identifiers or tracking parameters in
+their query strings. Duplicate pages can be reported and their links left unfollowed, which makes
+crawls of large sites considerably faster and the reports a lot easier to read.</p>
+<p>Fingerprints are calculated from the text of the pages, so scripts and styles do not count.</p>
+%s</body></html>`
+
+func article(extra string) []byte {
+	return []byte(strings.Replace(fingerprintArticle, "%s", extra, 1))
+}
+
+func Test_textIn(t *testing.T) {
+	body := []byte(`<html><head><title>Title</title><script>var x = 1;</script></head><body><p>Hello <b>world</b></p><style>p {}</style></body></html>`)
+
+	if got := words(textIn(body)); !reflect.DeepEqual(got, []string{"title", "hello", "world"}) {
+		t.Errorf("words(textIn()) = %v", got)
+	}
+}
+
+func TestFingerprint_Distance(t *testing.T) {
+	original := FingerprintOf(article(""))
+
+	tests := []struct {
+		name    string
+		body    []byte
+		maxDist int
+		minDist int
+	}{
+		{"same", article(""), 0, 0},
+		{"different markup", article(`<div class="session-1234"></div>`), 0, 0},
+		{"different script", article(`<script>var session = "abcdef";</script>`), 0, 0},
+		{"small change", article(`<p>Session 1234</p>`), defaultNearDuplicateThreshold, 0},
+		{"different page", []byte(`<html><body><p>Something entirely different, about cooking pasta at home.</p></body></html>`), 64, defaultNearDuplicateThreshold + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dist := original.Distance(FingerprintOf(tt.body))
+			if dist > tt.maxDist || dist < tt.minDist {
+				t.Errorf("Distance() = %d, want between %d and %d", dist, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
+
+func Test_duplicates(t *testing.T) {
+	pages := []struct {
+		url     string
+		body    []byte
+		wantDup bool
+	}{
+		{"https://liferay.com/a", article(""), false},
+		{"https://liferay.com/a?session=1", article(""), true},
+		{"https://liferay.com/a?utm=2", article(`<p>Session 1234</p>`), true},
+		{"https://liferay.com/b", []byte(`<html><body><p>Something entirely different, about cooking pasta at home.</p></body></html>`), false},
+	}
+
+	for _, threshold := range []int{defaultNearDuplicateThreshold, 10} {
+		d := newDuplicates(threshold)
+
+		for _, p := range pages {
+			if got := d.add(p.url, FingerprintOf(p.body)); got != p.wantDup {
+				t.Errorf("threshold %d: add(%q) = %v, want %v", threshold, p.url, got, p.wantDup)
+			}
+		}
+
+		report := d.report()
+		if len(report) != 1 || report[0].URL != "https://liferay.com/a" || len(report[0].Duplicates) != 2 {
+			t.Fatalf("threshold %d: unexpected report: %+v", threshold, report)
+		}
+
+		if !report[0].Duplicates[0].Exact || report[0].Duplicates[1].Exact {
+			t.Errorf("threshold %d: unexpected exactness in report: %+v", threshold, report)
+		}
+	}
+}
+
+func Test_duplicates_exactOnly(t *testing.T) {
+	d := newDuplicates(-1)
+
+	d.add("https://liferay.com/a", FingerprintOf(article("")))
+	if d.add("https://liferay.com/b", FingerprintOf(article(`<p>Session 1234</p>`))) {
+		t.Errorf("near duplicate detected with exact matching only")
+	}
+}
+
+func Test_duplicates_textless(t *testing.T) {
+	d := newDuplicates(defaultNearDuplicateThreshold)
+
+	for u, body := range map[string]string{
+		"https://liferay.com/app":   `<html><body><div id="app"></div><script src="/app.js"></script></body></html>`,
+		"https://liferay.com/moved": `<html><head><meta http-equiv="refresh" content="0; url=/new"></head><body>Redirecting</body></html>`,
+		"https://liferay.com/empty": ``,
+	} {
+		if d.add(u, FingerprintOf([]byte(body))) {
+			t.Errorf("add(%q) = true, want textless pages not to be duplicates", u)
+		}
+	}
+
+	if report := d.report(); len(report) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestCrawler_duplicatesTextless(t *testing.T) {
+	ts := testSite(map[string]string{
+		"/":  `<html><body><a href="/a">A</a> <a href="/b">B</a></body></html>`,
+		"/a": `<html><body><div id="app"></div><a href="/a/next"></a></body></html>`,
+		"/b": `<html><body><div id="root"></div><a href="/b/next"></a></body></html>`,
+	})
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{Duplicates: DuplicateOptions{Enabled: true, SkipLinks: true}})
+
+	var (
+		mu      sync.Mutex
+		visited []string
+	)
+	c.HandleResultFunc(func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		visited = append(visited, strings.TrimPrefix(r.URL, ts.URL))
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	sort.Strings(visited)
+	if want := []string{"", "/a", "/a/next", "/b", "/b/next"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %v, want %v", visited, want)
+	}
+
+	if groups := c.DuplicateGroups(); len(groups) != 0 {
+		t.Errorf("DuplicateGroups() = %+v, want none", groups)
+	}
+}
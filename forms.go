@@ -0,0 +1,523 @@
+package brink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// KindForm is the kind of the links created by submitting forms.
+const KindForm = "form"
+
+const (
+	defaultMaxFormSubmissions = 20
+
+	formURLEncoded = "application/x-www-form-urlencoded"
+)
+
+// Form is an HTML form found on a page.
+type Form struct {
+	// Page is the page on which the form was first found.
+	Page string `json:"page"`
+
+	// Action is the absolute url the form is submitted to.
+	Action string `json:"action"`
+
+	// Method is the http method of the form, either GET or POST.
+	Method string `json:"method"`
+
+	// Enctype is the encoding of the submitted fields, e.g.
+	// "application/x-www-form-urlencoded".
+	Enctype string `json:"enctype"`
+
+	// Fields are the named fields of the form in document order. Radio
+	// buttons sharing a name are merged into a single field.
+	Fields []FormField `json:"fields"`
+}
+
+// FormField is a named input, select, textarea or button of a form.
+type FormField struct {
+	Name string `json:"name"`
+
+	// Type is the type of inputs and buttons, or "select" and "textarea".
+	Type string `json:"type"`
+
+	// Value is the default value of the field: the value attribute of
+	// inputs and buttons, the selected option of selects and radio buttons
+	// and the text of textareas.
+	Value string `json:"value,omitempty"`
+
+	// Options are the values of the options of selects and of the radio
+	// buttons sharing the name.
+	Options []string `json:"options,omitempty"`
+
+	// Checked is true for checkboxes checked by default.
+	Checked bool `json:"checked,omitempty"`
+}
+
+// FormOptions configures the discovery and submission of forms.
+type FormOptions struct {
+	// Enabled turns on the discovery of forms. The forms found can be listed with
+	// the Forms method of the crawler.
+	Enabled bool `toml:"enabled" yaml:"enabled" json:"enabled"`
+
+	// SubmitGET makes the crawler submit the GET forms it finds and visit the resulting URLs.
+	SubmitGET bool `toml:"submit-get" yaml:"submit-get" json:"submit-get"`
+
+	// AllowPOST lists regular expressions matched against the actions of POST forms. Only
+	// the POST forms whose action matches one of them are submitted, so that no data is
+	// changed on the servers unintentionally.
+	AllowPOST []string `toml:"allow-post" yaml:"allow-post" json:"allow-post"`
+
+	// Values holds the values to submit, by field name. The first value is used by default,
+	// and every other one is submitted as a variation. Fields without configured values are
+	// submitted with their default value.
+	Values map[string][]string `toml:"values" yaml:"values" json:"values"`
+
+	// MaxSubmissions is the most number of times a form is submitted with different values.
+	// Setting it to 0 will use the default value of 20.
+	MaxSubmissions int `toml:"max-submissions" yaml:"max-submissions" json:"max-submissions"`
+}
+
+func (f FormOptions) validate() error {
+	for _, p := range f.AllowPOST {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid post pattern %q: %v", p, err)
+		}
+	}
+
+	if f.MaxSubmissions < 0 {
+		return fmt.Errorf("max submissions must not be negative")
+	}
+
+	return nil
+}
+
+// FormsIn expects a valid HTML to parse and returns the forms contained
+// inside. The actions of the forms are resolved against pageURL.
+func FormsIn(pageURL string, body []byte) []Form {
+	var (
+		found []Form
+
+		// form is the form being read, and field the select or textarea
+		// whose contents are being read, if any.
+		form  *Form
+		field *FormField
+		text  []string
+
+		// optionText is true while waiting for the text of an option
+		// without a value attribute, which becomes its value.
+		optionText     bool
+		optionSelected bool
+	)
+
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if form != nil {
+				found = append(found, *form)
+			}
+
+			return found
+		}
+
+		t := z.Token()
+
+		if tt == html.TextToken {
+			switch {
+			case field != nil && field.Type == "textarea":
+				text = append(text, t.Data)
+			case field != nil && optionText:
+				value := strings.TrimSpace(t.Data)
+				field.Options[len(field.Options)-1] = value
+				if optionSelected {
+					field.Value = value
+				}
+				optionText = false
+			}
+			continue
+		}
+
+		optionText = false
+
+		if tt == html.EndTagToken {
+			switch t.Data {
+			case "form":
+				if form != nil {
+					found = append(found, *form)
+				}
+				form, field = nil, nil
+			case "select", "textarea":
+				if field != nil {
+					if field.Type == "textarea" {
+						field.Value = strings.Join(text, "")
+					}
+					form.Fields = append(form.Fields, *field)
+				}
+				field, text = nil, nil
+			}
+			continue
+		}
+
+		attrs := make(map[string]string, len(t.Attr))
+		for _, attr := range t.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+		_, checked := attrs["checked"]
+		_, selected := attrs["selected"]
+		_, disabled := attrs["disabled"]
+
+		if t.Data == "form" {
+			if form != nil {
+				found = append(found, *form)
+			}
+			form, field = newForm(pageURL, attrs), nil
+			continue
+		}
+
+		if form == nil || disabled {
+			continue
+		}
+
+		name := attrs["name"]
+
+		switch t.Data {
+		case "input":
+			typ := strings.ToLower(attrs["type"])
+			if typ == "" {
+				typ = "text"
+			}
+
+			if name == "" {
+				continue
+			}
+
+			value, hasValue := attrs["value"]
+			switch typ {
+			case "radio":
+				form.addRadio(name, value, checked)
+				continue
+			case "checkbox":
+				if !hasValue {
+					value = "on"
+				}
+			}
+
+			form.Fields = append(form.Fields, FormField{Name: name, Type: typ, Value: value, Checked: checked})
+		case "button":
+			typ := strings.ToLower(attrs["type"])
+			if typ == "" {
+				typ = "submit"
+			}
+
+			if name != "" {
+				form.Fields = append(form.Fields, FormField{Name: name, Type: typ, Value: attrs["value"]})
+			}
+		case "select", "textarea":
+			field = nil
+			if name != "" {
+				field = &FormField{Name: name, Type: t.Data}
+			}
+		case "option":
+			if field == nil || field.Type != "select" {
+				continue
+			}
+
+			value, ok := attrs["value"]
+
+			field.Options = append(field.Options, value)
+			if selected || len(field.Options) == 1 {
+				field.Value = value
+			}
+
+			// The value of options without a value attribute is their text
+			optionText, optionSelected = !ok, selected || len(field.Options) == 1
+		}
+	}
+}
+
+func newForm(pageURL string, attrs map[string]string) *Form {
+	f := Form{
+		Page:    pageURL,
+		Action:  pageURL,
+		Method:  http.MethodGet,
+		Enctype: formURLEncoded,
+	}
+
+	if action := strings.TrimSpace(attrs["action"]); action != "" {
+		if abs, err := resolveURL(pageURL, action); err == nil {
+			f.Action = abs
+		}
+	}
+
+	if strings.EqualFold(attrs["method"], http.MethodPost) {
+		f.Method = http.MethodPost
+	}
+
+	if enctype := strings.TrimSpace(attrs["enctype"]); enctype != "" {
+		f.Enctype = strings.ToLower(enctype)
+	}
+
+	return &f
+}
+
+// addRadio adds the radio button to the field of the buttons sharing its
+// name, creating the field if there is none yet.
+func (f *Form) addRadio(name, value string, checked bool) {
+	if value == "" {
+		value = "on"
+	}
+
+	for i := range f.Fields {
+		if fd := &f.Fields[i]; fd.Type == "radio" && fd.Name == name {
+			fd.Options = append(fd.Options, value)
+			if checked {
+				fd.Value = value
+			}
+			return
+		}
+	}
+
+	field := FormField{Name: name, Type: "radio", Options: []string{value}}
+	if checked {
+		field.Value = value
+	}
+
+	f.Fields = append(f.Fields, field)
+}
+
+// key identifies the form regardless of the page it is on, e.g. a search
+// form in the header of every page.
+func (f Form) key() string {
+	names := make([]string, len(f.Fields))
+	for i, fd := range f.Fields {
+		names[i] = fd.Name
+	}
+
+	return f.Method + " " + f.Action + " " + strings.Join(names, ",")
+}
+
+// Submissions returns the encoded field values to submit the form with. The
+// first one holds the default values: the first configured value of the
+// fields, or their own default. It is followed by the variations changing a
+// single field each, to another configured value or another option of
+// selects and radio buttons, or checking a checkbox. At most max values are
+// returned. Submit buttons other than the first one are left out.
+func (f Form) Submissions(values map[string][]string, max int) []string {
+	base := make([]string, len(f.Fields))
+	include := make([]bool, len(f.Fields))
+
+	submitSeen := false
+	for i, fd := range f.Fields {
+		switch fd.Type {
+		case "submit", "image":
+			include[i] = !submitSeen
+			submitSeen = true
+		case "reset", "button", "file":
+		case "checkbox":
+			include[i] = fd.Checked
+		case "radio":
+			include[i] = fd.Value != ""
+		default:
+			include[i] = true
+		}
+
+		base[i] = fd.Value
+		if vs := values[fd.Name]; len(vs) > 0 && include[i] {
+			base[i] = vs[0]
+		}
+	}
+
+	encode := func(vals []string, incl []bool) string {
+		v := make(url.Values)
+		var keys []string
+		for i, fd := range f.Fields {
+			if !incl[i] {
+				continue
+			}
+
+			if _, ok := v[fd.Name]; !ok {
+				keys = append(keys, fd.Name)
+			}
+			v[fd.Name] = append(v[fd.Name], vals[i])
+		}
+
+		// Keep the fields in document order, as browsers do
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			for _, val := range v[k] {
+				parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(val))
+			}
+		}
+
+		return strings.Join(parts, "&")
+	}
+
+	seen := make(map[string]bool)
+	var submissions []string
+
+	add := func(vals []string, incl []bool) bool {
+		if len(submissions) >= max {
+			return false
+		}
+
+		s := encode(vals, incl)
+		if !seen[s] {
+			seen[s] = true
+			submissions = append(submissions, s)
+		}
+
+		return true
+	}
+
+	add(base, include)
+
+	for i, fd := range f.Fields {
+		var alternatives []string
+		switch fd.Type {
+		case "submit", "image", "reset", "button", "file":
+			continue
+		case "checkbox":
+			if !fd.Checked {
+				alternatives = []string{fd.Value}
+			}
+		case "select", "radio":
+			alternatives = fd.Options
+		}
+
+		if vs := values[fd.Name]; len(vs) > 0 {
+			alternatives = append(append([]string(nil), vs...), alternatives...)
+		}
+
+		for _, alt := range alternatives {
+			vals := append([]string(nil), base...)
+			incl := append([]bool(nil), include...)
+			vals[i], incl[i] = alt, true
+
+			if !add(vals, incl) {
+				return submissions
+			}
+		}
+	}
+
+	return submissions
+}
+
+// formLinks returns the links to visit by submitting the form, if it may
+// be submitted.
+func (c *Crawler) formLinks(f Form) []Link {
+	if !c.domainAllowed(f.Action) {
+		return nil
+	}
+
+	switch {
+	case f.Method == http.MethodGet && c.opts.Forms.SubmitGET:
+	case f.Method == http.MethodPost && f.Enctype == formURLEncoded && c.postAllowed(f.Action):
+	default:
+		return nil
+	}
+
+	max := c.opts.Forms.MaxSubmissions
+	if max == 0 {
+		max = defaultMaxFormSubmissions
+	}
+
+	var links []Link
+	for _, values := range f.Submissions(c.opts.Forms.Values, max) {
+		l := Link{LinkedFrom: f.Page, Href: f.Action, Kind: KindForm, Method: f.Method}
+
+		if f.Method == http.MethodGet {
+			u, err := url.Parse(f.Action)
+			if err != nil {
+				return nil
+			}
+
+			// Submitting a GET form replaces the query of its action
+			u.RawQuery, u.Fragment = values, ""
+			l.Href = u.String()
+		} else {
+			l.Body = values
+			l.Header = http.Header{"Content-Type": {formURLEncoded}}
+		}
+
+		links = append(links, l)
+	}
+
+	return links
+}
+
+func (c *Crawler) postAllowed(action string) bool {
+	for _, re := range c.postPatterns {
+		if re.MatchString(action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forms collects the distinct forms found during the crawl.
+type forms struct {
+	mu    sync.Mutex
+	forms map[string]Form
+}
+
+func newForms() *forms {
+	return &forms{forms: make(map[string]Form)}
+}
+
+// add stores the forms and returns the ones not seen before.
+func (fs *forms) add(found []Form) []Form {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var added []Form
+	for _, f := range found {
+		if _, ok := fs.forms[f.key()]; ok {
+			continue
+		}
+
+		fs.forms[f.key()] = f
+		added = append(added, f)
+	}
+
+	return added
+}
+
+func (fs *forms) list() []Form {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	list := make([]Form, 0, len(fs.forms))
+	for _, f := range fs.forms {
+		list = append(list, f)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Page != list[j].Page {
+			return list[i].Page < list[j].Page
+		}
+		return list[i].key() < list[j].key()
+	})
+
+	return list
+}
+
+// Forms returns the distinct forms found during the crawl, sorted by the
+// pages they were first found on. It returns nil if form discovery is
+// disabled.
+func (c *Crawler) Forms() []Form {
+	if c.forms == nil {
+		return nil
+	}
+
+	return c.forms.list()
+}
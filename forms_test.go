@@ -0,0 +1,164 @@
+package brink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+const formsPage = `<html><body>
+<form action="/search" role="search">
+	<input name="q" placeholder="Search">
+	<input type="hidden" name="lang" value="en">
+	<select name="color"><option>Any</option><option value="red">Red</option><option value="blue" selected>Blue</option></select>
+	<input type="radio" name="sort" value="price"><input type="radio" name="sort" value="name" checked>
+	<input type="checkbox" name="instock">
+	<input name="disabled" disabled>
+	<button name="go" value="1">Search</button>
+</form>
+<form method="post" action="cart/add" enctype="multipart/form-data">
+	<textarea name="note">gift wrap</textarea>
+	<input type="file" name="attachment">
+</form>
+</body></html>`
+
+func TestFormsIn(t *testing.T) {
+	want := []Form{
+		{
+			Page:    "http://example.com/shop/",
+			Action:  "http://example.com/search",
+			Method:  http.MethodGet,
+			Enctype: formURLEncoded,
+			Fields: []FormField{
+				{Name: "q", Type: "text"},
+				{Name: "lang", Type: "hidden", Value: "en"},
+				{Name: "color", Type: "select", Value: "blue", Options: []string{"Any", "red", "blue"}},
+				{Name: "sort", Type: "radio", Value: "name", Options: []string{"price", "name"}},
+				{Name: "instock", Type: "checkbox", Value: "on"},
+				{Name: "go", Type: "submit", Value: "1"},
+			},
+		},
+		{
+			Page:    "http://example.com/shop/",
+			Action:  "http://example.com/shop/cart/add",
+			Method:  http.MethodPost,
+			Enctype: "multipart/form-data",
+			Fields: []FormField{
+				{Name: "note", Type: "textarea", Value: "gift wrap"},
+				{Name: "attachment", Type: "file"},
+			},
+		},
+	}
+
+	if got := FormsIn("http://example.com/shop/", []byte(formsPage)); !reflect.DeepEqual(got, want) {
+		t.Errorf("FormsIn() = %+v, want %+v", got, want)
+	}
+}
+
+func TestForm_Submissions(t *testing.T) {
+	form := FormsIn("http://example.com/", []byte(formsPage))[0]
+
+	tests := []struct {
+		name   string
+		values map[string][]string
+		max    int
+		want   []string
+	}{
+		{"defaults", nil, 1, []string{"q=&lang=en&color=blue&sort=name&go=1"}},
+		{"variations", nil, 20, []string{
+			"q=&lang=en&color=blue&sort=name&go=1",
+			"q=&lang=en&color=Any&sort=name&go=1",
+			"q=&lang=en&color=red&sort=name&go=1",
+			"q=&lang=en&color=blue&sort=price&go=1",
+			"q=&lang=en&color=blue&sort=name&instock=on&go=1",
+		}},
+		{"configured values", map[string][]string{"q": {"red shoes", "boots"}, "instock": {"yes"}}, 4, []string{
+			"q=red+shoes&lang=en&color=blue&sort=name&go=1",
+			"q=boots&lang=en&color=blue&sort=name&go=1",
+			"q=red+shoes&lang=en&color=Any&sort=name&go=1",
+			"q=red+shoes&lang=en&color=red&sort=name&go=1",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := form.Submissions(tt.values, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Submissions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_forms(t *testing.T) {
+	pages := map[string]string{
+		"/": `<form action="/search"><select name="color"><option>red</option><option>blue</option></select></form>
+<form action="/cart/add" method="post"><input type="hidden" name="item" value="42"></form>
+<form action="/account/delete" method="post"><input type="hidden" name="confirm" value="yes"></form>`,
+		"/search":   `<form action="/search"><select name="color"><option>red</option><option>blue</option></select></form>`,
+		"/cart/add": `added`,
+	}
+
+	tests := []struct {
+		name      string
+		opts      FormOptions
+		forms     int
+		requested []string
+	}{
+		{"discovery only", FormOptions{Enabled: true}, 3, []string{"GET /"}},
+		{"get forms", FormOptions{Enabled: true, SubmitGET: true}, 3, []string{"GET /", "GET /search?color=blue", "GET /search?color=red"}},
+		{"allowed post forms", FormOptions{Enabled: true, SubmitGET: true, AllowPOST: []string{"/cart/"}}, 3, []string{"GET /", "GET /search?color=blue", "GET /search?color=red", "POST /cart/add item=42"}},
+		{"disabled", FormOptions{SubmitGET: true, AllowPOST: []string{".*"}}, 0, []string{"GET /"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu        sync.Mutex
+				requested []string
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+
+				req := r.Method + " " + r.URL.RequestURI()
+				if len(body) != 0 {
+					req += " " + string(body)
+				}
+
+				mu.Lock()
+				requested = append(requested, req)
+				mu.Unlock()
+
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, pages[r.URL.Path])
+			}))
+			defer ts.Close()
+
+			c := testCrawler(t, ts.URL+"/", CrawlOptions{Forms: tt.opts})
+			c.HandleResultFunc(func(r Result) {})
+
+			waitDone(t, startAsync(t, c))
+
+			sort.Strings(requested)
+			if !reflect.DeepEqual(requested, tt.requested) {
+				t.Errorf("requested %q, want %q", requested, tt.requested)
+			}
+
+			if got := c.Forms(); len(got) != tt.forms {
+				t.Errorf("Forms() = %+v, want %d forms", got, tt.forms)
+			}
+		})
+	}
+}
+
+func TestFormOptions_validate(t *testing.T) {
+	if err := (FormOptions{AllowPOST: []string{"/cart/("}}).validate(); err == nil {
+		t.Errorf("validate() accepted an invalid pattern")
+	}
+
+	if err := (FormOptions{MaxSubmissions: -1}).validate(); err == nil {
+		t.Errorf("validate() accepted negative max submissions")
+	}
+}
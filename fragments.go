@@ -0,0 +1,152 @@
+package brink
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// BrokenFragment is a link pointing to a fragment (e.g. "#section") which
+// does not exist on the target page.
+type BrokenFragment struct {
+	// Source is the page on which the link was found.
+	Source string
+
+	// Target is the page the link points to, without the fragment. It is
+	// the same as Source for in-page links.
+	Target string
+
+	// Fragment is the fragment of the link, without the leading "#".
+	Fragment string
+
+	// Text is the text of the link.
+	Text string
+}
+
+// AnchorsIn expects a valid HTML to parse and returns the sorted list of
+// the fragments that can be linked to on the page, e.g. the values of all
+// id attributes, and the name attributes of anchors.
+func AnchorsIn(body []byte) []string {
+	seen := make(map[string]bool)
+
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		for _, attr := range t.Attr {
+			if attr.Key == "id" || (attr.Key == "name" && t.Data == "a") {
+				seen[attr.Val] = true
+			}
+		}
+	}
+
+	anchors := make([]string, 0, len(seen))
+	for a := range seen {
+		anchors = append(anchors, a)
+	}
+
+	sort.Strings(anchors)
+
+	return anchors
+}
+
+// fragmentRef is a link with a fragment waiting to be validated.
+type fragmentRef struct {
+	source   string
+	target   string
+	fragment string
+	text     string
+}
+
+// fragments collects the anchors of the visited pages and the links
+// pointing to fragments, so that they can be validated once the target
+// pages have been fetched.
+type fragments struct {
+	mu      sync.Mutex
+	anchors map[string]map[string]bool
+	refs    []fragmentRef
+}
+
+func newFragments() *fragments {
+	return &fragments{
+		anchors: make(map[string]map[string]bool),
+	}
+}
+
+// addPage records the anchors of the page.
+func (f *fragments) addPage(_url string, body []byte) {
+	anchors := make(map[string]bool)
+	for _, a := range AnchorsIn(body) {
+		anchors[a] = true
+	}
+
+	f.mu.Lock()
+	f.anchors[_url] = anchors
+	f.mu.Unlock()
+}
+
+// addRef records a link pointing to the fragment of the target page.
+func (f *fragments) addRef(source, target, fragment, text string) {
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+
+	// Empty fragments and "#top" always point to the top of the page.
+	if fragment == "" || strings.EqualFold(fragment, "top") {
+		return
+	}
+
+	f.mu.Lock()
+	f.refs = append(f.refs, fragmentRef{source: source, target: target, fragment: fragment, text: text})
+	f.mu.Unlock()
+}
+
+// broken returns the links pointing to fragments missing from their target
+// pages. Links to pages which have not been fetched are not validated.
+func (f *fragments) broken() []BrokenFragment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []BrokenFragment
+
+	seen := make(map[fragmentRef]bool)
+	for _, ref := range f.refs {
+		anchors, ok := f.anchors[ref.target]
+		if !ok || anchors[ref.fragment] || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+
+		result = append(result, BrokenFragment{
+			Source:   ref.source,
+			Target:   ref.target,
+			Fragment: ref.fragment,
+			Text:     ref.text,
+		})
+	}
+
+	return result
+}
+
+// splitFragment splits the url into the part before the fragment and the
+// fragment itself.
+func splitFragment(_url string) (string, string, bool) {
+	i := strings.Index(_url, "#")
+	if i == -1 {
+		return _url, "", false
+	}
+
+	return _url[:i], _url[i+1:], true
+}
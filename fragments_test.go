@@ -0,0 +1,72 @@
+package brink
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnchorsIn(t *testing.T) {
+	body := []byte(`<html><body><h1 id="title">Title</h1><a name="legacy"></a><div id="section-1"><img id="logo" /></div><input name="notAnAnchor"><p id="title"></p></body></html>`)
+
+	want := []string{"legacy", "logo", "section-1", "title"}
+	if got := AnchorsIn(body); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnchorsIn() = %v, want %v", got, want)
+	}
+}
+
+func Test_fragments(t *testing.T) {
+	f := newFragments()
+
+	f.addPage("https://liferay.com/a", []byte(`<h2 id="install">Install</h2><h2 id="caf%C3%A9">Cafe</h2><h2 id="café">Café</h2>`))
+	f.addPage("https://liferay.com/b", []byte(`<a name="usage"></a>`))
+
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "install", "Install")
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "missing", "Missing")
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "missing", "Missing")
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "", "Empty")
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "top", "Top")
+	f.addRef("https://liferay.com/a", "https://liferay.com/a", "caf%C3%A9", "Café")
+	f.addRef("https://liferay.com/a", "https://liferay.com/b", "usage", "Usage")
+	f.addRef("https://liferay.com/a", "https://liferay.com/b", "install", "Install on b")
+	f.addRef("https://liferay.com/a", "https://liferay.com/unfetched", "anything", "Unfetched")
+
+	want := []BrokenFragment{
+		{Source: "https://liferay.com/a", Target: "https://liferay.com/a", Fragment: "missing", Text: "Missing"},
+		{Source: "https://liferay.com/a", Target: "https://liferay.com/b", Fragment: "install", Text: "Install on b"},
+	}
+	if got := f.broken(); !reflect.DeepEqual(got, want) {
+		t.Errorf("broken() = %v, want %v", got, want)
+	}
+}
+
+func TestCrawler_checkFragment(t *testing.T) {
+	c, _ := NewCrawlerWithOpts("https://liferay.com", CrawlOptions{ValidateFragments: true})
+
+	tests := []struct {
+		name  string
+		href  string
+		visit bool
+	}{
+		{"no fragment", "https://liferay.com/page", true},
+		{"in-page", "#section", false},
+		{"cross-page", "https://liferay.com/page?b=2&a=1#section", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.checkFragment("https://liferay.com", Link{Href: tt.href}); got != tt.visit {
+				t.Errorf("checkFragment() = %v, want %v", got, tt.visit)
+			}
+		})
+	}
+
+	c.fragments.addPage("https://liferay.com", []byte(`<div></div>`))
+	c.fragments.addPage("https://liferay.com/page?a=1&b=2", []byte(`<div></div>`))
+
+	want := []BrokenFragment{
+		{Source: "https://liferay.com", Target: "https://liferay.com", Fragment: "section"},
+		{Source: "https://liferay.com", Target: "https://liferay.com/page?a=1&b=2", Fragment: "section"},
+	}
+	if got := c.BrokenFragments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("BrokenFragments() = %v, want %v", got, want)
+	}
+}
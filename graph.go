@@ -0,0 +1,255 @@
+package brink
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Graph export formats
+const (
+	GraphFormatGraphML = "graphml"
+	GraphFormatDOT     = "dot"
+	GraphFormatCSV     = "csv"
+)
+
+// Edge is a link from the Source page to the Target URL, both in their
+// normalized form. Text is the text of the link and Kind is the kind of
+// element it was found in.
+type Edge struct {
+	Source string
+	Target string
+	Text   string
+	Kind   string
+}
+
+// LinkGraph holds all the links found during the crawl. It is safe for
+// concurrent use.
+type LinkGraph struct {
+	mu    sync.RWMutex
+	edges []Edge
+	seen  map[Edge]bool
+
+	// in and out index the edges by their targets and sources.
+	in  map[string][]int
+	out map[string][]int
+}
+
+func newLinkGraph() *LinkGraph {
+	return &LinkGraph{
+		seen: make(map[Edge]bool),
+		in:   make(map[string][]int),
+		out:  make(map[string][]int),
+	}
+}
+
+func (g *LinkGraph) add(e Edge) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.seen[e] {
+		return
+	}
+	g.seen[e] = true
+
+	g.edges = append(g.edges, e)
+	g.in[e.Target] = append(g.in[e.Target], len(g.edges)-1)
+	g.out[e.Source] = append(g.out[e.Source], len(g.edges)-1)
+}
+
+// Edges returns all the edges of the graph in the order they were found.
+func (g *LinkGraph) Edges() []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return append([]Edge(nil), g.edges...)
+}
+
+// Referrers returns the edges pointing to the target, e.g. all the links
+// found on any page pointing to it.
+func (g *LinkGraph) Referrers(target string) []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.collect(g.in[target])
+}
+
+// Links returns the edges originating from the source page.
+func (g *LinkGraph) Links(source string) []Edge {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.collect(g.out[source])
+}
+
+// Nodes returns the sorted list of all sources and targets in the graph.
+func (g *LinkGraph) Nodes() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	nodes := make([]string, 0, len(g.in))
+
+	for _, e := range g.edges {
+		for _, n := range []string{e.Source, e.Target} {
+			if !seen[n] {
+				seen[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+func (g *LinkGraph) collect(ixs []int) []Edge {
+	edges := make([]Edge, 0, len(ixs))
+	for _, ix := range ixs {
+		edges = append(edges, g.edges[ix])
+	}
+
+	return edges
+}
+
+// Write writes the graph to w in the specified format.
+func (g *LinkGraph) Write(w io.Writer, format string) error {
+	switch format {
+	case GraphFormatGraphML:
+		return g.WriteGraphML(w)
+	case GraphFormatDOT:
+		return g.WriteDOT(w)
+	case GraphFormatCSV:
+		return g.WriteCSV(w)
+	}
+
+	return fmt.Errorf("unknown graph format %q", format)
+}
+
+// WriteCSV writes the graph as an edge list with a header line.
+func (g *LinkGraph) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"source", "target", "text", "kind"}); err != nil {
+		return fmt.Errorf("failed writing header: %v", err)
+	}
+
+	for _, e := range g.Edges() {
+		if err := cw.Write([]string{e.Source, e.Target, e.Text, e.Kind}); err != nil {
+			return fmt.Errorf("failed writing edge: %v", err)
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. The text of the
+// links is the label of the edges, and their kind is the class.
+func (g *LinkGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph links {\n")
+
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(&b, "  %s;\n", dotQuote(n))
+	}
+
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, class=%s];\n",
+			dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Text), dotQuote(e.Kind))
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// dotQuote returns the DOT quoted string of s, in which only double quotes
+// and backslashes are escaped.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID string `xml:"id,attr"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in the GraphML format. Nodes are identified
+// by their URLs, while the text and kind of the links are edge attributes.
+func (g *LinkGraph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "text", For: "edge", AttrName: "text", AttrType: "string"},
+			{ID: "kind", For: "edge", AttrName: "kind", AttrType: "string"},
+		},
+		Graph: graphMLGraph{EdgeDefault: "directed"},
+	}
+
+	for _, n := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n})
+	}
+
+	for _, e := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data:   []graphMLData{{Key: "text", Value: e.Text}, {Key: "kind", Value: e.Kind}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed writing xml header: %v", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed encoding graph: %v", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
@@ -0,0 +1,106 @@
+package brink
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testGraph() *LinkGraph {
+	g := newLinkGraph()
+
+	g.add(Edge{Source: "https://liferay.com", Target: "https://liferay.com/a", Text: "A page", Kind: KindAnchor})
+	g.add(Edge{Source: "https://liferay.com", Target: "https://liferay.com/broken", Text: "Broken", Kind: KindAnchor})
+	g.add(Edge{Source: "https://liferay.com/a", Target: "https://liferay.com/broken", Text: "Also \"broken\"", Kind: KindAnchor})
+	g.add(Edge{Source: "https://liferay.com/a", Target: "https://liferay.com/broken", Text: "Also \"broken\"", Kind: KindAnchor})
+
+	return g
+}
+
+func TestLinkGraph(t *testing.T) {
+	g := testGraph()
+
+	if got := len(g.Edges()); got != 3 {
+		t.Errorf("len(Edges()) = %d, want 3", got)
+	}
+
+	wantReferrers := []Edge{
+		{Source: "https://liferay.com", Target: "https://liferay.com/broken", Text: "Broken", Kind: KindAnchor},
+		{Source: "https://liferay.com/a", Target: "https://liferay.com/broken", Text: "Also \"broken\"", Kind: KindAnchor},
+	}
+	if got := g.Referrers("https://liferay.com/broken"); !reflect.DeepEqual(got, wantReferrers) {
+		t.Errorf("Referrers() = %v, want %v", got, wantReferrers)
+	}
+
+	if got := g.Links("https://liferay.com/a"); len(got) != 1 || got[0].Target != "https://liferay.com/broken" {
+		t.Errorf("Links() = %v", got)
+	}
+
+	if got := g.Referrers("https://liferay.com/unknown"); len(got) != 0 {
+		t.Errorf("Referrers() of unknown url = %v", got)
+	}
+
+	wantNodes := []string{"https://liferay.com", "https://liferay.com/a", "https://liferay.com/broken"}
+	if got := g.Nodes(); !reflect.DeepEqual(got, wantNodes) {
+		t.Errorf("Nodes() = %v, want %v", got, wantNodes)
+	}
+}
+
+func Test_dotQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"https://liferay.com/a", `"https://liferay.com/a"`},
+		{`Say "hi"`, `"Say \"hi\""`},
+		{`C:\path`, `"C:\\path"`},
+		{"Café ☕", `"Café ☕"`},
+	}
+	for _, tt := range tests {
+		if got := dotQuote(tt.s); got != tt.want {
+			t.Errorf("dotQuote(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestLinkGraph_Write(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{GraphFormatCSV, []string{
+			"source,target,text,kind\n",
+			"https://liferay.com/a,https://liferay.com/broken,\"Also \"\"broken\"\"\",a\n",
+		}},
+		{GraphFormatDOT, []string{
+			"digraph links {\n",
+			`  "https://liferay.com/a";`,
+			`  "https://liferay.com/a" -> "https://liferay.com/broken" [label="Also \"broken\"", class="a"];`,
+		}},
+		{GraphFormatGraphML, []string{
+			`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`,
+			`<node id="https://liferay.com/broken"></node>`,
+			`<edge source="https://liferay.com/a" target="https://liferay.com/broken">`,
+			`<data key="text">Also &#34;broken&#34;</data>`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := testGraph().Write(&buf, tt.format); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Write() output does not contain %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+
+	if err := testGraph().Write(&bytes.Buffer{}, "png"); err == nil {
+		t.Errorf("Write() expected error for unknown format")
+	}
+}
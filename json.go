@@ -0,0 +1,438 @@
+package brink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of links found in JSON documents and Link headers
+const (
+	KindJSON       = "json"
+	KindPagination = "pagination"
+)
+
+// JSONOptions configures how links are found in JSON documents.
+type JSONOptions struct {
+	// Paths lists JSONPath-style expressions selecting the urls in the documents, e.g.
+	// "$.items[*].url" or "$..href". Selected objects holding an "href" are followed as well.
+	Paths []string `toml:"paths" yaml:"paths" json:"paths"`
+
+	// IgnoreHypermedia turns off following the links of the well-known hypermedia formats:
+	// the "_links" of HAL, the "links" of JSON:API and Siren, and plain "next" urls.
+	IgnoreHypermedia bool `toml:"ignore-hypermedia" yaml:"ignore-hypermedia" json:"ignore-hypermedia"`
+
+	// FollowLinkHeaders makes the crawler follow the rel="next" links of the Link headers of
+	// the responses, as used for paginating APIs.
+	FollowLinkHeaders bool `toml:"follow-link-headers" yaml:"follow-link-headers" json:"follow-link-headers"`
+}
+
+func (j JSONOptions) validate() error {
+	for _, p := range j.Paths {
+		if _, err := compileJSONPath(p); err != nil {
+			return fmt.Errorf("invalid path %q: %v", p, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseJSON returns the links of the hypermedia conventions found in the
+// JSON document: the "_links" of HAL resources, including the embedded ones,
+// the "links" of JSON:API and Siren documents, and the urls of "next" keys.
+func ParseJSON(pageURL string, body []byte) ([]Link, error) {
+	p, err := NewJSONParser(nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return p(pageURL, body)
+}
+
+// NewJSONParser returns a parser selecting the urls of JSON documents by
+// the JSONPath-style expressions, and by the hypermedia conventions too if
+// hypermedia is true.
+//
+// The expressions start with "$" and select object keys by ".key" or
+// "['key']", array elements by "[0]", all children by ".*" or "[*]", and
+// descendants by "..key" or "..*".
+func NewJSONParser(paths []string, hypermedia bool) (Parser, error) {
+	compiled := make([]jsonPath, 0, len(paths))
+	for _, p := range paths {
+		jp, err := compileJSONPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %v", p, err)
+		}
+
+		compiled = append(compiled, jp)
+	}
+
+	return func(pageURL string, body []byte) ([]Link, error) {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+
+		var doc interface{}
+		if err := d.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed parsing json: %v", err)
+		}
+
+		var links []Link
+		add := func(href, rel string) {
+			kind := KindJSON
+			if rel == "next" {
+				kind = KindPagination
+			}
+
+			links = append(links, Link{LinkedFrom: pageURL, Href: href, Text: rel, Kind: kind})
+		}
+
+		for _, jp := range compiled {
+			for _, v := range jp.eval(doc) {
+				if href := hrefOf(v); href != "" {
+					add(href, "")
+				}
+			}
+		}
+
+		if hypermedia {
+			hypermediaLinks(doc, add)
+		}
+
+		return links, nil
+	}, nil
+}
+
+// hrefOf returns the value if it is a string, or the "href" of the value if
+// it is an object.
+func hrefOf(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case map[string]interface{}:
+		if href, ok := t["href"].(string); ok {
+			return strings.TrimSpace(href)
+		}
+	}
+
+	return ""
+}
+
+// hypermediaLinks walks the document and calls add with the links of the
+// hypermedia conventions and their relations.
+func hypermediaLinks(v interface{}, add func(href, rel string)) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			hypermediaLinks(e, add)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(t) {
+			value := t[key]
+
+			switch key {
+			case "_links", "links":
+				relationLinks(value, add)
+				continue
+			case "next":
+				if s, ok := value.(string); ok && looksLikeURL(s) {
+					add(s, "next")
+					continue
+				}
+			}
+
+			hypermediaLinks(value, add)
+		}
+	}
+}
+
+// relationLinks calls add with the links of a HAL or JSON:API links object,
+// e.g. {"self": {"href": "/a"}, "next": "/a?page=2"}, or of a Siren links
+// array, e.g. [{"rel": ["self"], "href": "/a"}]. Templated HAL links are
+// skipped.
+func relationLinks(v interface{}, add func(href, rel string)) {
+	link := func(rel string, l interface{}) {
+		if m, ok := l.(map[string]interface{}); ok && m["templated"] == true {
+			return
+		}
+
+		if href := hrefOf(l); href != "" {
+			add(href, rel)
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, rel := range sortedKeys(t) {
+			l := t[rel]
+			if ls, ok := l.([]interface{}); ok {
+				for _, e := range ls {
+					link(rel, e)
+				}
+				continue
+			}
+
+			link(rel, l)
+		}
+	case []interface{}:
+		for _, e := range t {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var rel string
+			if rels, ok := m["rel"].([]interface{}); ok && len(rels) != 0 {
+				rel, _ = rels[0].(string)
+			}
+
+			link(rel, m)
+		}
+	}
+}
+
+func looksLikeURL(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "/")
+}
+
+// jsonStep is a step of a compiled JSONPath-style expression.
+type jsonStep struct {
+	// key is the object key to select, or "*" for all children.
+	key string
+
+	// index is the array element to select, if key is empty.
+	index int
+
+	// recursive selects the matching descendants instead of children.
+	recursive bool
+}
+
+type jsonPath []jsonStep
+
+func compileJSONPath(expr string) (jsonPath, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("must start with $")
+	}
+	s = s[1:]
+
+	var path jsonPath
+	for s != "" {
+		var step jsonStep
+
+		switch {
+		case strings.HasPrefix(s, ".."):
+			step.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(s, "."):
+			s = strings.TrimPrefix(s, ".")
+
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("missing key at %q", s)
+			}
+
+			step.key, s = s[:end], s[end:]
+			path = append(path, step)
+			continue
+		case !strings.HasPrefix(s, "["):
+			return nil, fmt.Errorf("unexpected %q", s)
+		}
+
+		end := strings.Index(s, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("missing ] in %q", s)
+		}
+
+		sel := strings.TrimSpace(s[1:end])
+		s = s[end+1:]
+
+		switch {
+		case sel == "*":
+			step.key = "*"
+		case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+			step.key = sel[1 : len(sel)-1]
+		default:
+			i, err := strconv.Atoi(sel)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid selector [%s]", sel)
+			}
+
+			if step.recursive {
+				return nil, fmt.Errorf("indexes can not be selected recursively")
+			}
+
+			step.index = i
+		}
+
+		path = append(path, step)
+	}
+
+	return path, nil
+}
+
+// eval returns the values selected by the path.
+func (p jsonPath) eval(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+
+	for _, step := range p {
+		var next []interface{}
+		for _, v := range current {
+			if step.recursive {
+				next = append(next, descendants(v, step.key)...)
+			} else {
+				next = append(next, children(v, step)...)
+			}
+		}
+
+		current = next
+	}
+
+	return current
+}
+
+func children(v interface{}, step jsonStep) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if step.key == "*" {
+			values := make([]interface{}, 0, len(t))
+			for _, key := range sortedKeys(t) {
+				values = append(values, t[key])
+			}
+			return values
+		}
+
+		if value, ok := t[step.key]; ok {
+			return []interface{}{value}
+		}
+	case []interface{}:
+		if step.key == "*" {
+			return t
+		}
+
+		if step.key == "" && step.index < len(t) {
+			return []interface{}{t[step.index]}
+		}
+	}
+
+	return nil
+}
+
+// descendants returns the values of the key, or all values if key is "*",
+// found at any depth below v.
+func descendants(v interface{}, key string) []interface{} {
+	var values []interface{}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(t) {
+			if key == "*" || k == key {
+				values = append(values, t[k])
+			}
+
+			values = append(values, descendants(t[k], key)...)
+		}
+	case []interface{}:
+		for _, e := range t {
+			if key == "*" {
+				values = append(values, e)
+			}
+
+			values = append(values, descendants(e, key)...)
+		}
+	}
+
+	return values
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// nextLinksIn returns the rel="next" links of the Link headers, e.g.
+// `<https://api.example.com/items?page=2>; rel="next"`, resolved against
+// the url of the page.
+func nextLinksIn(pageURL string, header http.Header) []Link {
+	var links []Link
+
+	for _, value := range header.Values("Link") {
+		for _, part := range splitLinkHeader(value) {
+			part = strings.TrimSpace(part)
+			if !strings.HasPrefix(part, "<") {
+				continue
+			}
+
+			end := strings.Index(part, ">")
+			if end == -1 {
+				continue
+			}
+
+			target := part[1:end]
+
+			for _, param := range strings.Split(part[end+1:], ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+
+				if !hasToken(strings.Trim(strings.TrimSpace(value), `"`), "next") {
+					continue
+				}
+
+				href, err := resolveURL(pageURL, target)
+				if err != nil {
+					continue
+				}
+
+				links = append(links, Link{LinkedFrom: pageURL, Href: href, Text: "next", Kind: KindPagination})
+			}
+		}
+	}
+
+	return links
+}
+
+// splitLinkHeader splits the value of a Link header at the commas between
+// the links, keeping the ones inside the urls and quoted parameters.
+func splitLinkHeader(value string) []string {
+	var (
+		parts          []string
+		start          int
+		inURL, inQuote bool
+	)
+
+	for i, r := range value {
+		switch {
+		case r == '<' && !inQuote:
+			inURL = true
+		case r == '>' && !inQuote:
+			inURL = false
+		case r == '"' && !inURL:
+			inQuote = !inQuote
+		case r == ',' && !inURL && !inQuote:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
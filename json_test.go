@@ -0,0 +1,198 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func hrefsOf(links []Link) []string {
+	hrefs := make([]string, len(links))
+	for i, l := range links {
+		hrefs[i] = fmt.Sprintf("%s %s %s", l.Kind, l.Text, l.Href)
+	}
+
+	return hrefs
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			"hal",
+			`{"_links": {"self": {"href": "/orders"}, "next": {"href": "/orders?page=2"}, "find": {"href": "/orders{?id}", "templated": true}},
+			"_embedded": {"orders": [{"total": 30, "_links": {"self": {"href": "/orders/123"}, "curies": [{"href": "/docs/rels/{rel}", "templated": true}]}}]}}`,
+			[]string{"json self /orders/123", "pagination next /orders?page=2", "json self /orders"},
+		},
+		{
+			"json:api",
+			`{"links": {"self": "http://example.com/articles", "next": "http://example.com/articles?page[offset]=2"},
+			"data": [{"type": "articles", "id": "1", "relationships": {"author": {"links": {"related": {"href": "http://example.com/articles/1/author"}}}}}]}`,
+			[]string{"json related http://example.com/articles/1/author", "pagination next http://example.com/articles?page[offset]=2", "json self http://example.com/articles"},
+		},
+		{
+			"siren",
+			`{"class": ["order"], "links": [{"rel": ["self"], "href": "/orders/42"}, {"rel": ["next"], "href": "/orders/43"}]}`,
+			[]string{"json self /orders/42", "pagination next /orders/43"},
+		},
+		{
+			"plain next",
+			`{"results": [{"name": "a"}], "next": "https://api.example.com/items?cursor=abc", "previous": null, "meta": {"next": "not a url"}}`,
+			[]string{"pagination next https://api.example.com/items?cursor=abc"},
+		},
+		{"no links", `[1, 2, "three"]`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := ParseJSON("http://example.com/", []byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseJSON() error = %v", err)
+			}
+
+			if got := hrefsOf(links); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseJSON("http://example.com/", []byte(`{"unterminated": `)); err == nil {
+		t.Errorf("ParseJSON() accepted invalid json")
+	}
+}
+
+func TestNewJSONParser(t *testing.T) {
+	body := `{"items": [{"url": "/a", "image": {"href": "/a.png"}}, {"url": "/b", "count": 3}],
+		"owner": {"profile": "/users/1", "_links": {"self": {"href": "/users/1/self"}}}}`
+
+	tests := []struct {
+		name       string
+		paths      []string
+		hypermedia bool
+		want       []string
+	}{
+		{"children", []string{"$.items[*].url"}, false, []string{"json  /a", "json  /b"}},
+		{"index and quoted key", []string{"$.items[1]['url']", `$["owner"].profile`}, false, []string{"json  /b", "json  /users/1"}},
+		{"objects with href", []string{"$.items[0].image"}, false, []string{"json  /a.png"}},
+		{"descendants", []string{"$..href"}, false, []string{"json  /a.png", "json  /users/1/self"}},
+		{"all descendants", []string{"$.items[1]..*"}, false, []string{"json  /b"}},
+		{"with hypermedia", []string{"$.owner.profile"}, true, []string{"json  /users/1", "json self /users/1/self"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewJSONParser(tt.paths, tt.hypermedia)
+			if err != nil {
+				t.Fatalf("NewJSONParser() error = %v", err)
+			}
+
+			links, err := p("http://example.com/", []byte(body))
+			if err != nil {
+				t.Fatalf("parser error = %v", err)
+			}
+
+			if got := hrefsOf(links); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parser = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_compileJSONPath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    jsonPath
+		wantErr bool
+	}{
+		{"$", nil, false},
+		{"$.a.b", jsonPath{{key: "a"}, {key: "b"}}, false},
+		{"$.a[2][*]", jsonPath{{key: "a"}, {index: 2}, {key: "*"}}, false},
+		{"$..a.*", jsonPath{{key: "a", recursive: true}, {key: "*"}}, false},
+		{"$..['a b']", jsonPath{{key: "a b", recursive: true}}, false},
+		{"a.b", nil, true},
+		{"$.", nil, true},
+		{"$.a[", nil, true},
+		{"$.a[x]", nil, true},
+		{"$.a[-1]", nil, true},
+		{"$..[0]", nil, true},
+		{"$a", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := compileJSONPath(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileJSONPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compileJSONPath() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nextLinksIn(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.example.com/items?page=1>; rel="prev first", </items?page=3&sort=a,b>; rel="next"`)
+	header.Add("Link", `<https://api.example.com/items?page=9>; title="last, really"; rel=last`)
+
+	want := []string{"pagination next https://api.example.com/items?page=3&sort=a,b"}
+	if got := hrefsOf(nextLinksIn("https://api.example.com/items?page=2", header)); !reflect.DeepEqual(got, want) {
+		t.Errorf("nextLinksIn() = %q, want %q", got, want)
+	}
+}
+
+func TestCrawler_json(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/hal+json")
+
+		switch r.URL.RequestURI() {
+		case "/":
+			fmt.Fprint(w, `{"_links": {"items": {"href": "/items"}}}`)
+		case "/items":
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+			fmt.Fprint(w, `{"count": 2}`)
+		case "/items?page=2":
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			fmt.Fprint(w, `{"data": [{"id": "1", "links": {"self": "/items/1"}}]}`)
+		case "/items/1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id": "1", "image": "/images/1.png"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	var (
+		mu      sync.Mutex
+		visited []string
+	)
+
+	c := testCrawler(t, ts.URL, CrawlOptions{JSON: JSONOptions{Paths: []string{"$.image"}, FollowLinkHeaders: true}})
+	c.HandleResultFunc(func(r Result) {
+		mu.Lock()
+		visited = append(visited, fmt.Sprintf("%d %s", r.Status, strings.TrimPrefix(r.URL, ts.URL)))
+		mu.Unlock()
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	sort.Strings(visited)
+	want := []string{"200 ", "200 /items", "200 /items/1", "200 /items?page=2", "404 /images/1.png"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %q, want %q", visited, want)
+	}
+}
+
+func TestJSONOptions_validate(t *testing.T) {
+	if err := (JSONOptions{Paths: []string{"$.items[*].url", "items"}}).validate(); err == nil {
+		t.Errorf("validate() accepted an invalid path")
+	}
+}
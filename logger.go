@@ -0,0 +1,117 @@
+package brink
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Log levels used by the StdLogger
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// Logger is used by the crawler to log its progress. The args are alternating
+// keys and values, e.g. "worker", "worker-1", "url", "https://example.com".
+// The interface is satisfied by *slog.Logger, so it can be used as is.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// nopLogger discards everything. It is the default logger of the crawler.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// StdLogger is a Logger writing lines like
+//
+//	2018/01/02 15:04:05 INFO spawning worker worker=worker-1
+//
+// through the standard library's log package. Messages below its level are
+// discarded.
+type StdLogger struct {
+	logger *log.Logger
+	level  int
+}
+
+// NewStdLogger returns a StdLogger writing to w the messages at or above
+// the level.
+func NewStdLogger(w io.Writer, level int) *StdLogger {
+	return &StdLogger{
+		logger: log.New(w, "", log.LstdFlags),
+		level:  level,
+	}
+}
+
+// ParseLevel returns the level belonging to its name, e.g. "debug" or "warn".
+func ParseLevel(name string) (int, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+
+	if strings.EqualFold(name, "warning") {
+		return LevelWarn, nil
+	}
+
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Debug logs at LevelDebug.
+func (l *StdLogger) Debug(msg string, args ...interface{}) { l.log(LevelDebug, msg, args) }
+
+// Info logs at LevelInfo.
+func (l *StdLogger) Info(msg string, args ...interface{}) { l.log(LevelInfo, msg, args) }
+
+// Warn logs at LevelWarn.
+func (l *StdLogger) Warn(msg string, args ...interface{}) { l.log(LevelWarn, msg, args) }
+
+// Error logs at LevelError.
+func (l *StdLogger) Error(msg string, args ...interface{}) { l.log(LevelError, msg, args) }
+
+func (l *StdLogger) log(level int, msg string, args []interface{}) {
+	if level < l.level {
+		return
+	}
+
+	var b strings.Builder
+
+	b.WriteString(levelNames[level])
+	b.WriteByte(' ')
+	b.WriteString(msg)
+
+	for i := 0; i < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+
+		var val interface{} = "!MISSING"
+		if i+1 < len(args) {
+			val = args[i+1]
+		}
+
+		fmt.Fprintf(&b, " %s=%s", key, formatLogValue(val))
+	}
+
+	l.logger.Println(b.String())
+}
+
+func formatLogValue(val interface{}) string {
+	s := fmt.Sprint(val)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+
+	return s
+}
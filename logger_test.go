@@ -0,0 +1,79 @@
+package brink
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(&buf, LevelInfo)
+
+	l.Debug("hidden message", "worker", "worker-1")
+	l.Info("visited url", "worker", "worker-1", "url", "https://liferay.com", "status", 200)
+	l.Warn("failed parsing links", "error", fmt.Errorf("bad html"), "odd")
+	l.Error("empty value", "value", "")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", len(lines), buf.String())
+	}
+
+	wants := []string{
+		`INFO visited url worker=worker-1 url=https://liferay.com status=200`,
+		`WARN failed parsing links error="bad html" odd=!MISSING`,
+		`ERROR empty value value=""`,
+	}
+	for i, want := range wants {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d = %q, want suffix %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantErr bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{"error", LevelError, false},
+		{"verbose", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLevel() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_SetLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	c, _ := NewCrawlerWithOpts("https://liferay.com", CrawlOptions{Logger: NewStdLogger(&buf, LevelDebug)})
+	c.AllowDomains("https://")
+
+	if !strings.Contains(buf.String(), `WARN failed allowing domain domain=https://`) {
+		t.Errorf("logger was not used: %q", buf.String())
+	}
+
+	c.SetLogger(nil)
+	c.AllowDomains("https://")
+
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("logs were not discarded: %q", buf.String())
+	}
+}
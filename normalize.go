@@ -0,0 +1,305 @@
+package brink
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Trailing slash policies for URLNormalization.TrailingSlash
+const (
+	TrailingSlashKeep   = ""
+	TrailingSlashAdd    = "add"
+	TrailingSlashRemove = "remove"
+)
+
+// URLNormalization controls the rules that are applied to URLs before checking whether
+// they have already been visited. Each of the rules can be toggled separately. The zero
+// value sorts the GET parameters and drops the fragment, which is how URLs have always
+// been compared.
+type URLNormalization struct {
+	// LowercaseHost lowercases the host and converts internationalized domain names to
+	// their punycode form.
+	LowercaseHost bool `toml:"lowercase-host" yaml:"lowercase-host" json:"lowercase-host"`
+
+	// RemoveDefaultPort removes the port if it is the default one for the scheme, e.g.
+	// :80 for http and :443 for https.
+	RemoveDefaultPort bool `toml:"remove-default-port" yaml:"remove-default-port" json:"remove-default-port"`
+
+	// ResolveDotSegments removes "." and ".." segments from the path.
+	ResolveDotSegments bool `toml:"resolve-dot-segments" yaml:"resolve-dot-segments" json:"resolve-dot-segments"`
+
+	// DecodeUnreserved decodes percent-encoded unreserved characters (letters, digits,
+	// "-", ".", "_" and "~") in the path, and uppercases the remaining escapes.
+	DecodeUnreserved bool `toml:"decode-unreserved" yaml:"decode-unreserved" json:"decode-unreserved"`
+
+	// TrailingSlash unifies the trailing slashes of paths. Possible values are "add",
+	// "remove" or an empty string to leave the path as is.
+	TrailingSlash string `toml:"trailing-slash" yaml:"trailing-slash" json:"trailing-slash"`
+
+	// KeepFragment keeps the fragment (e.g. "#section") of the URL. By default it is
+	// removed, as it does not change the page that is fetched.
+	KeepFragment bool `toml:"keep-fragment" yaml:"keep-fragment" json:"keep-fragment"`
+
+	// KeepParameterOrder keeps the GET parameters in the order they appear in the URL.
+	// By default they are sorted by their keys.
+	KeepParameterOrder bool `toml:"keep-parameter-order" yaml:"keep-parameter-order" json:"keep-parameter-order"`
+
+	// HonorCanonical makes the crawler read the <link rel="canonical"> tag of pages.
+	// Links are only followed once from all the pages sharing the same canonical URL.
+	HonorCanonical bool `toml:"honor-canonical" yaml:"honor-canonical" json:"honor-canonical"`
+}
+
+// urlRule is a single step of the normalization pipeline.
+type urlRule struct {
+	enabled func(n URLNormalization) bool
+	apply   func(n URLNormalization, u *url.URL)
+}
+
+// urlRules lists the steps of the normalization pipeline in the order
+// they are applied.
+var urlRules = []urlRule{
+	{
+		enabled: func(n URLNormalization) bool { return n.LowercaseHost },
+		apply:   lowercaseHost,
+	},
+	{
+		enabled: func(n URLNormalization) bool { return n.RemoveDefaultPort },
+		apply:   removeDefaultPort,
+	},
+	{
+		enabled: func(n URLNormalization) bool { return n.DecodeUnreserved },
+		apply:   decodeUnreserved,
+	},
+	{
+		enabled: func(n URLNormalization) bool { return n.ResolveDotSegments },
+		apply:   resolveDotSegments,
+	},
+	{
+		enabled: func(n URLNormalization) bool { return n.TrailingSlash != TrailingSlashKeep },
+		apply:   trailingSlash,
+	},
+}
+
+func (n URLNormalization) validate() error {
+	switch n.TrailingSlash {
+	case TrailingSlashKeep, TrailingSlashAdd, TrailingSlashRemove:
+		return nil
+	}
+
+	return fmt.Errorf("unknown trailing slash policy %q", n.TrailingSlash)
+}
+
+// normalize runs the URL through the normalization pipeline and returns
+// its canonical string form. ignoreParam reports whether a GET parameter
+// should be left out of the result.
+func (n URLNormalization) normalize(_url string, ignoreParam func(key string) bool) (string, error) {
+	_url = strings.TrimSpace(_url)
+
+	// ParseRequestURI does not know about fragments, so they need to be
+	// cut off before parsing.
+	var fragment string
+	if i := strings.Index(_url, "#"); i != -1 {
+		_url, fragment = _url[:i], _url[i+1:]
+	}
+
+	u, err := url.ParseRequestURI(_url)
+	if err != nil {
+		return "", fmt.Errorf("failed parsing url: %v", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("not an absolute url: %q", _url)
+	}
+
+	// Work on the escaped path so that escapes survive the round trip.
+	u.RawPath = u.EscapedPath()
+
+	for _, rule := range urlRules {
+		if rule.enabled(n) {
+			rule.apply(n, u)
+		}
+	}
+
+	result := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.RawPath)
+
+	if query := n.query(u.RawQuery, ignoreParam); query != "" {
+		result = fmt.Sprintf("%s?%s", result, query)
+	}
+
+	if n.KeepFragment && fragment != "" {
+		result = fmt.Sprintf("%s#%s", result, fragment)
+	}
+
+	return result, nil
+}
+
+// query returns the encoded form of the GET parameters, leaving out
+// the ignored ones.
+func (n URLNormalization) query(rawQuery string, ignoreParam func(key string) bool) string {
+	var result []string
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, val := pair, ""
+		if i := strings.Index(pair, "="); i != -1 {
+			key, val = pair[:i], pair[i+1:]
+		}
+
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+
+		if ignoreParam(key) {
+			continue
+		}
+
+		if val, err = url.QueryUnescape(val); err != nil {
+			continue
+		}
+
+		if val == "" {
+			result = append(result, url.QueryEscape(key))
+			continue
+		}
+
+		result = append(result, fmt.Sprintf("%s=%s", url.QueryEscape(key), url.QueryEscape(val)))
+	}
+
+	if !n.KeepParameterOrder {
+		sort.Strings(result)
+	}
+
+	return strings.Join(result, "&")
+}
+
+func lowercaseHost(_ URLNormalization, u *url.URL) {
+	host, port := splitHostPort(u.Host)
+
+	host, err := normalizeHost(host)
+	if err != nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	u.Host = joinHostPort(host, port)
+}
+
+func removeDefaultPort(_ URLNormalization, u *url.URL) {
+	host, port := splitHostPort(u.Host)
+
+	if port == defaultPort(u.Scheme) {
+		u.Host = joinHostPort(host, "")
+	}
+}
+
+func decodeUnreserved(_ URLNormalization, u *url.URL) {
+	var b strings.Builder
+
+	p := u.RawPath
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) || !isHex(p[i+1]) || !isHex(p[i+2]) {
+			b.WriteByte(p[i])
+			continue
+		}
+
+		c := unhex(p[i+1])<<4 | unhex(p[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(p[i : i+3]))
+		}
+		i += 2
+	}
+
+	u.RawPath = b.String()
+}
+
+func resolveDotSegments(_ URLNormalization, u *url.URL) {
+	u.RawPath = removeDotSegments(u.RawPath)
+}
+
+func trailingSlash(n URLNormalization, u *url.URL) {
+	switch n.TrailingSlash {
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(u.RawPath, "/") {
+			u.RawPath += "/"
+		}
+	case TrailingSlashRemove:
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+}
+
+// removeDotSegments implements the algorithm of the same name described
+// in RFC 3986, section 5.2.4.
+func removeDotSegments(p string) string {
+	if p == "" {
+		return p
+	}
+
+	var out []string
+
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(p, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+
+	return result
+}
+
+func joinHostPort(host, port string) string {
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	if port == "" {
+		return host
+	}
+
+	return host + ":" + port
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+
+	return c - 'A' + 10
+}
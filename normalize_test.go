@@ -0,0 +1,107 @@
+package brink
+
+import "testing"
+
+func TestURLNormalization_normalize(t *testing.T) {
+	all := URLNormalization{
+		LowercaseHost:      true,
+		RemoveDefaultPort:  true,
+		ResolveDotSegments: true,
+		DecodeUnreserved:   true,
+		TrailingSlash:      TrailingSlashRemove,
+	}
+	noIgnore := func(string) bool { return false }
+
+	type args struct {
+		n    URLNormalization
+		_url string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"defaults keep host", args{URLNormalization{}, "https://Liferay.com:443/a/./b"}, "https://Liferay.com:443/a/./b", false},
+		{"defaults drop fragment", args{URLNormalization{}, "https://liferay.com/page#section"}, "https://liferay.com/page", false},
+		{"defaults drop fragment after query", args{URLNormalization{}, "https://liferay.com/page?b=2&a=1#section"}, "https://liferay.com/page?a=1&b=2", false},
+		{"defaults escape ampersand", args{URLNormalization{}, "https://liferay.com/?q=a%26b&p=1"}, "https://liferay.com/?p=1&q=a%26b", false},
+		{"defaults keep path escapes", args{URLNormalization{}, "https://liferay.com/a%2Fb"}, "https://liferay.com/a%2Fb", false},
+		{"keep fragment", args{URLNormalization{KeepFragment: true}, "https://liferay.com/page#section"}, "https://liferay.com/page#section", false},
+		{"keep parameter order", args{URLNormalization{KeepParameterOrder: true}, "https://liferay.com/?b=2&a=1"}, "https://liferay.com/?b=2&a=1", false},
+		{"lowercase host", args{URLNormalization{LowercaseHost: true}, "https://WWW.Liferay.com/Path"}, "https://www.liferay.com/Path", false},
+		{"lowercase idn host", args{URLNormalization{LowercaseHost: true}, "https://Bücher.example/"}, "https://xn--bcher-kva.example/", false},
+		{"remove default port", args{URLNormalization{RemoveDefaultPort: true}, "http://liferay.com:80/"}, "http://liferay.com/", false},
+		{"keep other port", args{URLNormalization{RemoveDefaultPort: true}, "http://liferay.com:443/"}, "http://liferay.com:443/", false},
+		{"resolve dot segments", args{URLNormalization{ResolveDotSegments: true}, "https://liferay.com/a/./b/../c"}, "https://liferay.com/a/c", false},
+		{"resolve dot segments above root", args{URLNormalization{ResolveDotSegments: true}, "https://liferay.com/../a"}, "https://liferay.com/a", false},
+		{"resolve trailing dot", args{URLNormalization{ResolveDotSegments: true}, "https://liferay.com/a/b/.."}, "https://liferay.com/a/", false},
+		{"decode unreserved", args{URLNormalization{DecodeUnreserved: true}, "https://liferay.com/%7euser/%41%2f"}, "https://liferay.com/~user/A%2F", false},
+		{"add trailing slash", args{URLNormalization{TrailingSlash: TrailingSlashAdd}, "https://liferay.com/a"}, "https://liferay.com/a/", false},
+		{"add trailing slash to root", args{URLNormalization{TrailingSlash: TrailingSlashAdd}, "https://liferay.com"}, "https://liferay.com/", false},
+		{"remove trailing slash", args{URLNormalization{TrailingSlash: TrailingSlashRemove}, "https://liferay.com/a/"}, "https://liferay.com/a", false},
+		{"all rules", args{all, "HTTPS://Liferay.COM:443/a/../%62/?z=1&y#frag"}, "https://liferay.com/b?y&z=1", false},
+
+		{"relative", args{URLNormalization{}, "/some/path"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.args.n.normalize(tt.args._url, noIgnore)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("normalize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("normalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalIn(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no canonical", `<html><head><title>Title</title></head><body></body></html>`, ""},
+		{"canonical", `<html><head><link rel="canonical" href="https://liferay.com/page"></head></html>`, "https://liferay.com/page"},
+		{"self closing", `<html><head><link rel="Canonical" href="/page" /></head></html>`, "/page"},
+		{"other rel", `<html><head><link rel="stylesheet" href="/style.css"></head></html>`, ""},
+		{"in body", `<html><body><link rel="canonical" href="/page"></body></html>`, ""},
+		{"body title", `<html><head><title>body</title><link rel="canonical" href="/page"></head></html>`, "/page"},
+		{"body comment", `<html><head><!--body--><link rel="canonical" href="/page"></head></html>`, "/page"},
+		{"body end tag", `<html><head></body><link rel="canonical" href="/page"></head></html>`, "/page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalIn([]byte(tt.body)); got != tt.want {
+				t.Errorf("CanonicalIn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_firstCanonical(t *testing.T) {
+	c, _ := NewCrawler("https://liferay.com")
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+		want bool
+	}{
+		{"first page", "https://liferay.com/page?session=1", `<link rel="canonical" href="/page">`, true},
+		{"same canonical", "https://liferay.com/page?session=2", `<link rel="canonical" href="https://liferay.com/page">`, false},
+		{"canonical itself", "https://liferay.com/page", `<html></html>`, false},
+		{"other page", "https://liferay.com/other", `<html></html>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.firstCanonical(tt.url, []byte(tt.body)); got != tt.want {
+				t.Errorf("firstCanonical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package brink
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Page gives access to the content of a fetched HTML page. The body is only
+// parsed once something is asked of the page, and at most once. It is safe
+// for concurrent use.
+//
+// Handlers can wrap the body they receive, e.g.
+//
+//	p := brink.NewPage(url, []byte(body))
+//	log.Printf("%s: %s", url, p.Title())
+type Page struct {
+	URL  string
+	Body []byte
+
+	once sync.Once
+	doc  *html.Node
+	err  error
+}
+
+// Heading is a h1-h6 element of a page.
+type Heading struct {
+	Level int
+	Text  string
+}
+
+// Hreflang is a link to a version of the page in another language, as
+// given by <link rel="alternate" hreflang="..." href="...">.
+type Hreflang struct {
+	Lang string
+	Href string
+}
+
+// NewPage returns the Page of the body fetched from the url.
+func NewPage(url string, body []byte) *Page {
+	return &Page{URL: url, Body: body}
+}
+
+// Document returns the root node of the parsed page.
+func (p *Page) Document() (*html.Node, error) {
+	p.once.Do(func() {
+		p.doc, p.err = html.Parse(bytes.NewReader(p.Body))
+		if p.err != nil {
+			p.err = fmt.Errorf("failed parsing page: %v", p.err)
+		}
+	})
+
+	return p.doc, p.err
+}
+
+// Find returns the elements matching the CSS selector, e.g. "div.content > p".
+func (p *Page) Find(selector string) ([]*html.Node, error) {
+	sel, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+	}
+
+	doc, err := p.Document()
+	if err != nil {
+		return nil, err
+	}
+
+	return sel.MatchAll(doc), nil
+}
+
+// FindText returns the text of the elements matching the CSS selector.
+func (p *Page) FindText(selector string) ([]string, error) {
+	nodes, err := p.Find(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, len(nodes))
+	for i, n := range nodes {
+		texts[i] = NodeText(n)
+	}
+
+	return texts, nil
+}
+
+// Title returns the text of the <title> element of the page.
+func (p *Page) Title() string {
+	if n := p.first(func(n *html.Node) bool { return n.DataAtom == atom.Title }); n != nil {
+		return NodeText(n)
+	}
+
+	return ""
+}
+
+// Meta returns the content of the <meta> element whose name or property
+// is the given one, e.g. "description" or "og:title". Names are compared
+// case-insensitively.
+func (p *Page) Meta(name string) string {
+	n := p.first(func(n *html.Node) bool {
+		if n.DataAtom != atom.Meta {
+			return false
+		}
+
+		return strings.EqualFold(NodeAttr(n, "name"), name) || strings.EqualFold(NodeAttr(n, "property"), name)
+	})
+	if n == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(NodeAttr(n, "content"))
+}
+
+// MetaDescription returns the content of the description <meta> element.
+func (p *Page) MetaDescription() string {
+	return p.Meta("description")
+}
+
+// Headings returns the h1-h6 elements of the page in document order.
+func (p *Page) Headings() []Heading {
+	var headings []Heading
+
+	p.walk(func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			level, _ := strconv.Atoi(n.Data[1:])
+			headings = append(headings, Heading{Level: level, Text: NodeText(n)})
+		}
+
+		return false
+	})
+
+	return headings
+}
+
+// Canonical returns the url of the <link rel="canonical"> element of the
+// page resolved against the url of the page, or an empty string if there is
+// none.
+func (p *Page) Canonical() string {
+	n := p.first(func(n *html.Node) bool { return n.DataAtom == atom.Link && hasRel(n, "canonical") })
+	if n == nil {
+		return ""
+	}
+
+	return p.resolve(NodeAttr(n, "href"))
+}
+
+// Hreflangs returns the alternate language versions of the page, with their
+// urls resolved against the url of the page.
+func (p *Page) Hreflangs() []Hreflang {
+	var langs []Hreflang
+
+	p.walk(func(n *html.Node) bool {
+		if n.DataAtom == atom.Link && hasRel(n, "alternate") && NodeAttr(n, "hreflang") != "" {
+			langs = append(langs, Hreflang{
+				Lang: strings.TrimSpace(NodeAttr(n, "hreflang")),
+				Href: p.resolve(NodeAttr(n, "href")),
+			})
+		}
+
+		return false
+	})
+
+	return langs
+}
+
+// Language returns the language of the page, as given by the lang attribute
+// of the <html> element, or the Content-Language <meta> element if there is
+// no such attribute.
+func (p *Page) Language() string {
+	if n := p.first(func(n *html.Node) bool { return n.DataAtom == atom.Html }); n != nil {
+		if lang := strings.TrimSpace(NodeAttr(n, "lang")); lang != "" {
+			return lang
+		}
+	}
+
+	n := p.first(func(n *html.Node) bool {
+		return n.DataAtom == atom.Meta && strings.EqualFold(NodeAttr(n, "http-equiv"), "content-language")
+	})
+	if n == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(NodeAttr(n, "content"))
+}
+
+// Text returns the text content of the body of the page, with the contents
+// of scripts and styles left out and whitespace collapsed.
+func (p *Page) Text() string {
+	if n := p.first(func(n *html.Node) bool { return n.DataAtom == atom.Body }); n != nil {
+		return NodeText(n)
+	}
+
+	return ""
+}
+
+// first returns the first node in document order the match function returns
+// true for, or nil if there is none.
+func (p *Page) first(match func(n *html.Node) bool) *html.Node {
+	var found *html.Node
+
+	p.walk(func(n *html.Node) bool {
+		if match(n) {
+			found = n
+			return true
+		}
+
+		return false
+	})
+
+	return found
+}
+
+// walk calls f for each element of the page in document order until f
+// returns true.
+func (p *Page) walk(f func(n *html.Node) bool) {
+	doc, err := p.Document()
+	if err != nil {
+		return
+	}
+
+	var visit func(n *html.Node) bool
+	visit = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && f(n) {
+			return true
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if visit(c) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	visit(doc)
+}
+
+func (p *Page) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+
+	resolved, err := resolveURL(p.URL, href)
+	if err != nil {
+		return href
+	}
+
+	return resolved
+}
+
+// inlineElements are the elements whose text is not separated from the
+// text around them.
+var inlineElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true, atom.Cite: true,
+	atom.Code: true, atom.Data: true, atom.Dfn: true, atom.Em: true, atom.I: true, atom.Kbd: true,
+	atom.Label: true, atom.Mark: true, atom.Q: true, atom.S: true, atom.Samp: true, atom.Small: true,
+	atom.Span: true, atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Time: true, atom.U: true,
+	atom.Var: true,
+}
+
+// NodeText returns the text content of the node, with the contents of
+// scripts and styles left out and whitespace collapsed. The texts of block
+// elements are separated by spaces.
+func NodeText(n *html.Node) string {
+	var b strings.Builder
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if isRawTextTag(n.Data) || n.DataAtom == atom.Template {
+				return
+			}
+
+			if !inlineElements[n.DataAtom] {
+				b.WriteByte(' ')
+				defer b.WriteByte(' ')
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+
+	visit(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// NodeAttr returns the value of the attribute of the node, or an empty
+// string if it has no such attribute.
+func NodeAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+func hasRel(n *html.Node, rel string) bool {
+	return hasToken(NodeAttr(n, "rel"), rel)
+}
@@ -0,0 +1,112 @@
+package brink
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html>
+<html lang="en-US">
+<head>
+	<title> Liferay   Portal </title>
+	<meta name="Description" content=" The open source portal. ">
+	<meta property="og:title" content="Liferay">
+	<link rel="canonical" href="/portal">
+	<link rel="alternate" hreflang="hu" href="https://www.liferay.com/hu/portal">
+	<link rel="alternate" hreflang="x-default" href="/portal">
+	<link rel="alternate" type="application/rss+xml" href="/feed">
+	<style>body { color: red; }</style>
+</head>
+<body>
+	<h1>Portal</h1>
+	<div class="content">
+		<h2>Features <small>and more</small></h2>
+		<p>First   paragraph.</p>
+		<script>var notText = 1;</script>
+		<p class="note">Second <b>paragraph</b>.</p>
+	</div>
+	<h3>Footer</h3>
+</body>
+</html>`
+
+func TestPage(t *testing.T) {
+	p := NewPage("https://www.liferay.com/products/", []byte(testPage))
+
+	if got := p.Title(); got != "Liferay Portal" {
+		t.Errorf("Title() = %q", got)
+	}
+
+	if got := p.MetaDescription(); got != "The open source portal." {
+		t.Errorf("MetaDescription() = %q", got)
+	}
+
+	if got := p.Meta("og:title"); got != "Liferay" {
+		t.Errorf("Meta(og:title) = %q", got)
+	}
+
+	if got := p.Canonical(); got != "https://www.liferay.com/portal" {
+		t.Errorf("Canonical() = %q", got)
+	}
+
+	if got := p.Language(); got != "en-US" {
+		t.Errorf("Language() = %q", got)
+	}
+
+	wantHeadings := []Heading{{1, "Portal"}, {2, "Features and more"}, {3, "Footer"}}
+	if got := p.Headings(); !reflect.DeepEqual(got, wantHeadings) {
+		t.Errorf("Headings() = %v, want %v", got, wantHeadings)
+	}
+
+	wantLangs := []Hreflang{
+		{"hu", "https://www.liferay.com/hu/portal"},
+		{"x-default", "https://www.liferay.com/portal"},
+	}
+	if got := p.Hreflangs(); !reflect.DeepEqual(got, wantLangs) {
+		t.Errorf("Hreflangs() = %v, want %v", got, wantLangs)
+	}
+
+	wantText := "Portal Features and more First paragraph. Second paragraph. Footer"
+	if got := p.Text(); got != wantText {
+		t.Errorf("Text() = %q, want %q", got, wantText)
+	}
+}
+
+func TestPage_FindText(t *testing.T) {
+	p := NewPage("https://www.liferay.com/", []byte(testPage))
+
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{"descendants", "div.content p", []string{"First paragraph.", "Second paragraph."}, false},
+		{"class", "p.note > b", []string{"paragraph"}, false},
+		{"no match", "table", []string{}, false},
+		{"invalid", "p[", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.FindText(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindText() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPage_Language(t *testing.T) {
+	p := NewPage("https://www.liferay.com/", []byte(`<html><head><meta http-equiv="Content-Language" content="de"></head></html>`))
+
+	if got := p.Language(); got != "de" {
+		t.Errorf("Language() = %q, want de", got)
+	}
+
+	if got := NewPage("https://www.liferay.com/", nil).Title(); got != "" {
+		t.Errorf("Title() of empty page = %q", got)
+	}
+}
@@ -0,0 +1,264 @@
+package brink
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Parser returns the links found in the body of the page. The hrefs of the
+// links may be relative, the crawler resolves them against the url of the
+// page.
+type Parser func(pageURL string, body []byte) ([]Link, error)
+
+// defaultParsers returns the parsers registered on new crawlers, keyed by
+// media type.
+func defaultParsers() map[string]Parser {
+	return map[string]Parser{
+		"text/html":             ParseHTML,
+		"application/xhtml+xml": ParseHTML,
+		"text/css":              ParseCSS,
+		"application/xml":       ParseXML,
+		"text/xml":              ParseXML,
+		"application/rss+xml":   ParseXML,
+		"application/atom+xml":  ParseXML,
+		"application/pdf":       ParsePDF,
+		"application/json":      ParseJSON,
+	}
+}
+
+// RegisterParser registers the parser to find the links in pages served with
+// the media type, e.g. "application/json", replacing the one registered
+// earlier, if any. Passing a nil parser stops the crawler from following the
+// links of such pages. It should be called before Start.
+//
+// HTML, CSS, RSS and Atom feeds, sitemaps, PDFs and JSON are parsed by default. Pages
+// of an "+xml" or "+json" media type without a parser of their own are parsed as XML
+// or JSON respectively.
+func (c *Crawler) RegisterParser(mediaType string, p Parser) {
+	mediaType = strings.ToLower(mediaType)
+
+	if p == nil {
+		delete(c.parsers, mediaType)
+		return
+	}
+
+	c.parsers[mediaType] = p
+}
+
+// parserFor returns the parser registered for the content type, or nil if
+// there is none. If the content type is empty or generic binary, it is
+// detected from the body.
+func (c *Crawler) parserFor(contentType string, body []byte) Parser {
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = http.DetectContentType(body)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	if p, ok := c.parsers[mediaType]; ok {
+		return p
+	}
+
+	if strings.HasSuffix(mediaType, "+xml") {
+		return c.parsers["application/xml"]
+	}
+
+	if strings.HasSuffix(mediaType, "+json") {
+		return c.parsers["application/json"]
+	}
+
+	return nil
+}
+
+// isHTML reports whether the content type, or if it is empty, the body
+// itself denotes an HTML page.
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// parse returns the links found on the page by the parser registered for
+// its content type, resolved against the url of the page. Links pointing to
+// a fragment of the page itself are kept as is.
+func (c *Crawler) parse(pageURL, contentType string, body []byte) ([]Link, error) {
+	p := c.parserFor(contentType, body)
+	if p == nil {
+		return nil, nil
+	}
+
+	links, err := p(pageURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Link, 0, len(links))
+	for _, l := range links {
+		l.Href = strings.TrimSpace(l.Href)
+		if l.LinkedFrom == "" {
+			l.LinkedFrom = pageURL
+		}
+
+		if strings.HasPrefix(l.Href, "#") {
+			if c.fragments != nil {
+				result = append(result, l)
+			}
+
+			continue
+		}
+
+		href, err := resolveURL(pageURL, l.Href)
+		if err != nil {
+			c.logger.Debug("failed resolving link", "url", pageURL, "href", l.Href, "error", err)
+			continue
+		}
+		l.Href = href
+
+		result = append(result, l)
+	}
+
+	return result, nil
+}
+
+// ParseHTML returns the anchors of the page, along with the stylesheets and
+// the alternate versions, e.g. RSS feeds, linked from its <link> tags.
+func ParseHTML(pageURL string, body []byte) ([]Link, error) {
+	links := LinksIn(pageURL, body, false)
+
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links, nil
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range t.Attr {
+			switch attr.Key {
+			case "rel":
+				rel = strings.ToLower(attr.Val)
+			case "href":
+				href = strings.TrimSpace(attr.Val)
+			}
+		}
+
+		for _, r := range strings.Fields(rel) {
+			if (r == "stylesheet" || r == "alternate") && href != "" {
+				links = append(links, Link{LinkedFrom: pageURL, Href: href, Kind: KindLinkTag})
+				break
+			}
+		}
+	}
+}
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssURL     = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	cssImport  = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// ParseCSS returns the urls referenced by url() and @import rules of the
+// stylesheet. Data URIs are skipped.
+func ParseCSS(pageURL string, body []byte) ([]Link, error) {
+	css := cssComment.ReplaceAll(body, nil)
+
+	var links []Link
+	for _, re := range []*regexp.Regexp{cssImport, cssURL} {
+		for _, m := range re.FindAllSubmatch(css, -1) {
+			href := strings.TrimSpace(string(bytes.Join(m[1:], nil)))
+			if href == "" || strings.HasPrefix(strings.ToLower(href), "data:") {
+				continue
+			}
+
+			links = append(links, Link{LinkedFrom: pageURL, Href: href, Kind: KindStylesheet})
+		}
+	}
+
+	return links, nil
+}
+
+// ParseXML returns the links of RSS and Atom feeds, and the locations listed
+// in sitemaps and sitemap indexes. The kind of the links is KindSitemap for
+// sitemaps and KindFeed for everything else.
+func ParseXML(pageURL string, body []byte) ([]Link, error) {
+	var (
+		links []Link
+		kind  string
+		path  []string
+	)
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return links, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing xml: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			path = append(path, name)
+
+			if kind == "" {
+				kind = KindFeed
+				if name == "urlset" || name == "sitemapindex" {
+					kind = KindSitemap
+				}
+			}
+
+			// Atom links and RSS enclosures carry the url in an attribute
+			for _, attr := range t.Attr {
+				if (name == "link" && attr.Name.Local == "href") || (name == "enclosure" && attr.Name.Local == "url") {
+					links = append(links, Link{LinkedFrom: pageURL, Href: attr.Value, Kind: kind})
+				}
+			}
+		case xml.EndElement:
+			if len(path) != 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if len(path) == 0 {
+				continue
+			}
+
+			name := path[len(path)-1]
+			text := strings.TrimSpace(string(t))
+
+			if text != "" && ((kind == KindSitemap && name == "loc") || (kind == KindFeed && name == "link")) {
+				links = append(links, Link{LinkedFrom: pageURL, Href: text, Kind: kind})
+			}
+		}
+	}
+}
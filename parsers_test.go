@@ -0,0 +1,148 @@
+package brink
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCSS(t *testing.T) {
+	css := []byte(`
+@import "base.css";
+@import url('print.css') print;
+/* background: url(commented.png); */
+body { background: url( "img/bg.png" ); }
+.logo { background-image: url(/img/logo.svg), url(data:image/png;base64,AAAA); }
+`)
+
+	got, err := ParseCSS("https://www.liferay.com/css/main.css", css)
+	if err != nil {
+		t.Fatalf("ParseCSS() error = %v", err)
+	}
+
+	var hrefs []string
+	for _, l := range got {
+		if l.Kind != KindStylesheet {
+			t.Errorf("unexpected kind of %s: %s", l.Href, l.Kind)
+		}
+		hrefs = append(hrefs, l.Href)
+	}
+
+	want := []string{"base.css", "print.css", "img/bg.png", "/img/logo.svg"}
+	if !reflect.DeepEqual(hrefs, want) {
+		t.Errorf("ParseCSS() = %v, want %v", hrefs, want)
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		kind string
+		want []string
+	}{
+		{"rss",
+			`<?xml version="1.0"?><rss version="2.0"><channel><link>https://www.liferay.com/blog</link>
+			<item><link><![CDATA[https://www.liferay.com/blog/1]]></link><enclosure url="https://www.liferay.com/a.mp3" type="audio/mpeg"/></item>
+			</channel></rss>`,
+			KindFeed, []string{"https://www.liferay.com/blog", "https://www.liferay.com/blog/1", "https://www.liferay.com/a.mp3"}},
+		{"atom",
+			`<feed xmlns="http://www.w3.org/2005/Atom"><link href="/feed" rel="self"/><entry><link href="/posts/1"/></entry></feed>`,
+			KindFeed, []string{"/feed", "/posts/1"}},
+		{"sitemap",
+			`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc> https://www.liferay.com/ </loc><lastmod>2018-01-01</lastmod></url><url><loc>https://www.liferay.com/a</loc></url></urlset>`,
+			KindSitemap, []string{"https://www.liferay.com/", "https://www.liferay.com/a"}},
+		{"sitemap index",
+			`<sitemapindex><sitemap><loc>https://www.liferay.com/sitemap-1.xml</loc></sitemap></sitemapindex>`,
+			KindSitemap, []string{"https://www.liferay.com/sitemap-1.xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseXML("https://www.liferay.com/feed", []byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseXML() error = %v", err)
+			}
+
+			var hrefs []string
+			for _, l := range got {
+				if l.Kind != tt.kind {
+					t.Errorf("unexpected kind of %s: %s", l.Href, l.Kind)
+				}
+				hrefs = append(hrefs, l.Href)
+			}
+
+			if !reflect.DeepEqual(hrefs, tt.want) {
+				t.Errorf("ParseXML() = %v, want %v", hrefs, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	body := []byte(`<html><head>
+<link rel="stylesheet" href="/main.css">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="icon" href="/favicon.ico">
+</head><body><a href="page">Page</a></body></html>`)
+
+	got, err := ParseHTML("https://www.liferay.com/", body)
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+
+	want := []Link{
+		{LinkedFrom: "https://www.liferay.com/", Href: "page", Text: "Page", Kind: KindAnchor},
+		{LinkedFrom: "https://www.liferay.com/", Href: "/main.css", Kind: KindLinkTag},
+		{LinkedFrom: "https://www.liferay.com/", Href: "/feed.xml", Kind: KindLinkTag},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHTML() = %v, want %v", got, want)
+	}
+}
+
+func TestCrawler_parse(t *testing.T) {
+	c, _ := NewCrawler("https://www.liferay.com")
+	c.RegisterParser("Application/JSON", func(pageURL string, body []byte) ([]Link, error) {
+		return []Link{{Href: "../api/next"}}, nil
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+	}{
+		{"html resolves relative links", "text/html; charset=utf-8",
+			`<a href="b">B</a><a href="//cdn.liferay.com/x">X</a><a href="#top">Top</a>`,
+			[]string{"https://www.liferay.com/docs/b", "https://cdn.liferay.com/x"}},
+		{"sniffed html", "", `<html><body><a href="/c">C</a></body></html>`, []string{"https://www.liferay.com/c"}},
+		{"feed by suffix", "application/vnd.custom+xml", `<rss><channel><link>/blog</link></channel></rss>`, []string{"https://www.liferay.com/blog"}},
+		{"registered parser", "application/json", `{}`, []string{"https://www.liferay.com/api/next"}},
+		{"sniffed pdf", "application/octet-stream", "%PDF-1.4\n<< /A << /S /URI /URI (report.html) >> >>", []string{"https://www.liferay.com/docs/report.html"}},
+		{"no parser", "image/png", `<a href="/d">D</a>`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := c.parse("https://www.liferay.com/docs/a", tt.contentType, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("parse() error = %v", err)
+			}
+
+			var hrefs []string
+			for _, l := range links {
+				if l.LinkedFrom != "https://www.liferay.com/docs/a" {
+					t.Errorf("unexpected LinkedFrom of %s: %s", l.Href, l.LinkedFrom)
+				}
+				hrefs = append(hrefs, l.Href)
+			}
+
+			if !reflect.DeepEqual(hrefs, tt.want) {
+				t.Errorf("parse() = %v, want %v", hrefs, tt.want)
+			}
+		})
+	}
+
+	c.RegisterParser("text/html", nil)
+	if links, _ := c.parse("https://www.liferay.com/", "text/html", []byte(`<a href="/e">E</a>`)); len(links) != 0 {
+		t.Errorf("expected no links after removing the html parser, got %v", links)
+	}
+}
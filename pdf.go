@@ -0,0 +1,234 @@
+package brink
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxPDFInflatedSize is the most number of bytes the compressed streams of
+// a PDF are decompressed to in total, so that a small document cannot
+// expand to more than the crawler can hold in memory.
+const maxPDFInflatedSize = 32 << 20
+
+var (
+	pdfURI         = regexp.MustCompile(`/URI\s*([(<])`)
+	pdfStream      = regexp.MustCompile(`stream\r?\n`)
+	pdfFlateDecode = []byte("/FlateDecode")
+	pdfEndObj      = []byte("endobj")
+	pdfObj         = []byte(" obj")
+)
+
+// ParsePDF returns the targets of the URI actions of the PDF, e.g. the
+// hyperlinks of its link annotations. Actions stored in compressed object
+// streams are found as well, as long as they are compressed with
+// FlateDecode and decompress to at most 32Mb in total. Each target is
+// returned once.
+func ParsePDF(pageURL string, body []byte) ([]Link, error) {
+	var links []Link
+
+	seen := make(map[string]bool)
+	add := func(data []byte) {
+		for _, uri := range pdfURIsIn(data) {
+			if uri == "" || seen[uri] {
+				continue
+			}
+			seen[uri] = true
+
+			links = append(links, Link{LinkedFrom: pageURL, Href: uri, Kind: KindPDF})
+		}
+	}
+
+	add(body)
+
+	for _, stream := range pdfFlateStreams(body, maxPDFInflatedSize) {
+		add(stream)
+	}
+
+	return links, nil
+}
+
+// pdfURIsIn returns the values of the /URI keys in the data.
+func pdfURIsIn(data []byte) []string {
+	var uris []string
+
+	for _, m := range pdfURI.FindAllSubmatchIndex(data, -1) {
+		start := m[2]
+
+		var s []byte
+		if data[start] == '(' {
+			s = pdfLiteralString(data[start:])
+		} else {
+			s = pdfHexString(data[start:])
+		}
+
+		uris = append(uris, pdfTextString(s))
+	}
+
+	return uris
+}
+
+// pdfFlateStreams returns the decompressed contents of the streams whose
+// dictionaries mention FlateDecode. Streams which fail to decompress, e.g.
+// because they are truncated, are returned as far as they could be read.
+// Once the streams add up to more than limit bytes, the stream going over
+// it and the ones after it are skipped.
+func pdfFlateStreams(body []byte, limit int64) [][]byte {
+	var streams [][]byte
+
+	for _, m := range pdfStream.FindAllIndex(body, -1) {
+		if bytes.HasSuffix(body[:m[0]], []byte("end")) {
+			continue
+		}
+
+		// The dictionary of the stream is between the start of its
+		// object and the stream keyword.
+		dictStart := bytes.LastIndex(body[:m[0]], pdfObj)
+		if dictStart == -1 || bytes.Contains(body[dictStart:m[0]], pdfEndObj) {
+			continue
+		}
+
+		if !bytes.Contains(body[dictStart:m[0]], pdfFlateDecode) {
+			continue
+		}
+
+		r, err := zlib.NewReader(bytes.NewReader(body[m[1]:]))
+		if err != nil {
+			continue
+		}
+
+		data, _ := ioutil.ReadAll(io.LimitReader(r, limit+1))
+		r.Close()
+
+		if int64(len(data)) > limit {
+			break
+		}
+		limit -= int64(len(data))
+
+		if len(data) != 0 {
+			streams = append(streams, data)
+		}
+	}
+
+	return streams
+}
+
+// pdfLiteralString decodes the literal string, e.g. "(https://example.com)",
+// at the start of data.
+func pdfLiteralString(data []byte) []byte {
+	var (
+		s     []byte
+		depth int
+	)
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+		case '\\':
+			i++
+			if i == len(data) {
+				return s
+			}
+
+			switch e := data[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r':
+				// A backslash at the end of the line continues the string
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if e < '0' || e > '7' {
+					s = append(s, e)
+					continue
+				}
+
+				// Octal character code of up to three digits
+				var code byte
+				for n := 0; n < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; n++ {
+					code = code*8 + data[i] - '0'
+					i++
+				}
+				i--
+
+				s = append(s, code)
+			}
+
+			continue
+		}
+
+		s = append(s, c)
+	}
+
+	return s
+}
+
+// pdfHexString decodes the hexadecimal string, e.g. "<68747470>", at the
+// start of data.
+func pdfHexString(data []byte) []byte {
+	end := bytes.IndexByte(data, '>')
+	if end == -1 {
+		return nil
+	}
+
+	digits := make([]byte, 0, end)
+	for _, c := range data[1:end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+
+	// A missing final digit is assumed to be 0
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	s := make([]byte, hex.DecodedLen(len(digits)))
+	if _, err := hex.Decode(s, digits); err != nil {
+		return nil
+	}
+
+	return s
+}
+
+// pdfTextString converts the string to UTF-8. Strings starting with the
+// UTF-16BE byte order mark are decoded, the rest are returned as is.
+func pdfTextString(s []byte) string {
+	if len(s) < 2 || s[0] != 0xFE || s[1] != 0xFF {
+		return string(bytes.TrimSpace(s))
+	}
+
+	s = s[2:]
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+
+	return strings.TrimSpace(string(utf16.Decode(units)))
+}
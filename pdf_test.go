@@ -0,0 +1,115 @@
+package brink
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func deflate(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatalf("failed compressing: %v", err)
+	}
+	w.Close()
+
+	return b.Bytes()
+}
+
+func TestParsePDF(t *testing.T) {
+	compressed := deflate(t, "5 0 obj << /Type /Action /S /URI /URI (https://www.liferay.com/compressed) >> endobj")
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.5\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Annot /Subtype /Link /A << /S /URI /URI (https://www.liferay.com/plain) >> >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Annot /Subtype /Link /A << /S /URI /URI<68747470733A2F2F7777772E6C6966657261792E636F6D2F686578> >> >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /A << /S /URI /URI (https://www.liferay.com/a\\(b\\)\\137c) >> >>\nendobj\n")
+	pdf.WriteString("4 0 obj\n<< /A << /S /URI /URI (https://www.liferay.com/plain) >> >>\nendobj\n")
+	fmt.Fprintf(&pdf, "6 0 obj\n<< /Type /ObjStm /Filter /FlateDecode /Length %d >>\nstream\n", len(compressed))
+	pdf.Write(compressed)
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("7 0 obj\n<< /Length 10 >>\nstream\nnot zipped\nendstream\nendobj\n%%EOF\n")
+
+	got, err := ParsePDF("https://www.liferay.com/doc.pdf", pdf.Bytes())
+	if err != nil {
+		t.Fatalf("ParsePDF() error = %v", err)
+	}
+
+	var hrefs []string
+	for _, l := range got {
+		if l.LinkedFrom != "https://www.liferay.com/doc.pdf" || l.Kind != KindPDF {
+			t.Errorf("unexpected link: %+v", l)
+		}
+		hrefs = append(hrefs, l.Href)
+	}
+
+	want := []string{
+		"https://www.liferay.com/plain",
+		"https://www.liferay.com/hex",
+		"https://www.liferay.com/a(b)_c",
+		"https://www.liferay.com/compressed",
+	}
+	if !reflect.DeepEqual(hrefs, want) {
+		t.Errorf("ParsePDF() = %v, want %v", hrefs, want)
+	}
+}
+
+func Test_pdfLiteralString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"simple", "(abc) rest", "abc"},
+		{"balanced parens", "(a(b)c)", "a(b)c"},
+		{"escapes", `(a\nb\\c\)d)`, "a\nb\\c)d"},
+		{"octal", `(\101\60x)`, "A0x"},
+		{"line continuation", "(ab\\\r\ncd)", "abcd"},
+		{"unterminated", "(abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(pdfLiteralString([]byte(tt.in))); got != tt.want {
+				t.Errorf("pdfLiteralString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pdfTextString(t *testing.T) {
+	utf16 := []byte{0xFE, 0xFF, 0x00, 'h', 0x00, 't', 0x00, 't', 0x00, 'p', 0x00, ':', 0x00, 0xE9}
+
+	if got := pdfTextString(utf16); got != "http:é" {
+		t.Errorf("pdfTextString() = %q, want %q", got, "http:é")
+	}
+
+	if got := pdfTextString([]byte(" https://www.liferay.com ")); got != "https://www.liferay.com" {
+		t.Errorf("pdfTextString() = %q", got)
+	}
+}
+
+func Test_pdfFlateStreams_limit(t *testing.T) {
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.5\n")
+	for i, s := range []string{"first stream", strings.Repeat("0", 1000), "after the limit"} {
+		compressed := deflate(t, s)
+
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Filter /FlateDecode /Length %d >>\nstream\n", i+1, len(compressed))
+		pdf.Write(compressed)
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+
+	got := pdfFlateStreams(pdf.Bytes(), 100)
+	if len(got) != 1 || string(got[0]) != "first stream" {
+		t.Errorf("pdfFlateStreams() = %q, want only the stream within the limit", got)
+	}
+
+	if got := pdfFlateStreams(pdf.Bytes(), 2000); len(got) != 3 {
+		t.Errorf("pdfFlateStreams() returned %d streams, want 3", len(got))
+	}
+}
@@ -0,0 +1,228 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DomainProfile overrides the request settings of CrawlOptions for the
+// domains matching its pattern. Unset fields keep the global settings.
+type DomainProfile struct {
+	// Headers are added to the requests, replacing the global headers of the same name.
+	Headers map[string]string `toml:"headers" yaml:"headers" json:"headers"`
+
+	// AuthType, User and Pass configure the authentication used for the domains instead of
+	// the global one, sent along with every request to them.
+	AuthType int    `toml:"auth-type" yaml:"auth-type" json:"auth-type"`
+	User     string `toml:"user" yaml:"user" json:"user"`
+	Pass     string `toml:"pass" yaml:"pass" json:"pass"`
+
+	// UserAgent replaces the global user agent.
+	UserAgent string `toml:"user-agent" yaml:"user-agent" json:"user-agent"`
+
+	// RateLimit is the most number of requests per second sent to each host of the domains.
+	RateLimit float64 `toml:"rate-limit" yaml:"rate-limit" json:"rate-limit"`
+
+	// Timeout is the time in milliseconds a request may take.
+	Timeout int `toml:"timeout" yaml:"timeout" json:"timeout"`
+
+	// Proxy is the url of the proxy to send the requests through, e.g. "http://proxy:3128"
+	// or "socks5://localhost:1080".
+	Proxy string `toml:"proxy" yaml:"proxy" json:"proxy"`
+}
+
+func (p DomainProfile) validate() error {
+	switch p.AuthType {
+	case AuthNone, AuthBasic:
+	default:
+		return fmt.Errorf("unknown auth type %d", p.AuthType)
+	}
+
+	if p.RateLimit < 0 || p.Timeout < 0 {
+		return fmt.Errorf("rate limit and timeout must not be negative")
+	}
+
+	if p.Proxy != "" {
+		if _, err := parseProxy(p.Proxy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q", proxy)
+	}
+
+	return u, nil
+}
+
+// domainProfile is the compiled form of a DomainProfile.
+type domainProfile struct {
+	pattern string
+	rule    domainRule
+
+	header    http.Header
+	userAgent string
+	rateLimit float64
+
+	// client is nil if the profile uses the client of the crawler.
+	client *http.Client
+}
+
+// setupProfiles compiles the profiles, ordering them from the most to the
+// least specific pattern.
+func setupProfiles(c *Crawler, profiles map[string]DomainProfile) error {
+	for pattern, p := range profiles {
+		rule, err := parseDomainRule(pattern)
+		if err != nil {
+			return fmt.Errorf("failed parsing domain %q: %v", pattern, err)
+		}
+
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("profile %q: %v", pattern, err)
+		}
+
+		dp := domainProfile{
+			pattern:   pattern,
+			rule:      rule,
+			header:    make(http.Header),
+			userAgent: p.UserAgent,
+			rateLimit: p.RateLimit,
+		}
+
+		for k, v := range p.Headers {
+			dp.header.Set(k, v)
+		}
+
+		if p.AuthType == AuthBasic {
+			dp.header.Set(authorizationHeaderName, basicAuth(p.User, p.Pass))
+		}
+
+		if p.Timeout != 0 || p.Proxy != "" {
+			dp.client, err = newClient(c.opts.Timeout, c.opts.Proxy)
+			if err != nil {
+				return fmt.Errorf("profile %q: %v", pattern, err)
+			}
+
+			if p.Timeout != 0 {
+				dp.client.Timeout = time.Duration(p.Timeout) * time.Millisecond
+			}
+
+			if p.Proxy != "" {
+				proxy, _ := parseProxy(p.Proxy)
+				dp.client.Transport.(*http.Transport).Proxy = http.ProxyURL(proxy)
+			}
+		}
+
+		c.profiles = append(c.profiles, &dp)
+	}
+
+	sort.Slice(c.profiles, func(i, j int) bool {
+		a, b := c.profiles[i].rule, c.profiles[j].rule
+
+		switch {
+		case a.subdomains != b.subdomains:
+			return !a.subdomains
+		case len(a.host) != len(b.host):
+			return len(a.host) > len(b.host)
+		case (a.scheme == "") != (b.scheme == ""):
+			return a.scheme != ""
+		case (a.port == "") != (b.port == ""):
+			return a.port != ""
+		}
+
+		return c.profiles[i].pattern < c.profiles[j].pattern
+	})
+
+	return nil
+}
+
+// newClient returns an http client using the timeout in milliseconds and
+// the proxy, if they are set.
+func newClient(timeout int, proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+		u, err := parseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(timeout) * time.Millisecond,
+	}, nil
+}
+
+// profileFor returns the most specific profile matching the url, or nil if
+// there is none.
+func (c *Crawler) profileFor(u *url.URL) *domainProfile {
+	for _, p := range c.profiles {
+		if p.rule.matches(u) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// rateLimiter spaces the requests sent to a host evenly.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next request may be sent, or done is closed. It
+// reports whether the request may be sent.
+func (l *rateLimiter) wait(done <-chan struct{}) bool {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := time.Until(at); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-done:
+			return false
+		}
+	}
+
+	return true
+}
+
+// limiterFor returns the rate limiter of the host, or nil if the requests
+// sent to it are not limited.
+func (c *Crawler) limiterFor(host string, p *domainProfile) *rateLimiter {
+	rate := c.opts.RateLimit
+	if p != nil && p.rateLimit != 0 {
+		rate = p.rateLimit
+	}
+
+	if rate <= 0 {
+		return nil
+	}
+
+	l, _ := c.limiters.LoadOrStore(host, &rateLimiter{interval: time.Duration(float64(time.Second) / rate)})
+
+	return l.(*rateLimiter)
+}
@@ -0,0 +1,249 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_setupProfiles(t *testing.T) {
+	c := testCrawler(t, "http://example.com", CrawlOptions{
+		Profiles: map[string]DomainProfile{
+			"*.example.com":           {UserAgent: "example"},
+			"api.example.com":         {UserAgent: "api"},
+			"https://api.example.com": {UserAgent: "secure api"},
+			"*.api.example.com":       {UserAgent: "api subdomains"},
+		},
+	})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.example.com/orders", "secure api"},
+		{"http://api.example.com/orders", "api"},
+		{"http://v2.api.example.com/orders", "api subdomains"},
+		{"http://www.example.com/", "example"},
+		{"http://api.example.com:8080/", ""},
+		{"http://other.com/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+
+			var got string
+			if p := c.profileFor(u); p != nil {
+				got = p.userAgent
+			}
+
+			if got != tt.want {
+				t.Errorf("profileFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainProfile_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile DomainProfile
+		wantErr bool
+	}{
+		{"valid", DomainProfile{AuthType: AuthBasic, RateLimit: 0.5, Timeout: 1000, Proxy: "socks5://localhost:1080"}, false},
+		{"unknown auth type", DomainProfile{AuthType: 7}, true},
+		{"negative rate limit", DomainProfile{RateLimit: -1}, true},
+		{"invalid proxy", DomainProfile{Proxy: "localhost"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.profile.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_rateLimiter(t *testing.T) {
+	l := &rateLimiter{interval: 30 * time.Millisecond}
+	done := make(chan struct{})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.wait(done) {
+			t.Fatalf("wait() = false")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("3 requests took %v, want at least 60ms", elapsed)
+	}
+
+	close(done)
+	l.wait(done)
+	if l.wait(done) {
+		t.Errorf("wait() = true after done is closed")
+	}
+}
+
+// headerServer records the Authorization, User-Agent and Cookie headers
+// of the requests by path.
+type headerServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	headers map[string]string
+}
+
+func newHeaderServer(pages map[string]string) *headerServer {
+	s := &headerServer{headers: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.headers[r.URL.Path] = fmt.Sprintf("auth=%q agent=%q token=%q cookie=%q", r.Header.Get("Authorization"), r.UserAgent(), r.Header.Get("X-Token"), r.Header.Get("Cookie"))
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+
+	return s
+}
+
+func TestCrawler_profiles(t *testing.T) {
+	external := newHeaderServer(nil)
+	defer external.Close()
+
+	partner := newHeaderServer(map[string]string{"/slow": ""})
+	defer partner.Close()
+
+	site := newHeaderServer(map[string]string{"/": fmt.Sprintf(`<a href="%s/page">external</a> <a href="%s/page">partner</a>`, external.URL, partner.URL)})
+	defer site.Close()
+
+	c := testCrawler(t, site.URL, CrawlOptions{
+		AuthType:  AuthBasic,
+		User:      "user",
+		Pass:      "secret",
+		UserAgent: "brink",
+		Cookies:   map[string]*http.Cookie{"session": {Name: "session", Value: "abc"}},
+		Headers:   map[string]string{"X-Token": "7"},
+		Profiles: map[string]DomainProfile{
+			partner.URL: {AuthType: AuthBasic, User: "partner", Pass: "token", UserAgent: "brink-partner", Headers: map[string]string{"x-token": "42"}},
+		},
+	})
+	c.HandleResultFunc(func(r Result) {})
+
+	waitDone(t, startAsync(t, c))
+
+	tests := []struct {
+		name   string
+		server *headerServer
+		path   string
+		want   string
+	}{
+		{"allowed domain", site, "/", fmt.Sprintf(`auth=%q agent="brink" token="7" cookie="session=abc"`, basicAuth("user", "secret"))},
+		{"external domain", external, "/page", `auth="" agent="brink" token="" cookie=""`},
+		{"profile", partner, "/page", fmt.Sprintf(`auth=%q agent="brink-partner" token="42" cookie=""`, basicAuth("partner", "token"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.mu.Lock()
+			defer tt.server.mu.Unlock()
+
+			if got := tt.server.headers[tt.path]; got != tt.want {
+				t.Errorf("headers of %s = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawler_profileProxyAndTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		proxied []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/slow") {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/slow">slow</a>`)
+	}))
+	defer proxy.Close()
+
+	c := testCrawler(t, "http://intranet.invalid", CrawlOptions{
+		Profiles: map[string]DomainProfile{
+			"intranet.invalid": {Proxy: proxy.URL, Timeout: 50},
+		},
+	})
+
+	var (
+		rmu     sync.Mutex
+		results = make(map[string]Result)
+	)
+	c.HandleResultFunc(func(r Result) {
+		rmu.Lock()
+		results[r.URL] = r
+		rmu.Unlock()
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(proxied) != 2 || proxied[0] != "http://intranet.invalid" && proxied[0] != "http://intranet.invalid/" {
+		t.Errorf("proxied requests = %q, want the entrypoint and /slow", proxied)
+	}
+
+	if r := results["http://intranet.invalid/slow"]; r.Err == nil {
+		t.Errorf("result of /slow = %+v, want a timeout error", r)
+	}
+}
+
+func TestCrawler_cookies(t *testing.T) {
+	c := testCrawler(t, "https://liferay.com", CrawlOptions{
+		Cookies: map[string]*http.Cookie{
+			"session": {Name: "session", Value: "abc"},
+			"partner": {Name: "partner", Value: "42", Domain: ".partner.com"},
+		},
+	})
+
+	c.addCookies("liferay.com", []*http.Cookie{
+		{Name: "visit", Value: "1"},
+		{Name: "foreign", Value: "1", Domain: "example.com"},
+	})
+
+	tests := []struct {
+		name    string
+		host    string
+		allowed bool
+		want    []string
+	}{
+		{"allowed domain", "liferay.com", true, []string{"session", "visit"}},
+		{"external domain", "example.com", false, nil},
+		{"cookie domain", "api.partner.com", false, []string{"partner"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, cookie := range c.cookies(tt.host, tt.allowed) {
+				got = append(got, cookie.Name)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cookies(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,618 @@
+// Package render renders pages in a headless Chromium through the Chrome
+// DevTools protocol, so that the links of pages built by JavaScript can be
+// found as well. A Chrome satisfies the brink.Renderer and
+// brink.RequestRenderer interfaces.
+package render
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	pollInterval   = 100 * time.Millisecond
+
+	// devToolsOrigin is the origin of the DevTools connections, the only
+	// one the launched browser accepts, so that the pages it loads cannot
+	// connect to it.
+	devToolsOrigin = "http://localhost"
+)
+
+// browsers are the names of the executables looked up in the PATH if no
+// path is given.
+var browsers = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// Options configures a Chrome.
+type Options struct {
+	// Path is the path to the Chromium or Chrome executable. If empty, the
+	// usual names are looked up in the PATH.
+	Path string
+
+	// DevToolsURL is the http address of the DevTools endpoint of an
+	// already running browser, e.g. "http://localhost:9222". If set, no
+	// browser is launched.
+	DevToolsURL string
+
+	// WaitFor is a CSS selector to wait for after the page has loaded, e.g.
+	// "#app a". If empty, only the load event is waited for.
+	WaitFor string
+
+	// WaitTime is the time to wait for after the page has loaded and
+	// WaitFor has matched, e.g. for late requests to finish.
+	WaitTime time.Duration
+
+	// Timeout is the most time rendering a page may take. Zero means 30
+	// seconds.
+	Timeout time.Duration
+
+	// Proxy is the address of the proxy the launched browser sends its
+	// requests through, e.g. "http://proxy:3128". It is not used if
+	// DevToolsURL is set.
+	Proxy string
+}
+
+// Chrome renders pages in a headless Chromium. The browser is launched on
+// the first render and stopped by Close. It is safe for concurrent use,
+// every page is rendered in a tab of its own.
+type Chrome struct {
+	opts Options
+
+	mu       sync.Mutex
+	endpoint string
+	cmd      *exec.Cmd
+	dataDir  string
+}
+
+// NewChrome returns a Chrome configured by the options.
+func NewChrome(opts Options) *Chrome {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	return &Chrome{opts: opts, endpoint: strings.TrimSuffix(opts.DevToolsURL, "/")}
+}
+
+// Render loads the url in a new tab and returns the HTML of its DOM once
+// the wait conditions are met.
+func (c *Chrome) Render(_url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, _url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating request: %v", err)
+	}
+
+	return c.RenderRequest(context.Background(), req)
+}
+
+// RenderRequest renders the url of the request like Render, with the
+// cookies and headers of the request. The cookies are set for the url of
+// the request only, and its User-Agent replaces the one of the browser.
+// The rest of its headers, e.g. Authorization, are added to the requests
+// of the tab to the origin of the url only. Rendering is given up when ctx
+// is done.
+func (c *Chrome) RenderRequest(ctx context.Context, req *http.Request) ([]byte, error) {
+	html, err := c.render(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("rendering %s cancelled: %v", req.URL, ctx.Err())
+	}
+
+	return html, err
+}
+
+func (c *Chrome) render(ctx context.Context, req *http.Request) ([]byte, error) {
+	endpoint, err := c.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newTarget(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTarget(endpoint, t.ID)
+
+	conn, err := dial(t.WebSocketDebuggerURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	// Closing the connection makes the pending and later calls fail
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.close()
+		case <-finished:
+		}
+	}()
+
+	deadline := time.Now().Add(c.opts.Timeout)
+	if err := conn.ws.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed setting deadline: %v", err)
+	}
+
+	if err := conn.setRequest(req); err != nil {
+		return nil, err
+	}
+
+	if err := conn.call("Page.enable", nil, nil); err != nil {
+		return nil, err
+	}
+
+	_url := req.URL.String()
+
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := conn.call("Page.navigate", map[string]string{"url": _url}, &nav); err != nil {
+		return nil, err
+	}
+	if nav.ErrorText != "" {
+		return nil, fmt.Errorf("failed navigating to %s: %s", _url, nav.ErrorText)
+	}
+
+	if err := conn.waitEvent("Page.loadEventFired"); err != nil {
+		return nil, err
+	}
+
+	if c.opts.WaitFor != "" {
+		if err := c.waitFor(conn, deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := conn.idle(time.Now().Add(c.opts.WaitTime), deadline); err != nil {
+		return nil, err
+	}
+
+	var html string
+	if err := conn.evaluate("document.documentElement.outerHTML", &html); err != nil {
+		return nil, err
+	}
+
+	return []byte(html), nil
+}
+
+// waitFor polls the page until an element matches the WaitFor selector.
+func (c *Chrome) waitFor(conn *conn, deadline time.Time) error {
+	selector, err := json.Marshal(c.opts.WaitFor)
+	if err != nil {
+		return fmt.Errorf("failed encoding selector: %v", err)
+	}
+	expr := fmt.Sprintf("document.querySelector(%s) !== null", selector)
+
+	for {
+		var found bool
+		if err := conn.evaluate(expr, &found); err != nil {
+			return err
+		}
+
+		if found {
+			return nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return fmt.Errorf("timed out waiting for %q", c.opts.WaitFor)
+		}
+
+		if err := conn.idle(time.Now().Add(pollInterval), deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops the browser if it was launched by the Chrome.
+func (c *Chrome) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cmd == nil {
+		return nil
+	}
+
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	c.cmd = nil
+	c.endpoint = ""
+
+	return os.RemoveAll(c.dataDir)
+}
+
+// start launches the browser unless it is already running or an endpoint
+// was given, and returns the http address of its DevTools endpoint.
+func (c *Chrome) start(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.endpoint != "" {
+		return c.endpoint, nil
+	}
+
+	path, err := c.browserPath()
+	if err != nil {
+		return "", err
+	}
+
+	dataDir, err := ioutil.TempDir("", "brink-chrome")
+	if err != nil {
+		return "", fmt.Errorf("failed creating profile directory: %v", err)
+	}
+
+	args := []string{
+		"--headless",
+		"--disable-gpu",
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--remote-debugging-port=0",
+		"--remote-allow-origins=" + devToolsOrigin,
+		"--user-data-dir=" + dataDir,
+	}
+	if c.opts.Proxy != "" {
+		args = append(args, "--proxy-server="+c.opts.Proxy)
+	}
+
+	cmd := exec.Command(path, append(args, "about:blank")...)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed getting stderr of browser: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dataDir)
+		return "", fmt.Errorf("failed starting browser: %v", err)
+	}
+
+	// The browser prints the address of its DevTools endpoint to stderr,
+	// e.g. "DevTools listening on ws://127.0.0.1:41345/devtools/browser/<id>"
+	endpoint := make(chan string, 1)
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			if i := strings.Index(s.Text(), "ws://"); i != -1 && strings.Contains(s.Text(), "DevTools listening") {
+				endpoint <- s.Text()[i:]
+				break
+			}
+		}
+
+		// Keep draining stderr so that the browser does not block on it
+		ioutil.ReadAll(stderr)
+	}()
+
+	select {
+	case ws := <-endpoint:
+		u, err := url.Parse(ws)
+		if err != nil {
+			cmd.Process.Kill()
+			return "", fmt.Errorf("failed parsing DevTools address %q: %v", ws, err)
+		}
+
+		c.cmd, c.dataDir = cmd, dataDir
+		c.endpoint = "http://" + u.Host
+	case <-time.After(c.opts.Timeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dataDir)
+		return "", fmt.Errorf("browser did not start in %v", c.opts.Timeout)
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dataDir)
+		return "", fmt.Errorf("browser start cancelled: %v", ctx.Err())
+	}
+
+	return c.endpoint, nil
+}
+
+func (c *Chrome) browserPath() (string, error) {
+	if c.opts.Path != "" {
+		return c.opts.Path, nil
+	}
+
+	for _, name := range browsers {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("no Chromium or Chrome found in PATH, tried %s", strings.Join(browsers, ", "))
+}
+
+// target is a tab of the browser.
+type target struct {
+	ID                   string `json:"id"`
+	WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+}
+
+func newTarget(endpoint string) (target, error) {
+	var t target
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/json/new?about:blank", nil)
+	if err != nil {
+		return t, fmt.Errorf("failed creating request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return t, fmt.Errorf("failed opening tab: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return t, fmt.Errorf("failed opening tab: %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return t, fmt.Errorf("failed decoding tab: %v", err)
+	}
+
+	return t, nil
+}
+
+func closeTarget(endpoint, id string) {
+	resp, err := http.Get(endpoint + "/json/close/" + id)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// conn is a DevTools protocol connection to a tab.
+type conn struct {
+	ws     *websocket.Conn
+	nextID int
+	events map[string]bool
+
+	// header is added to the requests paused by the Fetch domain, the ones
+	// to the origin of the rendered url.
+	header http.Header
+}
+
+type message struct {
+	ID     int         `json:"id,omitempty"`
+	Method string      `json:"method,omitempty"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// response is a response to a command, or an event if it has a method.
+type response struct {
+	ID     int             `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func dial(wsURL string) (*conn, error) {
+	ws, err := websocket.Dial(wsURL, "", devToolsOrigin)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting to tab: %v", err)
+	}
+
+	return &conn{ws: ws, events: make(map[string]bool)}, nil
+}
+
+func (c *conn) close() {
+	c.ws.Close()
+}
+
+// setRequest sets up the tab to load pages with the cookies and headers of
+// the request.
+func (c *conn) setRequest(req *http.Request) error {
+	var cookies []map[string]string
+	for _, cookie := range req.Cookies() {
+		cookies = append(cookies, map[string]string{"name": cookie.Name, "value": cookie.Value, "url": req.URL.String()})
+	}
+
+	if len(cookies) != 0 {
+		if err := c.call("Network.setCookies", map[string]interface{}{"cookies": cookies}, nil); err != nil {
+			return err
+		}
+	}
+
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		if err := c.call("Network.setUserAgentOverride", map[string]string{"userAgent": ua}, nil); err != nil {
+			return err
+		}
+	}
+
+	header := req.Header.Clone()
+	header.Del("Cookie")
+	header.Del("User-Agent")
+	if len(header) == 0 {
+		return nil
+	}
+
+	// Only the requests to the origin of the url are paused, so that the
+	// headers are not sent to other sites
+	origin := req.URL.Scheme + "://" + req.URL.Host + "/*"
+	patterns := []map[string]string{{"urlPattern": origin, "requestStage": "Request"}}
+	if err := c.call("Fetch.enable", map[string]interface{}{"patterns": patterns}, nil); err != nil {
+		return err
+	}
+	c.header = header
+
+	return nil
+}
+
+// receive receives the next response. Requests paused by the Fetch domain
+// are continued with the headers added, other events are recorded.
+func (c *conn) receive() (response, error) {
+	var m response
+	if err := websocket.JSON.Receive(c.ws, &m); err != nil {
+		return m, err
+	}
+
+	switch m.Method {
+	case "":
+	case "Fetch.requestPaused":
+		return m, c.continueRequest(m.Params)
+	default:
+		c.events[m.Method] = true
+	}
+
+	return m, nil
+}
+
+// continueRequest continues the paused request with the headers of the
+// conn added. The response to the command is not waited for.
+func (c *conn) continueRequest(params json.RawMessage) error {
+	var paused struct {
+		RequestID string `json:"requestId"`
+		Request   struct {
+			Headers map[string]string `json:"headers"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(params, &paused); err != nil {
+		return fmt.Errorf("failed decoding paused request: %v", err)
+	}
+
+	header := make(http.Header)
+	for name, value := range paused.Request.Headers {
+		header.Set(name, value)
+	}
+	for name, values := range c.header {
+		header[name] = values
+	}
+
+	var entries []map[string]string
+	for name, values := range header {
+		for _, value := range values {
+			entries = append(entries, map[string]string{"name": name, "value": value})
+		}
+	}
+
+	c.nextID++
+	continued := map[string]interface{}{"requestId": paused.RequestID, "headers": entries}
+	if err := websocket.JSON.Send(c.ws, message{ID: c.nextID, Method: "Fetch.continueRequest", Params: continued}); err != nil {
+		return fmt.Errorf("failed continuing request: %v", err)
+	}
+
+	return nil
+}
+
+// call sends the command and decodes its result into result, unless it is
+// nil. Events received in the meantime are recorded.
+func (c *conn) call(method string, params interface{}, result interface{}) error {
+	c.nextID++
+	id := c.nextID
+
+	if err := websocket.JSON.Send(c.ws, message{ID: id, Method: method, Params: params}); err != nil {
+		return fmt.Errorf("failed sending %s: %v", method, err)
+	}
+
+	for {
+		m, err := c.receive()
+		if err != nil {
+			return fmt.Errorf("failed receiving response to %s: %v", method, err)
+		}
+
+		if m.ID != id {
+			continue
+		}
+
+		if m.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, m.Error.Message)
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(m.Result, result); err != nil {
+			return fmt.Errorf("failed decoding response to %s: %v", method, err)
+		}
+
+		return nil
+	}
+}
+
+// waitEvent waits until the event is received, unless it already has been.
+func (c *conn) waitEvent(method string) error {
+	for !c.events[method] {
+		if _, err := c.receive(); err != nil {
+			return fmt.Errorf("failed waiting for %s: %v", method, err)
+		}
+	}
+
+	return nil
+}
+
+// idle keeps receiving events until the given time, so that the requests
+// of the page are continued in the meantime, but not beyond the deadline.
+func (c *conn) idle(until, deadline time.Time) error {
+	if until.After(deadline) {
+		return fmt.Errorf("timed out waiting for the page")
+	}
+
+	if !until.After(time.Now()) {
+		return nil
+	}
+
+	if err := c.ws.SetReadDeadline(until); err != nil {
+		return fmt.Errorf("failed setting deadline: %v", err)
+	}
+
+	for {
+		_, err := c.receive()
+		if err == nil {
+			continue
+		}
+
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			break
+		}
+
+		return fmt.Errorf("failed receiving events: %v", err)
+	}
+
+	if err := c.ws.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("failed setting deadline: %v", err)
+	}
+
+	return nil
+}
+
+// evaluate evaluates the JavaScript expression in the page and decodes its
+// value into result.
+func (c *conn) evaluate(expr string, result interface{}) error {
+	var resp struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+
+	params := map[string]interface{}{"expression": expr, "returnByValue": true}
+	if err := c.call("Runtime.evaluate", params, &resp); err != nil {
+		return err
+	}
+
+	if resp.ExceptionDetails != nil {
+		return fmt.Errorf("failed evaluating %q: %s", expr, resp.ExceptionDetails.Text)
+	}
+
+	if err := json.Unmarshal(resp.Result.Value, result); err != nil {
+		return fmt.Errorf("failed decoding value of %q: %v", expr, err)
+	}
+
+	return nil
+}
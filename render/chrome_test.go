@@ -0,0 +1,263 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// devTools fakes the DevTools endpoint of a browser. Its pages get an
+// element matching "#app a" after the given number of polls. The params
+// of the commands received are recorded by their method.
+type devTools struct {
+	*httptest.Server
+
+	html      string
+	pollsLeft int
+
+	mu     sync.Mutex
+	closed []string
+	calls  map[string]json.RawMessage
+	origin string
+}
+
+func newDevTools(html string, polls int) *devTools {
+	d := &devTools{html: html, pollsLeft: polls, calls: make(map[string]json.RawMessage)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/new", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "use PUT", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ws := "ws" + strings.TrimPrefix(d.URL, "http") + "/devtools/page/tab1"
+		fmt.Fprintf(w, `{"id": "tab1", "webSocketDebuggerUrl": %q}`, ws)
+	})
+	mux.HandleFunc("/json/close/", func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.closed = append(d.closed, strings.TrimPrefix(r.URL.Path, "/json/close/"))
+		d.mu.Unlock()
+	})
+	mux.Handle("/devtools/page/tab1", websocket.Handler(d.serve))
+
+	d.Server = httptest.NewServer(mux)
+	return d
+}
+
+func (d *devTools) serve(ws *websocket.Conn) {
+	var fetching bool
+
+	d.mu.Lock()
+	d.origin = ws.Config().Origin.String()
+	d.mu.Unlock()
+
+	for {
+		var m struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Raw    json.RawMessage `json:"params"`
+			Params struct {
+				URL        string `json:"url"`
+				Expression string `json:"expression"`
+			} `json:"-"`
+		}
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			return
+		}
+		json.Unmarshal(m.Raw, &m.Params)
+
+		d.mu.Lock()
+		d.calls[m.Method] = m.Raw
+		d.mu.Unlock()
+
+		var (
+			result interface{} = struct{}{}
+			loaded bool
+		)
+		switch m.Method {
+		case "Page.navigate":
+			if !strings.HasPrefix(m.Params.URL, "http") {
+				result = map[string]string{"errorText": "net::ERR_INVALID_URL"}
+				break
+			}
+
+			// Events may arrive before the response of the command
+			websocket.JSON.Send(ws, map[string]interface{}{"method": "Page.frameNavigated"})
+			if fetching {
+				paused := map[string]interface{}{"requestId": "req1", "request": map[string]interface{}{"headers": map[string]string{"Accept": "text/html"}}}
+				websocket.JSON.Send(ws, map[string]interface{}{"method": "Fetch.requestPaused", "params": paused})
+			}
+			loaded = true
+		case "Fetch.enable":
+			fetching = true
+		case "Runtime.evaluate":
+			var value interface{} = d.html
+			if strings.Contains(m.Params.Expression, "querySelector") {
+				d.mu.Lock()
+				value = d.pollsLeft <= 0
+				d.pollsLeft--
+				d.mu.Unlock()
+			}
+			result = map[string]interface{}{"result": map[string]interface{}{"value": value}}
+		}
+
+		b, _ := json.Marshal(result)
+		websocket.JSON.Send(ws, map[string]interface{}{"id": m.ID, "result": json.RawMessage(b)})
+
+		if loaded {
+			websocket.JSON.Send(ws, map[string]interface{}{"method": "Page.loadEventFired"})
+		}
+	}
+}
+
+func TestChrome_Render(t *testing.T) {
+	html := `<html><head></head><body><div id="app"><a href="/page">page</a></div></body></html>`
+
+	tests := []struct {
+		name    string
+		url     string
+		waitFor string
+		polls   int
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"load event", "http://example.com", "", 0, 0, false},
+		{"wait for selector", "http://example.com", "#app a", 2, 0, false},
+		{"selector timeout", "http://example.com", "#app a", 100, 300 * time.Millisecond, true},
+		{"navigation error", "javascript:void(0)", "", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDevTools(html, tt.polls)
+			defer d.Close()
+
+			c := NewChrome(Options{DevToolsURL: d.URL + "/", WaitFor: tt.waitFor, Timeout: tt.timeout})
+			defer c.Close()
+
+			got, err := c.Render(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && string(got) != html {
+				t.Errorf("Render() = %s, want %s", got, html)
+			}
+
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if len(d.closed) != 1 || d.closed[0] != "tab1" {
+				t.Errorf("closed tabs = %v, want [tab1]", d.closed)
+			}
+		})
+	}
+}
+
+func TestChrome_browserPath(t *testing.T) {
+	c := NewChrome(Options{Path: "/opt/chromium/chrome"})
+
+	if got, err := c.browserPath(); err != nil || got != "/opt/chromium/chrome" {
+		t.Errorf("browserPath() = %q, %v", got, err)
+	}
+}
+
+func TestChrome_RenderRequest(t *testing.T) {
+	html := `<html><head></head><body></body></html>`
+
+	d := newDevTools(html, 0)
+	defer d.Close()
+
+	c := NewChrome(Options{DevToolsURL: d.URL})
+	defer c.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/app", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.Header.Set("User-Agent", "brink")
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+
+	got, err := c.RenderRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("RenderRequest() error = %v", err)
+	}
+
+	if string(got) != html {
+		t.Errorf("RenderRequest() = %s, want %s", got, html)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.origin != devToolsOrigin {
+		t.Errorf("Origin of the DevTools connection = %q, want %q", d.origin, devToolsOrigin)
+	}
+
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{"Network.setCookies", `{"cookies":[{"name":"session","url":"http://example.com/app","value":"abc"}]}`},
+		{"Network.setUserAgentOverride", `{"userAgent":"brink"}`},
+		{"Fetch.enable", `{"patterns":[{"requestStage":"Request","urlPattern":"http://example.com/*"}]}`},
+		{"Fetch.continueRequest", `{"headers":[{"name":"Accept","value":"text/html"},{"name":"Authorization","value":"Basic dXNlcjpwYXNz"}],"requestId":"req1"}`},
+	}
+	for _, tt := range tests {
+		if got := sortedHeaders(t, d.calls[tt.method]); got != tt.want {
+			t.Errorf("%s params = %s, want %s", tt.method, got, tt.want)
+		}
+	}
+}
+
+// sortedHeaders sorts the headers of the Fetch.continueRequest params,
+// which are sent in no particular order.
+func sortedHeaders(t *testing.T, params json.RawMessage) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal(params, &m); err != nil {
+		t.Fatalf("failed decoding %s: %v", params, err)
+	}
+
+	if headers, ok := m["headers"].([]interface{}); ok {
+		sort.Slice(headers, func(i, j int) bool {
+			return headers[i].(map[string]interface{})["name"].(string) < headers[j].(map[string]interface{})["name"].(string)
+		})
+	}
+
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+func TestChrome_RenderRequest_cancel(t *testing.T) {
+	d := newDevTools("<html></html>", 1000)
+	defer d.Close()
+
+	c := NewChrome(Options{DevToolsURL: d.URL, WaitFor: "#app a", Timeout: 10 * time.Second})
+	defer c.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := c.RenderRequest(ctx, req); err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("RenderRequest() error = %v, want cancelled", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RenderRequest() returned after %v, want right after the cancel", elapsed)
+	}
+}
@@ -0,0 +1,181 @@
+package brink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/djavorszky/brink/render"
+)
+
+// Renderer renders pages, e.g. by running their JavaScript in a browser,
+// and returns the resulting HTML. It is called concurrently by the workers.
+// Unless it is a RequestRenderer as well, it loads the page without the
+// cookies and headers of the crawler. The Chrome of the render package is
+// a Renderer.
+type Renderer interface {
+	Render(url string) ([]byte, error)
+}
+
+// RequestRenderer is a Renderer which loads the page with the cookies and
+// headers the crawler requested it with, e.g. its credentials, and gives
+// up when ctx is done, which it is when the crawler is stopped. The Chrome
+// of the render package is a RequestRenderer.
+type RequestRenderer interface {
+	Renderer
+	RenderRequest(ctx context.Context, req *http.Request) ([]byte, error)
+}
+
+// RendererFunc is an adapter to use a function as a Renderer, e.g. a fake
+// one in tests.
+type RendererFunc func(url string) ([]byte, error)
+
+// Render calls f(url).
+func (f RendererFunc) Render(url string) ([]byte, error) {
+	return f(url)
+}
+
+// RenderOptions configures the rendering of HTML pages in a headless
+// browser before their links are extracted.
+type RenderOptions struct {
+	// Enabled turns on rendering through a headless Chromium.
+	Enabled bool `toml:"enabled" yaml:"enabled" json:"enabled"`
+
+	// Domains lists the domains whose pages are rendered, in the same form as the allowed
+	// domains. If empty, all pages are rendered.
+	Domains []string `toml:"domains" yaml:"domains" json:"domains"`
+
+	// ChromePath is the path to the Chromium or Chrome executable. If empty, the usual names
+	// are looked up in the PATH.
+	ChromePath string `toml:"chrome-path" yaml:"chrome-path" json:"chrome-path"`
+
+	// DevToolsURL is the address of the DevTools endpoint of an already running browser, e.g.
+	// "http://localhost:9222". If set, no browser is launched. A launched browser sends its
+	// requests through the Proxy of the CrawlOptions, if set.
+	DevToolsURL string `toml:"devtools-url" yaml:"devtools-url" json:"devtools-url"`
+
+	// WaitFor is a CSS selector to wait for after the page has loaded, e.g. "#app a".
+	WaitFor string `toml:"wait-for" yaml:"wait-for" json:"wait-for"`
+
+	// WaitTime is the time in milliseconds to wait for after the page has loaded.
+	WaitTime int `toml:"wait-time" yaml:"wait-time" json:"wait-time"`
+
+	// Timeout is the time in milliseconds rendering a page may take. Setting it to 0 will use
+	// the default value of 30000 milliseconds.
+	Timeout int `toml:"timeout" yaml:"timeout" json:"timeout"`
+}
+
+func (r RenderOptions) chrome(proxy string) *render.Chrome {
+	return render.NewChrome(render.Options{
+		Path:        r.ChromePath,
+		DevToolsURL: r.DevToolsURL,
+		WaitFor:     r.WaitFor,
+		WaitTime:    time.Duration(r.WaitTime) * time.Millisecond,
+		Timeout:     time.Duration(r.Timeout) * time.Millisecond,
+		Proxy:       proxy,
+	})
+}
+
+// SetRenderer sets the renderer the HTML pages are passed through before
+// their links are extracted, replacing the one configured by the
+// RenderOptions, if any. Only the pages of the domains listed in the
+// RenderOptions are rendered, or all of them if none are listed. Passing
+// nil turns rendering off. It should be called before Start.
+func (c *Crawler) SetRenderer(r Renderer) {
+	c.closeRenderer()
+	c.renderer = r
+}
+
+// closeRenderer stops the renderer if the crawler created it.
+func (c *Crawler) closeRenderer() {
+	if c.ownRenderer == nil {
+		return
+	}
+
+	if err := c.ownRenderer.Close(); err != nil {
+		c.logger.Warn("failed stopping browser", "error", err)
+	}
+	c.ownRenderer = nil
+}
+
+func (r RenderOptions) validate() error {
+	for _, domain := range r.Domains {
+		if _, err := parseDomainRule(domain); err != nil {
+			return fmt.Errorf("failed parsing domain %q: %v", domain, err)
+		}
+	}
+
+	if r.Timeout < 0 || r.WaitTime < 0 {
+		return fmt.Errorf("timeout and wait time must not be negative")
+	}
+
+	return nil
+}
+
+func setupRendering(c *Crawler, opts RenderOptions) error {
+	for _, domain := range opts.Domains {
+		rule, err := parseDomainRule(domain)
+		if err != nil {
+			return fmt.Errorf("failed parsing domain %q: %v", domain, err)
+		}
+
+		c.renderDomains = append(c.renderDomains, rule)
+	}
+
+	if opts.Enabled {
+		chrome := opts.chrome(c.opts.Proxy)
+		c.renderer = chrome
+		c.ownRenderer = chrome
+	}
+
+	return nil
+}
+
+// render renders the page of the request. A RequestRenderer gets the
+// request itself, and its rendering is cancelled when the crawler is
+// stopped.
+func (c *Crawler) render(req *http.Request) ([]byte, error) {
+	r, ok := c.renderer.(RequestRenderer)
+	if !ok {
+		return c.renderer.Render(req.URL.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return r.RenderRequest(ctx, req)
+}
+
+// shouldRender reports whether the page is to be rendered.
+func (c *Crawler) shouldRender(_url string, status int, contentType string, body []byte) bool {
+	if c.renderer == nil || status != 200 || !isHTML(contentType, body) {
+		return false
+	}
+
+	if len(c.renderDomains) == 0 {
+		return true
+	}
+
+	u, err := url.ParseRequestURI(_url)
+	if err != nil {
+		return false
+	}
+
+	for _, rule := range c.renderDomains {
+		if rule.matches(u) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package brink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var renderPages = map[string]string{
+	"/":       `<a href="/static">static</a><div id="app"></div><script src="/app.js"></script>`,
+	"/static": `static page`,
+}
+
+func TestCrawler_SetRenderer(t *testing.T) {
+	tests := []struct {
+		name    string
+		domains func(tsURL string) []string
+		fail    bool
+		visited []string
+	}{
+		{"all domains", func(string) []string { return nil }, false, []string{"/static", "/rendered"}},
+		{"listed domain", func(tsURL string) []string { return []string{tsURL} }, false, []string{"/static", "/rendered"}},
+		{"other domain", func(string) []string { return []string{"example.com"} }, false, []string{"/static"}},
+		{"failed rendering", func(string) []string { return nil }, true, []string{"/static"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := testSite(renderPages)
+			defer ts.Close()
+
+			c := testCrawler(t, ts.URL, CrawlOptions{Rendering: RenderOptions{Domains: tt.domains(ts.URL)}})
+
+			var (
+				mu       sync.Mutex
+				rendered []string
+				visited  = make(map[string]bool)
+			)
+			c.SetRenderer(RendererFunc(func(url string) ([]byte, error) {
+				mu.Lock()
+				rendered = append(rendered, url)
+				mu.Unlock()
+
+				if tt.fail {
+					return nil, fmt.Errorf("browser crashed")
+				}
+
+				body := strings.Replace(renderPages["/"], `<div id="app"></div>`, `<div id="app"><a href="/rendered">rendered</a></div>`, 1)
+				return []byte(body), nil
+			}))
+			c.HandleResultFunc(func(r Result) {
+				mu.Lock()
+				visited[strings.TrimPrefix(r.URL, ts.URL)] = true
+				mu.Unlock()
+			})
+
+			waitDone(t, startAsync(t, c))
+
+			if len(visited) != len(tt.visited)+1 {
+				t.Errorf("visited %v, want the entrypoint and %v", visited, tt.visited)
+			}
+
+			for _, path := range tt.visited {
+				if !visited[path] {
+					t.Errorf("%s was not visited", path)
+				}
+			}
+
+			// /rendered is a 404 and /static has no links, but both are HTML
+			for _, url := range rendered {
+				if strings.HasSuffix(url, "/rendered") {
+					t.Errorf("rendered page with status 404: %s", url)
+				}
+			}
+		})
+	}
+}
+
+// requestRenderer is a RequestRenderer which waits until ctx is done.
+type requestRenderer struct {
+	started chan *http.Request
+}
+
+func (r requestRenderer) Render(url string) ([]byte, error) {
+	return nil, fmt.Errorf("Render called instead of RenderRequest")
+}
+
+func (r requestRenderer) RenderRequest(ctx context.Context, req *http.Request) ([]byte, error) {
+	select {
+	case r.started <- req:
+	default:
+	}
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCrawler_RequestRenderer(t *testing.T) {
+	ts := testSite(renderPages)
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{UserAgent: "brink-test"})
+	c.HandleResultFunc(func(r Result) {})
+
+	started := make(chan *http.Request, 1)
+	c.SetRenderer(requestRenderer{started})
+
+	done := startAsync(t, c)
+
+	select {
+	case req := <-started:
+		if got := req.Header.Get("User-Agent"); got != "brink-test" {
+			t.Errorf("User-Agent of the rendered request = %q, want brink-test", got)
+		}
+	case <-done:
+		t.Fatalf("crawl finished without calling RenderRequest")
+	}
+
+	c.Stop()
+	waitDone(t, done)
+}
@@ -0,0 +1,485 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/djavorszky/brink"
+)
+
+// Report formats
+const (
+	FormatJSONLines = "jsonl"
+	FormatCSV       = "csv"
+	FormatJUnit     = "junit"
+	FormatSummary   = "summary"
+)
+
+// Entry is the line of the report belonging to a single URL.
+type Entry struct {
+	URL         string   `json:"url"`
+	Method      string   `json:"method,omitempty"`
+	Status      int      `json:"status"`
+	Referrers   []string `json:"referrers"`
+	Depth       int      `json:"depth"`
+	DurationMs  int64    `json:"duration_ms"`
+	ContentType string   `json:"content_type,omitempty"`
+	Size        int64    `json:"size"`
+	Error       string   `json:"error,omitempty"`
+
+	// Findings holds the problems found on the page by the audit rules.
+	Findings []brink.Finding `json:"findings,omitempty"`
+
+	// Forms holds the forms first found on the page.
+	Forms []brink.Form `json:"forms,omitempty"`
+}
+
+// method returns the method of the request of the entry.
+func (e Entry) method() string {
+	if e.Method == "" {
+		return http.MethodGet
+	}
+
+	return e.Method
+}
+
+// Broken reports whether the entry represents a broken link, e.g. one
+// which could not be fetched or returned an error status.
+func (e Entry) Broken() bool {
+	return e.Error != "" || e.Status >= http.StatusBadRequest
+}
+
+// Collector collects the results of a crawl. Its Add method can be
+// registered with the crawler's HandleResultFunc.
+type Collector struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	seen    map[string]map[string]bool
+	order   []string
+}
+
+// NewCollector returns an initialized Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		entries: make(map[string]*Entry),
+		seen:    make(map[string]map[string]bool),
+	}
+}
+
+// Add adds the result to the report. Results of already visited requests
+// only add their referrer to the existing entry. Requests are told apart
+// the way the crawler does, by Result.Key, so requests other than GET, e.g.
+// submitted POST forms, get entries of their own for every body.
+func (c *Collector) Add(r brink.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := r.Key()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &Entry{URL: r.URL, Depth: r.Depth, Referrers: []string{}}
+		if r.Method != http.MethodGet {
+			e.Method = r.Method
+		}
+
+		c.entries[key] = e
+		c.seen[key] = make(map[string]bool)
+		c.order = append(c.order, key)
+	}
+
+	if r.Depth < e.Depth {
+		e.Depth = r.Depth
+	}
+
+	if r.LinkedFrom != "" && !c.seen[key][r.LinkedFrom] {
+		c.seen[key][r.LinkedFrom] = true
+		e.Referrers = append(e.Referrers, r.LinkedFrom)
+	}
+
+	if r.Cached {
+		return
+	}
+
+	e.Status = r.Status
+	e.DurationMs = int64(r.Duration / time.Millisecond)
+	e.ContentType = r.ContentType
+	e.Size = r.Size
+
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+}
+
+// AddFindings adds the audit findings to the entries of their URLs.
+// Findings of URLs without an entry are dropped.
+func (c *Collector) AddFindings(findings []brink.Finding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, f := range findings {
+		if e, ok := c.entries[f.URL]; ok {
+			e.Findings = append(e.Findings, f)
+		}
+	}
+}
+
+// AddForms adds the forms to the entries of the pages they were found on.
+// Forms of pages without an entry are dropped.
+func (c *Collector) AddForms(forms []brink.Form) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, f := range forms {
+		if e, ok := c.entries[f.Page]; ok {
+			e.Forms = append(e.Forms, f)
+		}
+	}
+}
+
+// Failed reports whether the entry is broken or has audit findings of error
+// severity.
+func (e Entry) Failed() bool {
+	if e.Broken() {
+		return true
+	}
+
+	for _, f := range e.Findings {
+		if f.Severity == brink.SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Entries returns a copy of the collected entries in the order their URLs
+// were first seen.
+func (c *Collector) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]Entry, 0, len(c.order))
+	for _, u := range c.order {
+		e := *c.entries[u]
+		e.Referrers = append([]string{}, e.Referrers...)
+		e.Findings = append([]brink.Finding(nil), e.Findings...)
+		e.Forms = append([]brink.Form(nil), e.Forms...)
+
+		entries = append(entries, e)
+	}
+
+	return entries
+}
+
+// ValidFormat reports whether the format is one of the supported ones.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatJSONLines, FormatCSV, FormatJUnit, FormatSummary:
+		return true
+	}
+
+	return false
+}
+
+// Write writes the entries to w in the specified format.
+func Write(w io.Writer, format string, entries []Entry) error {
+	switch format {
+	case FormatJSONLines:
+		return WriteJSONLines(w, entries)
+	case FormatCSV:
+		return WriteCSV(w, entries)
+	case FormatJUnit:
+		return WriteJUnit(w, entries)
+	case FormatSummary:
+		return WriteSummary(w, entries)
+	}
+
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// WriteJSONLines writes the entries as JSON objects, one per line.
+func WriteJSONLines(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed encoding entry of %q: %v", e.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadJSONLines reads the entries of a report written by WriteJSONLines.
+func ReadJSONLines(r io.Reader) ([]Entry, error) {
+	dec := json.NewDecoder(r)
+
+	var entries []Entry
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed decoding entry %d: %v", len(entries)+1, err)
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+// WriteSummary writes a plain text summary of the entries: the number of
+// checked URLs, then the broken links with their referrers and the audit
+// findings.
+func WriteSummary(w io.Writer, entries []Entry) error {
+	var broken, withFindings []Entry
+	for _, e := range entries {
+		if e.Broken() {
+			broken = append(broken, e)
+		}
+
+		if len(e.Findings) != 0 {
+			withFindings = append(withFindings, e)
+		}
+	}
+
+	ew := &errWriter{w: w}
+
+	ew.printf("Checked %d URLs: %d broken, %d with audit findings\n", len(entries), len(broken), len(withFindings))
+
+	if len(broken) != 0 {
+		ew.printf("\nBroken links:\n")
+	}
+
+	for _, e := range broken {
+		problem := e.Error
+		if problem == "" {
+			problem = fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+		}
+
+		ew.printf("  %s: %s\n", e.URL, problem)
+		for _, ref := range e.Referrers {
+			ew.printf("      linked from %s\n", ref)
+		}
+	}
+
+	if len(withFindings) != 0 {
+		ew.printf("\nAudit findings:\n")
+	}
+
+	for _, e := range withFindings {
+		ew.printf("  %s:\n", e.URL)
+		for _, f := range e.Findings {
+			ew.printf("      %s\n", findingLine(f))
+		}
+	}
+
+	return ew.err
+}
+
+// errWriter remembers the first error of the writes and skips the
+// following ones.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+// WriteCSV writes the entries as CSV with a header line. Referrers are
+// separated by spaces, findings by newlines.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"url", "method", "status", "referrers", "depth", "duration_ms", "content_type", "size", "error", "findings"}); err != nil {
+		return fmt.Errorf("failed writing header: %v", err)
+	}
+
+	for _, e := range entries {
+		record := []string{
+			e.URL,
+			e.method(),
+			strconv.Itoa(e.Status),
+			strings.Join(e.Referrers, " "),
+			strconv.Itoa(e.Depth),
+			strconv.FormatInt(e.DurationMs, 10),
+			e.ContentType,
+			strconv.FormatInt(e.Size, 10),
+			e.Error,
+			findingLines(e.Findings),
+		}
+
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed writing entry of %q: %v", e.URL, err)
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the entries as a JUnit XML report. Every request is a
+// test case with its method as a property, grouped into test suites by
+// host, and broken links are reported as failures listing the pages
+// linking to them. Pages with audit findings of error severity are
+// failures as well, listing all of their findings.
+func WriteJUnit(w io.Writer, entries []Entry) error {
+	suites := make(map[string]*junitSuite)
+	durations := make(map[string]int64)
+	var names []string
+
+	for _, e := range entries {
+		host := hostOf(e.URL)
+
+		s, ok := suites[host]
+		if !ok {
+			s = &junitSuite{Name: host}
+			suites[host] = s
+			names = append(names, host)
+		}
+
+		tc := junitCase{
+			Name:       e.URL,
+			ClassName:  host,
+			Time:       seconds(e.DurationMs),
+			Properties: []junitProperty{{Name: "method", Value: e.method()}},
+		}
+
+		switch {
+		case e.Broken():
+			tc.Failure = &junitFailure{
+				Message: failureMessage(e),
+				Type:    "BrokenLink",
+				Text:    fmt.Sprintf("Linked from:\n%s", strings.Join(e.Referrers, "\n")),
+			}
+			s.Failures++
+		case e.Failed():
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d audit findings", len(e.Findings)),
+				Type:    "AuditFinding",
+				Text:    findingLines(e.Findings),
+			}
+			s.Failures++
+		}
+
+		s.Tests++
+		s.Cases = append(s.Cases, tc)
+		durations[host] += e.DurationMs
+	}
+
+	sort.Strings(names)
+
+	var result junitSuites
+	for _, name := range names {
+		s := suites[name]
+		s.Time = seconds(durations[name])
+
+		result.Suites = append(result.Suites, *s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed writing xml header: %v", err)
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(result); err != nil {
+		return fmt.Errorf("failed encoding junit report: %v", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func findingLines(findings []brink.Finding) string {
+	lines := make([]string, len(findings))
+	for i, f := range findings {
+		lines[i] = findingLine(f)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func findingLine(f brink.Finding) string {
+	line := fmt.Sprintf("%s %s: %s", f.Severity, f.Rule, f.Message)
+	if f.LinkedFrom != "" {
+		line += fmt.Sprintf(" (linked from %s)", f.LinkedFrom)
+	}
+
+	return line
+}
+
+func failureMessage(e Entry) string {
+	if e.Error != "" {
+		return e.Error
+	}
+
+	return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+}
+
+func hostOf(_url string) string {
+	s := _url
+	if i := strings.Index(s, "://"); i != -1 {
+		s = s[i+3:]
+	}
+
+	if i := strings.IndexAny(s, "/?#"); i != -1 {
+		s = s[:i]
+	}
+
+	return s
+}
+
+func seconds(ms int64) string {
+	return strconv.FormatFloat(float64(ms)/1000, 'f', 3, 64)
+}
@@ -0,0 +1,233 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/djavorszky/brink"
+)
+
+func testEntries() []Entry {
+	c := NewCollector()
+
+	c.Add(brink.Result{URL: "https://liferay.com", LinkedFrom: "start", Status: 200, ContentType: "text/html", Size: 1024, Duration: 120 * time.Millisecond})
+	c.Add(brink.Result{URL: "https://liferay.com/missing", LinkedFrom: "https://liferay.com", Depth: 1, Status: 404, ContentType: "text/html", Size: 10, Duration: 30 * time.Millisecond})
+	c.Add(brink.Result{URL: "https://liferay.com/missing", LinkedFrom: "https://liferay.com/other", Depth: 2, Status: 404, Cached: true})
+	c.Add(brink.Result{URL: "https://liferay.com/missing", LinkedFrom: "https://liferay.com", Depth: 1, Status: 404, Cached: true})
+	c.Add(brink.Result{URL: "https://down.example.com", LinkedFrom: "https://liferay.com", Depth: 1, Err: fmt.Errorf("get failed: connection refused")})
+
+	return c.Entries()
+}
+
+func TestCollector(t *testing.T) {
+	want := []Entry{
+		{URL: "https://liferay.com", Status: 200, Referrers: []string{"start"}, DurationMs: 120, ContentType: "text/html", Size: 1024},
+		{URL: "https://liferay.com/missing", Status: 404, Referrers: []string{"https://liferay.com", "https://liferay.com/other"}, Depth: 1, DurationMs: 30, ContentType: "text/html", Size: 10},
+		{URL: "https://down.example.com", Referrers: []string{"https://liferay.com"}, Depth: 1, Error: "get failed: connection refused"},
+	}
+
+	if got := testEntries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Entries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSONLines, testEntries()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `{"url":"https://liferay.com","status":200,"referrers":["start"],"depth":0,"duration_ms":120,"content_type":"text/html","size":1024}
+{"url":"https://liferay.com/missing","status":404,"referrers":["https://liferay.com","https://liferay.com/other"],"depth":1,"duration_ms":30,"content_type":"text/html","size":10}
+{"url":"https://down.example.com","status":0,"referrers":["https://liferay.com"],"depth":1,"duration_ms":0,"size":0,"error":"get failed: connection refused"}
+`
+	if buf.String() != want {
+		t.Errorf("Write() = %s, want %s", buf.String(), want)
+	}
+}
+
+func TestReadJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSONLines, testEntries()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got, err := ReadJSONLines(&buf)
+	if err != nil {
+		t.Fatalf("ReadJSONLines() error = %v", err)
+	}
+
+	if want := testEntries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadJSONLines() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ReadJSONLines(strings.NewReader("{\"url\":\"https://liferay.com\"}\nnot json\n")); err == nil {
+		t.Errorf("ReadJSONLines() expected error for invalid line")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	entries := testEntries()
+	entries[0].Findings = []brink.Finding{
+		{URL: "https://liferay.com", Rule: brink.RuleMissingTitle, Severity: brink.SeverityError, Message: "page has no title"},
+		{URL: "https://liferay.com", Rule: "missing-article", Severity: brink.SeverityWarning, Message: "article does not exist", LinkedFrom: "start"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatSummary, entries); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `Checked 3 URLs: 2 broken, 1 with audit findings
+
+Broken links:
+  https://liferay.com/missing: 404 Not Found
+      linked from https://liferay.com
+      linked from https://liferay.com/other
+  https://down.example.com: get failed: connection refused
+      linked from https://liferay.com
+
+Audit findings:
+  https://liferay.com:
+      error missing-title: page has no title
+      warning missing-article: article does not exist (linked from start)
+`
+	if buf.String() != want {
+		t.Errorf("Write() = %s, want %s", buf.String(), want)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, testEntries()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `url,method,status,referrers,depth,duration_ms,content_type,size,error,findings
+https://liferay.com,GET,200,start,0,120,text/html,1024,,
+https://liferay.com/missing,GET,404,https://liferay.com https://liferay.com/other,1,30,text/html,10,,
+https://down.example.com,GET,0,https://liferay.com,1,0,,0,get failed: connection refused,
+`
+	if buf.String() != want {
+		t.Errorf("Write() = %s, want %s", buf.String(), want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJUnit, testEntries()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		`<testsuite name="down.example.com" tests="1" failures="1" time="0.000">`,
+		`<testsuite name="liferay.com" tests="2" failures="1" time="0.150">`,
+		`<testcase name="https://liferay.com" classname="liferay.com" time="0.120">`,
+		`<property name="method" value="GET"></property>`,
+		`<failure message="404 Not Found" type="BrokenLink">Linked from:&#xA;https://liferay.com&#xA;https://liferay.com/other</failure>`,
+		`<failure message="get failed: connection refused" type="BrokenLink">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Write() output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestCollector_AddFindings(t *testing.T) {
+	c := NewCollector()
+	c.Add(brink.Result{URL: "https://liferay.com", LinkedFrom: "start", Status: 200})
+	c.Add(brink.Result{URL: "https://liferay.com/a", LinkedFrom: "https://liferay.com", Status: 200})
+
+	c.AddFindings([]brink.Finding{
+		{URL: "https://liferay.com", Rule: brink.RuleMissingMetaDescription, Severity: brink.SeverityWarning, Message: "page has no meta description"},
+		{URL: "https://liferay.com/a", Rule: brink.RuleMixedContent, Severity: brink.SeverityError, Message: "img loads insecure resource http://liferay.com/a.png"},
+		{URL: "https://liferay.com/unknown", Rule: brink.RuleMissingTitle, Severity: brink.SeverityError, Message: "page has no title"},
+	})
+
+	entries := c.Entries()
+	if len(entries[0].Findings) != 1 || entries[0].Failed() {
+		t.Errorf("unexpected entry with warning: %+v", entries[0])
+	}
+
+	if len(entries[1].Findings) != 1 || !entries[1].Failed() {
+		t.Errorf("unexpected entry with error: %+v", entries[1])
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJUnit, entries); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `<failure message="1 audit findings" type="AuditFinding">error mixed-content: img loads insecure resource http://liferay.com/a.png</failure>`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Write() output does not contain %q:\n%s", want, buf.String())
+	}
+}
+
+func TestCollector_AddForms(t *testing.T) {
+	c := NewCollector()
+	c.Add(brink.Result{URL: "https://liferay.com", LinkedFrom: "start", Status: 200})
+	c.Add(brink.Result{URL: "https://liferay.com/cart", Method: "POST", LinkedFrom: "https://liferay.com", Depth: 1, Status: 200})
+	c.Add(brink.Result{URL: "https://liferay.com/cart", Method: "GET", LinkedFrom: "https://liferay.com", Depth: 1, Status: 405})
+
+	c.AddForms([]brink.Form{
+		{Page: "https://liferay.com", Action: "https://liferay.com/cart", Method: "POST"},
+		{Page: "https://liferay.com/unknown", Action: "https://liferay.com/search", Method: "GET"},
+	})
+
+	entries := c.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Entries() = %+v, want 3 entries", entries)
+	}
+
+	if len(entries[0].Forms) != 1 || entries[0].Forms[0].Action != "https://liferay.com/cart" {
+		t.Errorf("unexpected forms of %s: %+v", entries[0].URL, entries[0].Forms)
+	}
+
+	if entries[1].Method != "POST" || entries[1].Status != 200 || entries[2].Method != "" || entries[2].Status != 405 {
+		t.Errorf("unexpected entries of the POST and GET requests: %+v, %+v", entries[1], entries[2])
+	}
+}
+
+func TestCollector_requestBodies(t *testing.T) {
+	c := NewCollector()
+	c.Add(brink.Result{URL: "https://liferay.com/search", Method: "POST", Body: "q=a", LinkedFrom: "seed", Status: 200})
+	c.Add(brink.Result{URL: "https://liferay.com/search", Method: "POST", Body: "q=b", LinkedFrom: "seed", Status: 500})
+	c.Add(brink.Result{URL: "https://liferay.com/search", Method: "POST", Body: "q=a", LinkedFrom: "https://liferay.com", Status: 200, Cached: true})
+	c.Add(brink.Result{URL: "https://liferay.com/search", LinkedFrom: "https://liferay.com", Status: 405})
+
+	entries := c.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Entries() = %+v, want 3 entries", entries)
+	}
+
+	if entries[0].Status != 200 || len(entries[0].Referrers) != 2 || entries[1].Status != 500 {
+		t.Errorf("unexpected entries of the POST requests: %+v, %+v", entries[0], entries[1])
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, entries); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"https://liferay.com/search,POST,200,",
+		"https://liferay.com/search,POST,500,",
+		"https://liferay.com/search,GET,405,",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Write() output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWrite_unknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "xlsx", nil); err == nil {
+		t.Errorf("Write() expected error for unknown format")
+	}
+}
@@ -0,0 +1,180 @@
+package brink
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Seed is a request the crawl is started with in addition to the root
+// domain, e.g. a POST request to the listing endpoint of an API.
+type Seed struct {
+	URL string `toml:"url" yaml:"url" json:"url"`
+
+	// Method is the http method of the request. Leaving it empty will use GET.
+	Method string `toml:"method" yaml:"method,omitempty" json:"method,omitempty"`
+
+	// Body is sent along with the request. Its Content-Type defaults to
+	// application/json if it is valid JSON, and to application/x-www-form-urlencoded
+	// otherwise, unless set in the Headers.
+	Body string `toml:"body" yaml:"body,omitempty" json:"body,omitempty"`
+
+	// Headers are added to the request, replacing the ones set for all requests.
+	Headers map[string]string `toml:"headers" yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+func (s Seed) validate() error {
+	if _, err := url.ParseRequestURI(s.URL); err != nil {
+		return fmt.Errorf("invalid url %q: %v", s.URL, err)
+	}
+
+	if strings.ContainsAny(s.Method, " \t\r\n") {
+		return fmt.Errorf("invalid method %q", s.Method)
+	}
+
+	return nil
+}
+
+func (s Seed) link() Link {
+	l := Link{
+		LinkedFrom: seedReferrer,
+		Href:       s.URL,
+		Method:     strings.ToUpper(s.Method),
+		Body:       s.Body,
+	}
+
+	if len(s.Headers) != 0 {
+		l.Header = make(http.Header, len(s.Headers))
+		for k, v := range s.Headers {
+			l.Header.Set(k, v)
+		}
+	}
+
+	return l
+}
+
+// Request is a request about to be sent by the crawler, passed to the
+// request middleware.
+type Request struct {
+	Method string
+	URL    string
+	Body   string
+
+	// Header holds the headers of this request only, which replace the ones
+	// set for all requests.
+	Header http.Header
+
+	// Link is the link the request is sent for.
+	Link Link
+
+	// CheckOnly makes the crawler only check the page: it is neither audited
+	// nor are its links followed, until it is reached by a request without
+	// CheckOnly.
+	CheckOnly bool
+}
+
+// key identifies the request among the visited ones.
+func (r *Request) key() string {
+	return visitKey(r.Method, r.URL, r.Body)
+}
+
+// RequestMiddleware can change the requests before they are sent, e.g. to
+// add headers or to turn them into POST requests. Returning an error skips
+// the request.
+type RequestMiddleware func(r *Request) error
+
+// UseRequestMiddleware adds the middleware to the ones the requests are
+// passed through, in the order they were added. It should be called before
+// Start.
+func (c *Crawler) UseRequestMiddleware(m ...RequestMiddleware) {
+	c.middleware = append(c.middleware, m...)
+}
+
+// newRequest creates the request for the link pointing to the normalized
+// url and passes it through the middleware.
+func (c *Crawler) newRequest(_url string, l Link) (*Request, error) {
+	r := Request{
+		Method: l.Method,
+		URL:    _url,
+		Body:   l.Body,
+		Header: l.Header.Clone(),
+		Link:   l,
+	}
+
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	for _, m := range c.middleware {
+		if err := m(&r); err != nil {
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+// visitKey identifies a request among the visited ones. GET requests
+// without a body are identified by their url alone, the others by their
+// method, url and the hash of their body.
+func visitKey(method, url, body string) string {
+	if body == "" {
+		if method == http.MethodGet {
+			return url
+		}
+
+		return method + " " + url
+	}
+
+	sum := sha256.Sum256([]byte(body))
+
+	return method + " " + url + " " + hex.EncodeToString(sum[:16])
+}
+
+// contentTypeOf returns the Content-Type to send the body with if none is
+// set explicitly.
+func contentTypeOf(body string) string {
+	if json.Valid([]byte(body)) {
+		return "application/json"
+	}
+
+	return formURLEncoded
+}
+
+// AddSeedRequests adds the requests to the ones waiting to be sent. It can
+// be called while the crawler is running, and fails the same way as
+// AddSeeds: if the url buffer fills up, the error is a BufferFull telling
+// how many of the seeds were added.
+func (c *Crawler) AddSeedRequests(seeds ...Seed) error {
+	for _, s := range seeds {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("invalid seed: %v", err)
+		}
+
+		if _, err := c.normalizeURL(s.URL); err != nil {
+			return fmt.Errorf("invalid seed %q: %v", s.URL, err)
+		}
+	}
+
+	for i, s := range seeds {
+		if c.isStopping() {
+			return fmt.Errorf("crawler is stopped")
+		}
+
+		select {
+		case c.urls <- s.link():
+		default:
+			return BufferFull{Added: i, URL: s.URL}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package brink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_visitKey(t *testing.T) {
+	const u = "http://example.com/a"
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   string
+	}{
+		{"get", http.MethodGet, "", u},
+		{"head", http.MethodHead, "", "HEAD " + u},
+		{"post", http.MethodPost, `{"page":1}`, "POST " + u + " 70fb0185588d2e765454a7927f2792ae"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visitKey(tt.method, u, tt.body); got != tt.want {
+				t.Errorf("visitKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if visitKey(http.MethodPost, u, `{"page":1}`) == visitKey(http.MethodPost, u, `{"page":2}`) {
+		t.Errorf("visitKey() is the same for different bodies")
+	}
+}
+
+func Test_contentTypeOf(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"query": "shoes"}`, "application/json"},
+		{`[1, 2]`, "application/json"},
+		{`q=shoes&page=2`, formURLEncoded},
+	}
+	for _, tt := range tests {
+		if got := contentTypeOf(tt.body); got != tt.want {
+			t.Errorf("contentTypeOf(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestCrawler_seeds(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		req := fmt.Sprintf("%s %s %s type=%s key=%s trace=%s", r.Method, r.URL.RequestURI(), body,
+			r.Header.Get("Content-Type"), r.Header.Get("X-Api-Key"), r.Header.Get("X-Trace"))
+
+		mu.Lock()
+		requested = append(requested, req)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/skip">skip</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{
+		Headers: map[string]string{"X-Api-Key": "global"},
+		Seeds: []Seed{
+			{URL: ts.URL + "/api/list", Method: "post", Body: `{"page":1}`, Headers: map[string]string{"x-api-key": "secret"}},
+			{URL: ts.URL + "/api/list", Method: "POST", Body: `{"page":2}`},
+			{URL: ts.URL + "/api/list", Method: "POST", Body: `{"page":1}`},
+			{URL: ts.URL + "/api/search", Method: "POST", Body: "q=shoes"},
+		},
+	})
+	c.UseRequestMiddleware(func(r *Request) error {
+		if strings.HasSuffix(r.URL, "/skip") {
+			return fmt.Errorf("skipped")
+		}
+
+		r.Header.Set("X-Trace", r.Method)
+		return nil
+	})
+	c.HandleResultFunc(func(r Result) {})
+
+	waitDone(t, startAsync(t, c))
+
+	want := []string{
+		"GET /  type= key=global trace=GET",
+		`POST /api/list {"page":1} type=application/json key=secret trace=POST`,
+		`POST /api/list {"page":2} type=application/json key=global trace=POST`,
+		"POST /api/search q=shoes type=application/x-www-form-urlencoded key=global trace=POST",
+	}
+
+	sort.Strings(requested)
+	if !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+}
+
+func TestCrawler_AddSeedRequests(t *testing.T) {
+	c := testCrawler(t, "http://example.com", CrawlOptions{URLBufferSize: 1})
+
+	if err := c.AddSeedRequests(Seed{URL: "/relative", Method: "POST"}); err == nil {
+		t.Errorf("AddSeedRequests() accepted a relative url")
+	}
+
+	if err := c.AddSeedRequests(Seed{URL: "http://example.com/a", Method: "NOT VALID"}); err == nil {
+		t.Errorf("AddSeedRequests() accepted an invalid method")
+	}
+
+	if err := c.AddSeedRequests(Seed{URL: "http://example.com/a", Method: "POST", Body: "{}"}); err != nil {
+		t.Errorf("AddSeedRequests() error = %v", err)
+	}
+
+	if l := <-c.urls; l.Method != http.MethodPost || l.Body != "{}" || l.LinkedFrom != seedReferrer {
+		t.Errorf("unexpected seed link: %+v", l)
+	}
+
+	err := c.AddSeedRequests(Seed{URL: "http://example.com/b"}, Seed{URL: "http://example.com/c"})
+	if want := (BufferFull{Added: 1, URL: "http://example.com/c"}); err != want {
+		t.Errorf("AddSeedRequests() with a full buffer error = %v, want %v", err, want)
+	}
+}
+
+func TestCrawler_checkOnly(t *testing.T) {
+	tests := []struct {
+		name         string
+		q            string
+		wantChild    int
+		wantFindings int
+	}{
+		{"checked only", `q`, 0, 0},
+		{"reached later", `<a href="/p">p again</a>`, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := testSite(map[string]string{
+				"/":        `<a href="/p">p</a> <a href="/q">q</a>`,
+				"/q":       tt.q,
+				"/p":       `<a href="/p/child">child</a> marker`,
+				"/p/child": `child`,
+			})
+			defer ts.Close()
+
+			// A single worker visits /p through the check-only request
+			// before finding the link on /q.
+			c := testCrawler(t, ts.URL, CrawlOptions{
+				WorkerCount: 1,
+				Audit:       AuditOptions{ContentRules: []ContentRule{{Name: "marker", Contains: "marker"}}},
+			})
+			c.UseRequestMiddleware(func(r *Request) error {
+				r.CheckOnly = strings.HasSuffix(r.URL, "/p") && r.Link.LinkedFrom == ts.URL
+				return nil
+			})
+
+			var (
+				mu      sync.Mutex
+				fetched = make(map[string]int)
+			)
+			c.HandleResultFunc(func(r Result) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if !r.Cached {
+					fetched[strings.TrimPrefix(r.URL, ts.URL)]++
+				}
+			})
+
+			waitDone(t, startAsync(t, c))
+
+			if fetched["/p"] != 1 {
+				t.Errorf("/p reported as fetched %d times, want once", fetched["/p"])
+			}
+
+			if fetched["/p/child"] != tt.wantChild {
+				t.Errorf("/p/child fetched %d times, want %d", fetched["/p/child"], tt.wantChild)
+			}
+
+			if got := len(c.AuditFindings()); got != tt.wantFindings {
+				t.Errorf("AuditFindings() = %v, want %d findings", c.AuditFindings(), tt.wantFindings)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package brink
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result holds everything the crawler found out about a visited URL.
+type Result struct {
+	// URL is the normalized form of the visited URL.
+	URL string
+
+	// Method is the http method of the request. It is GET unless the URL
+	// was visited by submitting a POST form.
+	Method string
+
+	// Body is the body of the request, e.g. of a submitted POST form or a
+	// seed request.
+	Body string
+
+	// LinkedFrom is the page on which the link to URL was found.
+	LinkedFrom string
+
+	// Depth is the number of links followed from the entrypoint to reach URL.
+	Depth int
+
+	// Status is the http status code of the response, or 0 if the request failed.
+	Status int
+
+	// ContentType is the value of the Content-Type header of the response.
+	ContentType string
+
+	// Size is the size of the body in bytes. If the body was not downloaded,
+	// it is the Content-Length reported by the server, which can be -1 if
+	// unknown.
+	Size int64
+
+	// Duration is the time it took to fetch the URL.
+	Duration time.Duration
+
+	// Err holds the error encountered while fetching the URL, if any.
+	Err error
+
+	// NoIndex is true if the page asks not to be indexed by its robots meta
+	// tag or X-Robots-Tag header.
+	NoIndex bool
+
+	// NoFollow is true if the page asks for its links not to be followed by
+	// its robots meta tag or X-Robots-Tag header.
+	NoFollow bool
+
+	// Cached is true if the URL has already been visited and the result is
+	// served from the list of visited URLs. Cached results only have their
+	// URL, Method, Body, LinkedFrom, Depth and Status set.
+	Cached bool
+}
+
+// Key identifies the request of the result among the visited ones, the way
+// the crawler does: GET requests without a body by their URL alone, the
+// others by their method, URL and the hash of their body.
+func (r Result) Key() string {
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	return visitKey(method, r.URL, r.Body)
+}
@@ -0,0 +1,109 @@
+package brink
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// robotsHeader is the response header carrying the robots directives of
+// pages which are not HTML, or which prefer headers over meta tags.
+const robotsHeader = "X-Robots-Tag"
+
+// robotsDirectives are the directives of a page about how crawlers should
+// treat it.
+type robotsDirectives struct {
+	noIndex  bool
+	noFollow bool
+}
+
+// RobotsMetaIn expects a valid HTML to parse and returns the contents of its
+// <meta name="robots"> tags joined by commas, e.g. "noindex, nofollow".
+func RobotsMetaIn(body []byte) string {
+	var contents []string
+
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return strings.Join(contents, ", ")
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		if t.Data != "meta" {
+			continue
+		}
+
+		var name, content string
+		for _, attr := range t.Attr {
+			switch attr.Key {
+			case "name":
+				name = attr.Val
+			case "content":
+				content = attr.Val
+			}
+		}
+
+		if strings.EqualFold(strings.TrimSpace(name), "robots") && strings.TrimSpace(content) != "" {
+			contents = append(contents, strings.TrimSpace(content))
+		}
+	}
+}
+
+// parseRobotsDirectives parses the comma separated directives of meta tags
+// and X-Robots-Tag headers. Values addressed to a specific crawler, e.g.
+// "googlebot: noindex", are ignored.
+func parseRobotsDirectives(values ...string) robotsDirectives {
+	var d robotsDirectives
+
+	for _, v := range values {
+		if i := strings.Index(v, ":"); i != -1 && !robotsDirectiveWithValue(v[:i]) {
+			continue
+		}
+
+		for _, directive := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "noindex":
+				d.noIndex = true
+			case "nofollow":
+				d.noFollow = true
+			case "none":
+				d.noIndex = true
+				d.noFollow = true
+			}
+		}
+	}
+
+	return d
+}
+
+// robotsDirectiveWithValue reports whether the name is a directive taking a
+// value after a colon, as opposed to the name of a crawler.
+func robotsDirectiveWithValue(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+
+	return false
+}
+
+// robotsDirectivesOf returns the directives of the response, given by its
+// X-Robots-Tag headers and, for HTML pages, its robots meta tags.
+func robotsDirectivesOf(resp *response) robotsDirectives {
+	values := resp.header[http.CanonicalHeaderKey(robotsHeader)]
+
+	if isHTML(resp.header.Get("Content-Type"), resp.body) {
+		if meta := RobotsMetaIn(resp.body); meta != "" {
+			values = append(values, meta)
+		}
+	}
+
+	return parseRobotsDirectives(values...)
+}
@@ -0,0 +1,154 @@
+package brink
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_parseRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   robotsDirectives
+	}{
+		{"none given", nil, robotsDirectives{}},
+		{"noindex", []string{"noindex"}, robotsDirectives{noIndex: true}},
+		{"both", []string{"NoIndex, NOFOLLOW"}, robotsDirectives{noIndex: true, noFollow: true}},
+		{"none", []string{"none"}, robotsDirectives{noIndex: true, noFollow: true}},
+		{"across values", []string{"index", "nofollow"}, robotsDirectives{noFollow: true}},
+		{"other crawler", []string{"googlebot: noindex, nofollow"}, robotsDirectives{}},
+		{"directive with value", []string{"unavailable_after: 25 Jun 2010 15:00:00 PST, noindex"}, robotsDirectives{noIndex: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRobotsDirectives(tt.values...); got != tt.want {
+				t.Errorf("parseRobotsDirectives() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRobotsMetaIn(t *testing.T) {
+	body := []byte(`<html><head><meta name="Robots" content="noindex"><meta name="description" content="nofollow">
+<meta name="robots" content=" nofollow "></head><body></body></html>`)
+
+	if got := RobotsMetaIn(body); got != "noindex, nofollow" {
+		t.Errorf("RobotsMetaIn() = %q", got)
+	}
+}
+
+func robotsSite() *httptest.Server {
+	pages := map[string]string{
+		"/":              `<a href="/nofollow">page nofollow</a> <a href="/header">header nofollow</a> <a href="/admin" rel="external nofollow">admin</a>`,
+		"/nofollow":      `<html><head><meta name="robots" content="noindex,nofollow"></head><body><a href="/from-nofollow">x</a></body></html>`,
+		"/header":        `<a href="/from-header">x</a>`,
+		"/admin":         `<a href="/admin/delete">delete everything</a>`,
+		"/from-nofollow": `<a href="/deeper-1">x</a>`,
+		"/from-header":   `<a href="/deeper-2">x</a>`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Path == "/header" {
+			w.Header().Set("X-Robots-Tag", "nofollow")
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestCrawler_robotsDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignore  bool
+		visited []string
+	}{
+		{"obeyed", false, []string{"/nofollow", "/header", "/admin", "/from-nofollow", "/from-header"}},
+		{"ignored", true, []string{"/nofollow", "/header", "/admin", "/from-nofollow", "/from-header", "/admin/delete", "/deeper-1", "/deeper-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := robotsSite()
+			defer ts.Close()
+
+			c := testCrawler(t, ts.URL, CrawlOptions{IgnoreRobotsDirectives: tt.ignore})
+
+			var (
+				mu      sync.Mutex
+				results = make(map[string]Result)
+			)
+			c.HandleResultFunc(func(r Result) {
+				mu.Lock()
+				results[r.URL] = r
+				mu.Unlock()
+			})
+
+			waitDone(t, startAsync(t, c))
+
+			if len(results) != len(tt.visited)+1 {
+				t.Errorf("visited %d urls, want %d: %v", len(results), len(tt.visited)+1, results)
+			}
+
+			for _, path := range tt.visited {
+				if _, ok := results[ts.URL+path]; !ok {
+					t.Errorf("%s was not visited", path)
+				}
+			}
+
+			if r := results[ts.URL+"/nofollow"]; !r.NoIndex || !r.NoFollow {
+				t.Errorf("unexpected directives of /nofollow: %+v", r)
+			}
+
+			if r := results[ts.URL+"/header"]; r.NoIndex || !r.NoFollow {
+				t.Errorf("unexpected directives of /header: %+v", r)
+			}
+		})
+	}
+}
+
+func TestCrawler_nofollowThenFollowable(t *testing.T) {
+	ts := testSite(map[string]string{
+		"/":        `<a href="/p" rel="nofollow">p</a> <a href="/q">q</a>`,
+		"/q":       `<a href="/p">p again</a>`,
+		"/p":       `<a href="/p/child">child</a>`,
+		"/p/child": `child`,
+	})
+	defer ts.Close()
+
+	// A single worker visits /p through the nofollow link before finding
+	// the followable one on /q.
+	c := testCrawler(t, ts.URL, CrawlOptions{WorkerCount: 1})
+
+	var (
+		mu      sync.Mutex
+		fetched = make(map[string]int)
+	)
+	c.HandleResultFunc(func(r Result) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !r.Cached {
+			fetched[strings.TrimPrefix(r.URL, ts.URL)]++
+		}
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	if fetched["/p/child"] != 1 {
+		t.Errorf("links of /p were not followed after reaching it through a followable link: %v", fetched)
+	}
+
+	if fetched["/p"] != 1 {
+		t.Errorf("/p reported as fetched %d times, want once", fetched["/p"])
+	}
+}
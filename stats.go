@@ -0,0 +1,294 @@
+package brink
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of errors kept in Stats.RecentErrors.
+const maxRecentErrors = 50
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the
+// fetch latency histogram.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Stats is a snapshot of the statistics of a crawl.
+type Stats struct {
+	// Started is the time the crawl was started.
+	Started time.Time
+
+	// PagesFetched is the number of requests that returned a response.
+	PagesFetched int64
+
+	// CachedVisits is the number of links pointing to already visited URLs.
+	CachedVisits int64
+
+	// Errors is the number of requests that failed without a response.
+	Errors int64
+
+	// StatusCounts holds the number of responses by their status codes.
+	StatusCounts map[int]int64
+
+	// BytesDownloaded is the total size of the downloaded bodies.
+	BytesDownloaded int64
+
+	// Latency is the histogram of the time it took to fetch the pages.
+	Latency Histogram
+
+	// FrontierSize is the number of links waiting to be visited.
+	FrontierSize int
+
+	// Hosts holds the request and error counts by host.
+	Hosts map[string]HostStats
+
+	// RecentErrors holds the last failed requests and error responses,
+	// the most recent one being the last.
+	RecentErrors []RecentError
+}
+
+// RecentError is a failed request or a response with a status of 400 or
+// above.
+type RecentError struct {
+	Time       time.Time
+	URL        string
+	LinkedFrom string
+	Status     int
+	Err        string
+}
+
+// HostStats holds the statistics of the requests sent to a single host.
+// Errors include failed requests and responses with a status of 400 or
+// above.
+type HostStats struct {
+	Requests int64
+	Errors   int64
+}
+
+// ErrorRate returns the ratio of errors to requests.
+func (h HostStats) ErrorRate() float64 {
+	if h.Requests == 0 {
+		return 0
+	}
+
+	return float64(h.Errors) / float64(h.Requests)
+}
+
+// Histogram is a cumulative histogram. Counts[i] is the number of
+// observations less than or equal to Buckets[i].
+type Histogram struct {
+	Buckets []float64
+	Counts  []int64
+	Count   int64
+	Sum     float64
+}
+
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{
+		Buckets: buckets,
+		Counts:  make([]int64, len(buckets)),
+	}
+}
+
+func (h *Histogram) observe(v float64) {
+	for i, b := range h.Buckets {
+		if v <= b {
+			h.Counts[i]++
+		}
+	}
+
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) clone() Histogram {
+	h.Buckets = append([]float64(nil), h.Buckets...)
+	h.Counts = append([]int64(nil), h.Counts...)
+
+	return h
+}
+
+// stats collects the statistics of the crawl. It is safe for concurrent use.
+type stats struct {
+	mu sync.Mutex
+	s  Stats
+}
+
+func newStats() *stats {
+	return &stats{
+		s: Stats{
+			StatusCounts: make(map[int]int64),
+			Latency:      newHistogram(latencyBuckets),
+			Hosts:        make(map[string]HostStats),
+		},
+	}
+}
+
+func (st *stats) start() {
+	st.mu.Lock()
+	st.s.Started = time.Now()
+	st.mu.Unlock()
+}
+
+// add records the result of a visit.
+func (st *stats) add(r Result) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if r.Cached {
+		st.s.CachedVisits++
+		return
+	}
+
+	var host string
+	if u, err := url.Parse(r.URL); err == nil {
+		host = u.Host
+	}
+	h := st.s.Hosts[host]
+	h.Requests++
+
+	if r.Err != nil {
+		st.s.Errors++
+		h.Errors++
+		st.s.Hosts[host] = h
+		st.addRecentError(RecentError{Time: time.Now(), URL: r.URL, LinkedFrom: r.LinkedFrom, Err: r.Err.Error()})
+		return
+	}
+
+	if r.Status >= 400 {
+		h.Errors++
+		st.addRecentError(RecentError{Time: time.Now(), URL: r.URL, LinkedFrom: r.LinkedFrom, Status: r.Status})
+	}
+	st.s.Hosts[host] = h
+
+	st.s.PagesFetched++
+	st.s.StatusCounts[r.Status]++
+	st.s.Latency.observe(r.Duration.Seconds())
+}
+
+// addDownloaded records the size of a body read by the crawler. The size
+// of results is not counted, as it is the Content-Length of the responses
+// whose body was not read, e.g. because it was too large.
+func (st *stats) addDownloaded(n int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.s.BytesDownloaded += n
+}
+
+// addRecentError records the error, dropping the oldest one if there are
+// too many. The caller must hold mu.
+func (st *stats) addRecentError(e RecentError) {
+	if len(st.s.RecentErrors) == maxRecentErrors {
+		st.s.RecentErrors = append(st.s.RecentErrors[:0], st.s.RecentErrors[1:]...)
+	}
+
+	st.s.RecentErrors = append(st.s.RecentErrors, e)
+}
+
+func (st *stats) snapshot() Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s := st.s
+	s.Latency = st.s.Latency.clone()
+
+	s.StatusCounts = make(map[int]int64, len(st.s.StatusCounts))
+	for k, v := range st.s.StatusCounts {
+		s.StatusCounts[k] = v
+	}
+
+	s.Hosts = make(map[string]HostStats, len(st.s.Hosts))
+	for k, v := range st.s.Hosts {
+		s.Hosts[k] = v
+	}
+
+	s.RecentErrors = append([]RecentError(nil), st.s.RecentErrors...)
+
+	return s
+}
+
+// WritePrometheus writes the statistics in the Prometheus text exposition
+// format.
+func (s Stats) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("brink_pages_fetched_total", "counter", "Number of requests that returned a response.")
+	fmt.Fprintf(&b, "brink_pages_fetched_total %d\n", s.PagesFetched)
+
+	metric("brink_cached_visits_total", "counter", "Number of links pointing to already visited URLs.")
+	fmt.Fprintf(&b, "brink_cached_visits_total %d\n", s.CachedVisits)
+
+	metric("brink_fetch_errors_total", "counter", "Number of requests that failed without a response.")
+	fmt.Fprintf(&b, "brink_fetch_errors_total %d\n", s.Errors)
+
+	metric("brink_responses_total", "counter", "Number of responses by status code.")
+	statuses := make([]int, 0, len(s.StatusCounts))
+	for status := range s.StatusCounts {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "brink_responses_total{status=\"%d\"} %d\n", status, s.StatusCounts[status])
+	}
+
+	metric("brink_downloaded_bytes_total", "counter", "Total size of the downloaded bodies.")
+	fmt.Fprintf(&b, "brink_downloaded_bytes_total %d\n", s.BytesDownloaded)
+
+	metric("brink_fetch_duration_seconds", "histogram", "Time it took to fetch the pages.")
+	for i, bucket := range s.Latency.Buckets {
+		fmt.Fprintf(&b, "brink_fetch_duration_seconds_bucket{le=\"%s\"} %d\n", formatFloat(bucket), s.Latency.Counts[i])
+	}
+	fmt.Fprintf(&b, "brink_fetch_duration_seconds_bucket{le=\"+Inf\"} %d\n", s.Latency.Count)
+	fmt.Fprintf(&b, "brink_fetch_duration_seconds_sum %s\n", formatFloat(s.Latency.Sum))
+	fmt.Fprintf(&b, "brink_fetch_duration_seconds_count %d\n", s.Latency.Count)
+
+	metric("brink_frontier_size", "gauge", "Number of links waiting to be visited.")
+	fmt.Fprintf(&b, "brink_frontier_size %d\n", s.FrontierSize)
+
+	hosts := make([]string, 0, len(s.Hosts))
+	for host := range s.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	metric("brink_host_requests_total", "counter", "Number of requests by host.")
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "brink_host_requests_total{host=\"%s\"} %d\n", escapeLabel(host), s.Hosts[host].Requests)
+	}
+
+	metric("brink_host_errors_total", "counter", "Number of failed requests and error responses by host.")
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "brink_host_errors_total{host=\"%s\"} %d\n", escapeLabel(host), s.Hosts[host].Errors)
+	}
+
+	if !s.Started.IsZero() {
+		metric("brink_start_time_seconds", "gauge", "Unix time the crawl was started at.")
+		fmt.Fprintf(&b, "brink_start_time_seconds %d\n", s.Started.Unix())
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// labelEscaper escapes label values the way the Prometheus text format
+// does, which only knows backslashes, double quotes and line feeds.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
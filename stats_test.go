@@ -0,0 +1,178 @@
+package brink
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStats() *stats {
+	st := newStats()
+
+	st.add(Result{URL: "https://liferay.com", Status: 200, Size: 1000, Duration: 80 * time.Millisecond})
+	st.add(Result{URL: "https://liferay.com/a", Status: 200, Size: 500, Duration: 300 * time.Millisecond})
+	st.add(Result{URL: "https://liferay.com/missing", Status: 404, Size: -1, Duration: 20 * time.Millisecond})
+	st.add(Result{URL: "https://liferay.com/missing", Status: 404, Cached: true})
+	st.add(Result{URL: "https://down.example.com/", Err: fmt.Errorf("connection refused")})
+	st.addDownloaded(1000)
+	st.addDownloaded(500)
+
+	return st
+}
+
+func Test_stats(t *testing.T) {
+	s := testStats().snapshot()
+
+	if s.PagesFetched != 3 || s.CachedVisits != 1 || s.Errors != 1 {
+		t.Errorf("unexpected counts: fetched %d, cached %d, errors %d", s.PagesFetched, s.CachedVisits, s.Errors)
+	}
+
+	if s.StatusCounts[200] != 2 || s.StatusCounts[404] != 1 {
+		t.Errorf("unexpected status counts: %v", s.StatusCounts)
+	}
+
+	if s.BytesDownloaded != 1500 {
+		t.Errorf("BytesDownloaded = %d, want 1500", s.BytesDownloaded)
+	}
+
+	if s.Latency.Count != 3 || s.Latency.Counts[0] != 1 || s.Latency.Counts[1] != 2 || s.Latency.Counts[3] != 3 {
+		t.Errorf("unexpected latency histogram: %+v", s.Latency)
+	}
+
+	if h := s.Hosts["liferay.com"]; h.Requests != 3 || h.Errors != 1 {
+		t.Errorf("unexpected host stats: %+v", h)
+	}
+
+	if rate := s.Hosts["down.example.com"].ErrorRate(); rate != 1 {
+		t.Errorf("ErrorRate() = %v, want 1", rate)
+	}
+
+	if len(s.RecentErrors) != 2 || s.RecentErrors[0].Status != 404 || s.RecentErrors[1].Err != "connection refused" {
+		t.Errorf("unexpected recent errors: %+v", s.RecentErrors)
+	}
+}
+
+func Test_stats_recentErrorsLimit(t *testing.T) {
+	st := newStats()
+
+	for i := 0; i < maxRecentErrors+10; i++ {
+		st.add(Result{URL: fmt.Sprintf("https://liferay.com/%d", i), Status: 500})
+	}
+
+	s := st.snapshot()
+	if len(s.RecentErrors) != maxRecentErrors {
+		t.Fatalf("len(RecentErrors) = %d, want %d", len(s.RecentErrors), maxRecentErrors)
+	}
+
+	if last := s.RecentErrors[maxRecentErrors-1].URL; last != fmt.Sprintf("https://liferay.com/%d", maxRecentErrors+9) {
+		t.Errorf("last recent error is %s", last)
+	}
+}
+
+func Test_stats_snapshotIsCopy(t *testing.T) {
+	st := testStats()
+
+	s := st.snapshot()
+	s.StatusCounts[200] = 100
+	s.Latency.Counts[0] = 100
+
+	if again := st.snapshot(); again.StatusCounts[200] != 2 || again.Latency.Counts[0] != 1 {
+		t.Errorf("snapshot shares state with the collector")
+	}
+}
+
+func TestStats_WritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testStats().snapshot().WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"# TYPE brink_pages_fetched_total counter\nbrink_pages_fetched_total 3\n",
+		"brink_responses_total{status=\"200\"} 2\nbrink_responses_total{status=\"404\"} 1\n",
+		"brink_downloaded_bytes_total 1500\n",
+		"brink_fetch_duration_seconds_bucket{le=\"0.1\"} 2\n",
+		"brink_fetch_duration_seconds_bucket{le=\"+Inf\"} 3\n",
+		"brink_fetch_duration_seconds_count 3\n",
+		"brink_frontier_size 0\n",
+		"brink_host_errors_total{host=\"down.example.com\"} 1\n",
+		"brink_host_requests_total{host=\"liferay.com\"} 3\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WritePrometheus() output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestStats_WritePrometheus_unicodeHost(t *testing.T) {
+	st := newStats()
+	st.add(Result{URL: "https://bücher.example/", Status: 200})
+
+	var buf bytes.Buffer
+	if err := st.snapshot().WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus() error = %v", err)
+	}
+
+	if want := "brink_host_requests_total{host=\"bücher.example\"} 1\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("WritePrometheus() output does not contain %q:\n%s", want, buf.String())
+	}
+}
+
+func Test_escapeLabel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"liferay.com", "liferay.com"},
+		{"bücher.example", "bücher.example"},
+		{"a\tb", "a\tb"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+	}
+	for _, tt := range tests {
+		if got := escapeLabel(tt.value); got != tt.want {
+			t.Errorf("escapeLabel(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCrawler_bytesDownloaded(t *testing.T) {
+	const root = `<html><body><a href="/large">Large</a></body></html>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := root
+		if r.URL.Path == "/large" {
+			body = strings.Repeat("x", 5000)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	c := testCrawler(t, ts.URL, CrawlOptions{MaxContentLength: 1000})
+
+	var large Result
+	c.HandleResultFunc(func(r Result) {
+		if strings.HasSuffix(r.URL, "/large") {
+			large = r
+		}
+	})
+
+	waitDone(t, startAsync(t, c))
+
+	if large.Size != 5000 {
+		t.Errorf("Size of too large response = %d, want its Content-Length", large.Size)
+	}
+
+	if s := c.Stats(); s.BytesDownloaded != int64(len(root)) {
+		t.Errorf("BytesDownloaded = %d, want only the %d bytes read", s.BytesDownloaded, len(root))
+	}
+}
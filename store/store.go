@@ -34,6 +34,22 @@ func (cs *CStore) Load(key string) (string, bool) {
 	return val, ok
 }
 
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value. The loaded result
+// is true if the value was loaded, false if stored.
+func (cs *CStore) LoadOrStore(key, value string) (string, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if val, ok := cs.store[key]; ok {
+		return val, true
+	}
+
+	cs.store[key] = value
+
+	return value, false
+}
+
 // Contains checks if a key exists in the store.
 func (cs *CStore) Contains(key string) bool {
 	_, ok := cs.Load(key)
@@ -229,3 +229,31 @@ func TestCStore_AnyContainsReverse(t *testing.T) {
 		})
 	}
 }
+
+func TestCStore_LoadOrStore(t *testing.T) {
+	cs := New()
+	cs.Store("existingKey", "existingValue")
+
+	type args struct {
+		key   string
+		value string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantLoaded bool
+	}{
+		{"existing", args{"existingKey", "newValue"}, "existingValue", true},
+		{"new", args{"newKey", "newValue"}, "newValue", false},
+		{"new_again", args{"newKey", "otherValue"}, "newValue", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, loaded := cs.LoadOrStore(tt.args.key, tt.args.value)
+			if got != tt.want || loaded != tt.wantLoaded {
+				t.Errorf("CStore.LoadOrStore() = (%q, %t), want (%q, %t)", got, loaded, tt.want, tt.wantLoaded)
+			}
+		})
+	}
+}
@@ -3,9 +3,8 @@ package brink
 import (
 	"bytes"
 	"fmt"
-	"log"
+	"net/http"
 	"net/url"
-	"sort"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -29,12 +28,34 @@ func scheme(_url string) (string, error) {
 	return u.Scheme, nil
 }
 
+// Kinds of elements and documents links can be found in
+const (
+	KindAnchor     = "a"
+	KindLinkTag    = "link"
+	KindStylesheet = "css"
+	KindFeed       = "feed"
+	KindSitemap    = "sitemap"
+	KindPDF        = "pdf"
+)
+
 // Link represents a very basic HTML anchor tag. LinkedFrom is the page on which it is found,
-// Href is where it is pointing to.
+// Href is where it is pointing to. Text is the text of the anchor and Kind is the kind of
+// element the link was found in. Depth is the number of links followed from the entrypoint
+// to reach the page the link points to. NoFollow is true for rel="nofollow" anchors and the
+// links of pages asking for their links not to be followed. Method is the http method to
+// request Href with, GET if empty, and Body is the body sent along, e.g. the encoded fields
+// of a submitted POST form. Header holds the headers to send with this request only.
 type Link struct {
 	LinkedFrom string
 	Href       string
 	Target     string
+	Text       string
+	Kind       string
+	Depth      int
+	NoFollow   bool
+	Method     string
+	Body       string
+	Header     http.Header
 }
 
 // AbsoluteLinksIn expects a valid HTML to parse and returns a slice
@@ -53,11 +74,9 @@ func AbsoluteLinksIn(hostURL, linkedFrom string, body []byte, ignoreAnchors bool
 	links := LinksIn(linkedFrom, body, ignoreAnchors)
 	for ix, l := range links {
 		if strings.HasPrefix(l.Href, "//") {
-			l.Href = fmt.Sprintf("%s://%s", scheme, l.Href)
+			l.Href = fmt.Sprintf("%s:%s", scheme, l.Href)
 			links[ix] = l
-		}
-
-		if strings.HasPrefix(l.Href, "/") {
+		} else if strings.HasPrefix(l.Href, "/") {
 			l.Href = fmt.Sprintf("%s://%s%s", scheme, host, l.Href)
 			links[ix] = l
 		}
@@ -73,23 +92,50 @@ func AbsoluteLinksIn(hostURL, linkedFrom string, body []byte, ignoreAnchors bool
 func LinksIn(linkedFrom string, body []byte, ignoreAnchors bool) []Link {
 	links := make([]Link, 0)
 
+	// current is the anchor whose text is being read, if any
+	var (
+		current *Link
+		text    []string
+	)
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+
+		current.Text = strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+		links = append(links, *current)
+
+		current, text = nil, nil
+	}
+
 	z := html.NewTokenizer(bytes.NewBuffer(body))
 	for {
 		if z.Next() == html.ErrorToken {
 			// Returning io.EOF indicates success.
+			finish()
 			return links
 		}
 
 		t := z.Token()
 
-		if t.Type == html.StartTagToken && t.Data == "a" {
-			l := Link{LinkedFrom: linkedFrom}
+		switch {
+		case t.Type == html.TextToken && current != nil:
+			text = append(text, t.Data)
+		case t.Type == html.EndTagToken && t.Data == "a":
+			finish()
+		case t.Type == html.StartTagToken && t.Data == "a":
+			finish()
+
+			l := Link{LinkedFrom: linkedFrom, Kind: KindAnchor}
 			for _, attr := range t.Attr {
 				switch attr.Key {
 				case "href":
 					l.Href = attr.Val
 				case "target":
 					l.Target = attr.Val
+				case "rel":
+					l.NoFollow = hasToken(attr.Val, "nofollow")
 				}
 			}
 
@@ -100,58 +146,93 @@ func LinksIn(linkedFrom string, body []byte, ignoreAnchors bool) []Link {
 
 			l.Href = strings.Trim(l.Href, " ")
 
-			links = append(links, l)
+			current = &l
 		}
 	}
 }
 
 // normalizeURL expects a full URL and returns one in which the GET parameters
 // have been sorted by their keys. It also removes any GET parameters which the
-// Crawler has been told to ignore.
+// Crawler has been told to ignore, and applies the rest of the normalization
+// rules configured in the CrawlOptions.
 func (c *Crawler) normalizeURL(_url string) (string, error) {
-	var (
-		result       []string
-		ignoreParams bool
-	)
+	return c.opts.Normalization.normalize(_url, c.ignoredGETParam)
+}
 
-	u, err := url.ParseRequestURI(strings.TrimSpace(_url))
-	if err != nil {
-		return "", fmt.Errorf("failed parsing url: %v", err)
+func (c *Crawler) ignoredGETParam(key string) bool {
+	if c.ignoredGETParams.Size() == 0 {
+		return false
 	}
-	params := u.Query()
 
-	if c.ignoredGETParams.Size() > 0 {
-		ignoreParams = true
+	if c.ignoredGETParams.Contains(key) {
+		return true
 	}
 
-	for key, vals := range params {
-		if ignoreParams {
-			if c.ignoredGETParams.Contains(key) {
-				continue
-			}
+	return c.opts.FuzzyGETParameterChecks && c.ignoredGETParams.AnyContainsReverse(key)
+}
 
-			if c.opts.FuzzyGETParameterChecks && c.ignoredGETParams.AnyContainsReverse(key) {
-				continue
+// CanonicalIn expects a valid HTML to parse and returns the href of
+// its <link rel="canonical"> tag, or an empty string if there is none.
+func CanonicalIn(body []byte) string {
+	z := html.NewTokenizer(bytes.NewBuffer(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return ""
+		}
+
+		t := z.Token()
+		if tt == html.StartTagToken && t.Data == "body" {
+			return ""
+		}
+
+		if (tt != html.StartTagToken && tt != html.SelfClosingTagToken) || t.Data != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range t.Attr {
+			switch attr.Key {
+			case "rel":
+				rel = attr.Val
+			case "href":
+				href = attr.Val
 			}
 		}
 
-		for _, val := range vals {
-			if val == "" {
-				result = append(result, key)
-				continue
+		for _, r := range strings.Fields(rel) {
+			if strings.EqualFold(r, "canonical") {
+				return strings.TrimSpace(href)
 			}
+		}
+	}
+}
 
-			result = append(result, fmt.Sprintf("%s=%s", key, val))
+// hasToken reports whether the space separated list contains the token,
+// ignoring case.
+func hasToken(list, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
 		}
 	}
 
-	if len(result) == 0 {
-		return fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, u.Path), nil
+	return false
+}
+
+// resolveURL resolves the possibly relative ref against base.
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("failed parsing base url: %v", err)
 	}
 
-	sort.Strings(result)
+	r, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", fmt.Errorf("failed parsing reference: %v", err)
+	}
 
-	return fmt.Sprintf("%s://%s%s?%s", u.Scheme, u.Host, u.Path, strings.Join(result, "&")), nil
+	return b.ResolveReference(r).String(), nil
 }
 
 func getPath(_url string) (string, error) {
@@ -166,8 +247,7 @@ func getPath(_url string) (string, error) {
 func pathForbidden(c *Crawler, _url string) bool {
 	p, err := getPath(_url)
 	if err != nil {
-		// Debug..
-		log.Printf("%s: failed getPath: %v", _url, err)
+		c.logger.Debug("failed getting path", "url", _url, "error", err)
 		return false
 	}
 
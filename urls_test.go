@@ -55,7 +55,7 @@ func Test_LinksIn(t *testing.T) {
 		{"no links with anchors", args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body>Hello world</body></html>"), true}, []Link{}},
 		{"one link with anchors",
 			args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"#\">Hello world</a></body></html>"), false},
-			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "#"}},
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "#", Text: "Hello world", Kind: KindAnchor}},
 		},
 		{"ignore anchor",
 			args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"#\">Hello world</a></body></html>"), true},
@@ -63,19 +63,27 @@ func Test_LinksIn(t *testing.T) {
 		},
 		{"one link with target blank",
 			args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"google.com\" target=\"_blank\">Hello world</a></body></html>"), true},
-			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Target: "_blank"}},
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Target: "_blank", Text: "Hello world", Kind: KindAnchor}},
 		},
 		{"two links with target blank",
 			args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"google.com\">Hello world</a><a href=\"liferay.com\" target=\"_blank\">Whatsup</a></body></html>"), true},
 			[]Link{
-				Link{LinkedFrom: "https://www.liferay.com", Href: "google.com"},
-				Link{LinkedFrom: "https://www.liferay.com", Href: "liferay.com", Target: "_blank"},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Text: "Hello world", Kind: KindAnchor},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "liferay.com", Target: "_blank", Text: "Whatsup", Kind: KindAnchor},
 			},
 		},
 		{"one link with javascript",
 			args{"https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"javascript:;\">Hello world</a></body></html>"), false},
 			[]Link{},
 		},
+		{"nested and unclosed anchors",
+			args{"https://www.liferay.com", []byte("<html><body><a href=\"/one\"> <b>Bold</b>\n text </a><a href=\"/two\">Two<a href=\"/three\">Three</body></html>"), false},
+			[]Link{
+				Link{LinkedFrom: "https://www.liferay.com", Href: "/one", Text: "Bold text", Kind: KindAnchor},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "/two", Text: "Two", Kind: KindAnchor},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "/three", Text: "Three", Kind: KindAnchor},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -139,21 +147,29 @@ func TestAbsoluteLinksIn(t *testing.T) {
 		{"no links with anchors", args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body>Hello world</body></html>"), true}, []Link{}, false},
 		{"one link with anchors",
 			args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"#\">Hello world</a></body></html>"), false},
-			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "#"}}, false,
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "#", Text: "Hello world", Kind: KindAnchor}}, false,
 		},
 		{"ignore anchor",
 			args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"#\">Hello world</a></body></html>"), true},
 			[]Link{}, false,
 		},
+		{"protocol relative link",
+			args{"https://google.com", "https://www.liferay.com", []byte("<html><body><a href=\"//cdn.liferay.com/a\">CDN</a></body></html>"), true},
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "https://cdn.liferay.com/a", Text: "CDN", Kind: KindAnchor}}, false,
+		},
+		{"nofollow link",
+			args{"https://google.com", "https://www.liferay.com", []byte("<html><body><a href=\"/admin\" rel=\"NoFollow noopener\">Admin</a></body></html>"), true},
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "https://google.com/admin", Text: "Admin", Kind: KindAnchor, NoFollow: true}}, false,
+		},
 		{"one link with target blank",
 			args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"google.com\" target=\"_blank\">Hello world</a></body></html>"), true},
-			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Target: "_blank"}}, false,
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Target: "_blank", Text: "Hello world", Kind: KindAnchor}}, false,
 		},
 		{"two links with target blank",
 			args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"google.com\">Hello world</a><a href=\"liferay.com\" target=\"_blank\">Whatsup</a></body></html>"), true},
 			[]Link{
-				Link{LinkedFrom: "https://www.liferay.com", Href: "google.com"},
-				Link{LinkedFrom: "https://www.liferay.com", Href: "liferay.com", Target: "_blank"},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "google.com", Text: "Hello world", Kind: KindAnchor},
+				Link{LinkedFrom: "https://www.liferay.com", Href: "liferay.com", Target: "_blank", Text: "Whatsup", Kind: KindAnchor},
 			}, false,
 		},
 		{"one link with javascript",
@@ -167,7 +183,7 @@ func TestAbsoluteLinksIn(t *testing.T) {
 		},
 		{"one dynamic link",
 			args{"https://google.com", "https://www.liferay.com", []byte("<html><header><title>This is title</title></header><body><a href=\"/hello\" target=\"_blank\">Hello world</a></body></html>"), true},
-			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "https://google.com/hello", Target: "_blank"}}, false,
+			[]Link{Link{LinkedFrom: "https://www.liferay.com", Href: "https://google.com/hello", Target: "_blank", Text: "Hello world", Kind: KindAnchor}}, false,
 		},
 	}
 	for _, tt := range tests {
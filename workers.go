@@ -0,0 +1,192 @@
+package brink
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// worker visits the links received from the urls channel until it is told
+// to quit or the crawler is stopped.
+type worker struct {
+	name string
+
+	// running is 1 while the worker is visiting a link.
+	running int32
+
+	// quit is closed to make the worker exit once it finishes its current
+	// visit. quitting is set at the same time, guarded by the crawler's wmu.
+	quit     chan struct{}
+	quitting bool
+}
+
+// SetWorkerCount changes the number of workers crawling the pages. If the
+// crawler is running, new workers are spawned right away, while the ones
+// above the count exit once they finish their current visits.
+func (c *Crawler) SetWorkerCount(n int) error {
+	if n < 1 {
+		return fmt.Errorf("worker count must be at least 1, got %d", n)
+	}
+
+	if c.isStopping() {
+		return fmt.Errorf("crawler is stopped")
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.opts.WorkerCount = n
+
+	if !c.started {
+		return nil
+	}
+
+	active := c.activeWorkers()
+	for ; active < n; active++ {
+		c.spawnWorker()
+	}
+
+	for i := len(c.workers) - 1; i >= 0 && active > n; i-- {
+		w := c.workers[i]
+		if w.quitting {
+			continue
+		}
+
+		c.logger.Debug("stopping worker", "worker", w.name)
+		w.quitting = true
+		close(w.quit)
+		active--
+	}
+
+	return nil
+}
+
+// WorkerCount returns the number of workers crawling the pages, not
+// counting the ones that are about to exit.
+func (c *Crawler) WorkerCount() int {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if !c.started {
+		return c.opts.WorkerCount
+	}
+
+	return c.activeWorkers()
+}
+
+// activeWorkers returns the number of workers which have not been told to
+// quit. The caller must hold wmu.
+func (c *Crawler) activeWorkers() int {
+	var n int
+	for _, w := range c.workers {
+		if !w.quitting {
+			n++
+		}
+	}
+
+	return n
+}
+
+// spawnWorker starts a new worker. The caller must hold wmu.
+func (c *Crawler) spawnWorker() {
+	c.workerSeq++
+
+	w := &worker{
+		name: fmt.Sprintf("worker-%d", c.workerSeq),
+		quit: make(chan struct{}),
+	}
+	c.logger.Debug("spawning worker", "worker", w.name)
+
+	c.workers = append(c.workers, w)
+	c.wg.Add(1)
+
+	go c.work(w)
+}
+
+func (c *Crawler) work(w *worker) {
+	defer c.wg.Done()
+	defer c.removeWorker(w)
+
+	for {
+		var link Link
+
+		select {
+		case <-c.done:
+			return
+		case <-w.quit:
+			return
+		case link = <-c.urls:
+		}
+
+		atomic.StoreInt32(&w.running, 1)
+
+		// Hold on to the link while paused, so that it is visited once
+		// the crawl is resumed.
+		c.waitWhilePaused()
+		if c.isStopping() {
+			return
+		}
+
+		for _, l := range c.visit(w.name, link) {
+			if !c.enqueue(l) {
+				return
+			}
+		}
+
+		atomic.StoreInt32(&w.running, 0)
+	}
+}
+
+func (c *Crawler) removeWorker(w *worker) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	for i, other := range c.workers {
+		if other == w {
+			c.workers = append(c.workers[:i], c.workers[i+1:]...)
+			return
+		}
+	}
+}
+
+// enqueue sends the link to the workers. It returns false if the crawler
+// has been stopped in the meantime.
+func (c *Crawler) enqueue(l Link) bool {
+	select {
+	case c.urls <- l:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// idle reports whether there is no work left to do.
+func (c *Crawler) idle() bool {
+	if c.Paused() || len(c.urls) != 0 {
+		return false
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	for _, w := range c.workers {
+		if atomic.LoadInt32(&w.running) == 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddSeeds adds the URLs to the links waiting to be visited. It can be
+// called while the crawler is running. It fails if any of the URLs is not
+// absolute, if the crawler is stopped, or if there is no more room for
+// links waiting to be visited, in which case the error is a BufferFull
+// telling how many of the URLs were added.
+func (c *Crawler) AddSeeds(urls ...string) error {
+	seeds := make([]Seed, len(urls))
+	for i, u := range urls {
+		seeds[i] = Seed{URL: u}
+	}
+
+	return c.AddSeedRequests(seeds...)
+}